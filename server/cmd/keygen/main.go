@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Generates a new token signing key, as a PEM file named after its key ID, in the keys directory.
+func main() {
+	dir := flag.String("dir", ".", "directory where the key is written")
+	public := flag.Bool("public", false, "also write the public key, under <dir>/public")
+	flag.Parse()
+
+	kid := flag.Arg(0)
+	if kid == "" {
+		log.Fatalln("usage: keygen [-dir DIR] [-public] KEY_ID")
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("error generating key: %v\n", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		log.Fatalf("error encoding private key: %v\n", err)
+	}
+
+	writeKey(filepath.Join(*dir, kid+".pem"), "PRIVATE KEY", privateDER)
+
+	if *public {
+		publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			log.Fatalf("error encoding public key: %v\n", err)
+		}
+
+		writeKey(filepath.Join(*dir, "public", kid+".pem"), "PUBLIC KEY", publicDER)
+	}
+}
+
+func writeKey(path, blockType string, der []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.Fatalf("error creating directory: %v\n", err)
+	}
+
+	// Never overwrite an existing key, as it would invalidate every token it signed.
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Fatalf("error creating key file: %v\n", err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		log.Fatalf("error writing key file: %v\n", err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+)
+
+// Rewrites the emails of existing users in normalized form, and reserves them in the user-emails collection under
+// their canonical key. It can run while the server is up, and more than once. Users with an invalid email, or sharing
+// a mailbox, are listed, and must be fixed by hand.
+func main() {
+	client := config.FirestoreClient
+
+	report, err := dao.MigrateUserEmails(
+		context.Background(),
+		client,
+		client.Collection("users"),
+		client.Collection("user-emails"),
+		config.EmailParser,
+	)
+	if err != nil {
+		log.Fatalf("error migrating emails: %v\n", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("error writing report: %v\n", err)
+	}
+
+	if len(report.Conflicts) > 0 || len(report.Invalid) > 0 {
+		os.Exit(1)
+	}
+}
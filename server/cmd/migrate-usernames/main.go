@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+)
+
+// Reserves the usernames of existing users in the user-usernames collection, so they are unique whatever their case.
+// It can run while the server is up, and more than once. Users sharing a username with a different case are listed,
+// and must be fixed by hand.
+func main() {
+	client := config.FirestoreClient
+
+	report, err := dao.MigrateUsernames(
+		context.Background(),
+		client,
+		client.Collection("users"),
+		client.Collection("user-usernames"),
+	)
+	if err != nil {
+		log.Fatalf("error migrating usernames: %v\n", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("error writing report: %v\n", err)
+	}
+
+	if len(report.Conflicts) > 0 {
+		os.Exit(1)
+	}
+}
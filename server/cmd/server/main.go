@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 	"os"
+	"os/signal"
+	"syscall"
 	"technical-interview/config"
 	"technical-interview/pkg/api"
 	"technical-interview/pkg/dao"
 	"technical-interview/pkg/handlers"
+	"technical-interview/pkg/mail"
+	"technical-interview/pkg/policy"
 	"technical-interview/pkg/services"
+	"technical-interview/pkg/webauthn"
 	"time"
 )
 
@@ -28,6 +34,117 @@ func newLogger() zerolog.Logger {
 		Logger()
 }
 
+func newMailer(logger zerolog.Logger) mail.Mailer {
+	switch config.Mail.Driver {
+	case config.MailDriverFile:
+		return mail.NewFileMailer(config.Mail.Dir)
+	case config.MailDriverSMTP:
+		return mail.NewSMTPMailer(
+			config.Mail.SMTP.Host,
+			config.Mail.SMTP.Port,
+			config.Mail.SMTP.Username,
+			config.Mail.SMTP.Password,
+			config.Mail.From,
+		)
+	default:
+		return mail.NewLogMailer(logger)
+	}
+}
+
+// newCors allows every origin to call the API, but only the frontend may send cookies, like the login link nonce.
+func newCors() gin.HandlerFunc {
+	public := cors.New(config.Cors)
+
+	frontendOrigin := config.App.FrontendOrigin()
+	if frontendOrigin == "" {
+		return public
+	}
+
+	frontendConfig := config.Cors
+	frontendConfig.AllowOrigins = []string{frontendOrigin}
+	frontendConfig.AllowCredentials = true
+	frontend := cors.New(frontendConfig)
+
+	return func(c *gin.Context) {
+		if c.GetHeader("Origin") == frontendOrigin {
+			frontend(c)
+			return
+		}
+
+		public(c)
+	}
+}
+
+func newLoginAttemptRepository() dao.LoginAttemptRepository {
+	if config.Auth.LoginProtection.Store == config.LoginAttemptStoreFirestore {
+		return dao.NewLoginAttemptRepository(config.FirestoreClient, config.FirestoreClient.Collection("login-attempts"))
+	}
+
+	return dao.NewMemoryLoginAttemptRepository()
+}
+
+func newRateLimitRepository() dao.RateLimitRepository {
+	if config.RateLimit.Store == config.RateLimitStoreFirestore {
+		return dao.NewRateLimitRepository(config.FirestoreClient, config.FirestoreClient.Collection("rate-limits"))
+	}
+
+	return dao.NewMemoryRateLimitRepository()
+}
+
+// rateLimit returns the middleware enforcing the named rule. Routes without a configured rule are not limited.
+func rateLimit(repository dao.RateLimitRepository, name string) gin.HandlerFunc {
+	rule, ok := config.RateLimit.Rules[name]
+	if !ok {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	key := api.KeyByIP
+	switch rule.Key {
+	case config.RateLimitKeyUser:
+		key = api.KeyByUser
+	case config.RateLimitKeyClient:
+		key = api.KeyByClient
+	}
+
+	return api.RateLimit(repository, api.RateLimitRule{Name: name, Limit: rule.Limit, Window: rule.Window, Key: key})
+}
+
+// reloadOnHangup reads the email domain lists again every time the process receives SIGHUP, so they can be updated
+// without a restart.
+func reloadOnHangup(logger zerolog.Logger, domainPolicy policy.EmailDomainPolicy) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		if err := domainPolicy.Reload(); err != nil {
+			logger.Error().Err(err).Msg("failed to reload email domain lists, keeping the previous ones")
+			continue
+		}
+
+		logger.Info().Msg("email domain lists reloaded")
+	}
+}
+
+// purgeDeletedUsers deletes the users whose grace period has ended, every interval, until the process exits.
+func purgeDeletedUsers(logger zerolog.Logger, service services.PurgeDeletedUsersService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		deleted, err := service.Exec(context.Background(), now)
+		if err != nil {
+			logger.Error().Err(err).Int("deleted", deleted).Msg("failed to delete some users, they will be retried")
+			continue
+		}
+
+		if deleted > 0 {
+			logger.Info().Int("deleted", deleted).Msg("deleted users whose grace period has ended")
+		}
+	}
+}
+
 func main() {
 	logger := newLogger()
 	router := gin.New()
@@ -39,28 +156,285 @@ func main() {
 	routerAPI := router.Use(
 		gin.RecoveryWithWriter(logger),
 		api.Logger(logger, config.App.ProjectID),
-		cors.New(config.Cors),
+		newCors(),
+	)
+
+	userDAO := dao.NewUserRepository(
+		config.FirestoreClient,
+		config.FirestoreClient.Collection("users"),
+		config.FirestoreClient.Collection("user-emails"),
+		config.FirestoreClient.Collection("user-usernames"),
+		config.EmailParser,
+		config.PasswordHasher,
+	)
+	sessionDAO := dao.NewSessionRepository(config.FirestoreClient, config.FirestoreClient.Collection("sessions"))
+	revocationDAO := dao.NewRevocationRepository(config.FirestoreClient, config.FirestoreClient.Collection("revoked-tokens"))
+	actionTokenDAO := dao.NewActionTokenRepository(config.FirestoreClient, config.FirestoreClient.Collection("action-tokens"))
+	mfaDAO := dao.NewMFARepository(config.FirestoreClient, config.FirestoreClient.Collection("mfa"))
+	webAuthnCredentialDAO := dao.NewWebAuthnCredentialRepository(
+		config.FirestoreClient,
+		config.FirestoreClient.Collection("webauthn-credentials"),
 	)
+	emailDomainDAO := dao.NewEmailDomainRepository(config.FirestoreClient, config.FirestoreClient.Collection("email-domains"))
+	loginAttemptDAO := newLoginAttemptRepository()
+	rateLimitDAO := newRateLimitRepository()
+
+	mailer := newMailer(logger)
+	// Mails are sent by tasks, which must not run for longer than a slow SMTP server takes to answer.
+	tasks := services.NewTaskRunner(logger, time.Minute)
+
+	emailDomainPolicy, err := policy.NewEmailDomainPolicy(config.Auth.Email.Domains.Options(), emailDomainDAO)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid email domain configuration")
+	}
+	go reloadOnHangup(logger, emailDomainPolicy)
+
+	webAuthnOptions, err := config.Auth.WebAuthn.Options()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid WebAuthn configuration")
+	}
+
+	relyingParty, err := webauthn.NewRelyingParty(webAuthnOptions)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("invalid WebAuthn configuration")
+	}
 
-	userDAO := dao.NewUserRepository(config.FirestoreClient.Collection("users"))
+	jwtOptions := services.JWTOptions{
+		Enabled:  config.Auth.JWT.Enabled,
+		Issuer:   config.Auth.JWT.Issuer,
+		Audience: config.Auth.JWT.Audience,
+	}
 
-	generateTokenService := services.NewGenerateTokenService(24 * time.Hour)
-	introspectTokenService := services.NewGetTokenStatusService()
+	generateTokenService := services.NewGenerateTokenService(config.Auth.TokenTTL, config.Keys, jwtOptions)
+	introspectTokenService := services.NewGetTokenStatusService(
+		config.Keys,
+		jwtOptions,
+		revocationDAO,
+		sessionDAO,
+		config.Auth.SessionLastSeenInterval,
+	)
+	issueSessionService := services.NewIssueSessionService(sessionDAO, userDAO, generateTokenService, config.Auth.RefreshTokenTTL)
+	refreshTokenService := services.NewRefreshTokenService(sessionDAO, generateTokenService, config.Auth.RefreshTokenTTL)
 
-	getUserService := services.NewGetUserService(userDAO)
-	updateEmailService := services.NewUpdateEmailService(userDAO, introspectTokenService)
-	loginService := services.NewLoginService(userDAO, generateTokenService)
-	registerService := services.NewRegisterService(userDAO, generateTokenService)
+	sendVerificationEmailService := services.NewSendVerificationEmailService(
+		actionTokenDAO,
+		mailer,
+		config.App.FrontendURL+"/email/verify",
+		config.Auth.EmailVerificationTTL,
+	)
+
+	getUserService := services.NewGetUserService(userDAO, config.EmailParser)
+	getCurrentUserService := services.NewGetCurrentUserService(userDAO)
+	usernameAvailableService := services.NewUsernameAvailableService(userDAO, config.UsernamePolicy)
+	updateUserService := services.NewUpdateUserService(userDAO, config.UsernamePolicy)
+	updateEmailService := services.NewUpdateEmailService(userDAO, config.EmailParser, emailDomainPolicy, sendVerificationEmailService, mailer, tasks)
+	verifyEmailService := services.NewVerifyEmailService(userDAO, actionTokenDAO)
+	loginProtectionService := services.NewLoginProtectionService(loginAttemptDAO, config.EmailParser, services.LoginProtectionOptions{
+		AccountFreeAttempts: config.Auth.LoginProtection.AccountFreeAttempts,
+		IPFreeAttempts:      config.Auth.LoginProtection.IPFreeAttempts,
+		BaseDelay:           config.Auth.LoginProtection.BaseDelay,
+		MaxDelay:            config.Auth.LoginProtection.MaxDelay,
+		LockoutThreshold:    config.Auth.LoginProtection.LockoutThreshold,
+		LockoutDuration:     config.Auth.LoginProtection.LockoutDuration,
+		FailureWindow:       config.Auth.LoginProtection.FailureWindow,
+	})
+	mfaChallengeService := services.NewMFAChallengeService(mfaDAO, actionTokenDAO, config.Auth.MFA.ChallengeTTL)
+	loginService := services.NewLoginService(
+		userDAO,
+		config.EmailParser,
+		config.PasswordHasher,
+		loginProtectionService,
+		mfaChallengeService,
+		issueSessionService,
+		config.Auth.RequireVerifiedEmail,
+	)
+	loginMFAService := services.NewLoginMFAService(
+		userDAO,
+		mfaDAO,
+		actionTokenDAO,
+		loginProtectionService,
+		issueSessionService,
+		config.Auth.MFA.TOTPSkew,
+	)
+	enrollTOTPService := services.NewEnrollTOTPService(userDAO, mfaDAO, config.App.Name)
+	confirmTOTPService := services.NewConfirmTOTPService(mfaDAO, config.Auth.MFA.TOTPSkew)
+	disableMFAService := services.NewDisableMFAService(
+		userDAO,
+		config.PasswordHasher,
+		mfaDAO,
+		loginProtectionService,
+		config.Auth.MFA.TOTPSkew,
+	)
+	beginWebAuthnRegistrationService := services.NewBeginWebAuthnRegistrationService(
+		userDAO,
+		webAuthnCredentialDAO,
+		actionTokenDAO,
+		relyingParty,
+		config.Auth.WebAuthn.ChallengeTTL,
+	)
+	finishWebAuthnRegistrationService := services.NewFinishWebAuthnRegistrationService(webAuthnCredentialDAO, actionTokenDAO, relyingParty)
+	beginWebAuthnLoginService := services.NewBeginWebAuthnLoginService(actionTokenDAO, relyingParty, config.Auth.WebAuthn.ChallengeTTL)
+	finishWebAuthnLoginService := services.NewFinishWebAuthnLoginService(
+		userDAO,
+		webAuthnCredentialDAO,
+		actionTokenDAO,
+		relyingParty,
+		loginProtectionService,
+		issueSessionService,
+		config.Auth.RequireVerifiedEmail,
+	)
+	listWebAuthnCredentialsService := services.NewListWebAuthnCredentialsService(webAuthnCredentialDAO)
+	renameWebAuthnCredentialService := services.NewRenameWebAuthnCredentialService(webAuthnCredentialDAO)
+	deleteWebAuthnCredentialService := services.NewDeleteWebAuthnCredentialService(webAuthnCredentialDAO)
+	requestLoginLinkService := services.NewRequestLoginLinkService(
+		userDAO,
+		config.EmailParser,
+		actionTokenDAO,
+		mailer,
+		tasks,
+		config.App.FrontendURL+"/login/link",
+		config.Auth.LoginLinkTTL,
+	)
+	verifyLoginLinkService := services.NewVerifyLoginLinkService(userDAO, actionTokenDAO, mfaChallengeService, issueSessionService)
+	registerService := services.NewRegisterService(
+		userDAO,
+		config.EmailParser,
+		emailDomainPolicy,
+		config.UsernamePolicy,
+		config.PasswordPolicy,
+		issueSessionService,
+		sendVerificationEmailService,
+		tasks,
+	)
+	getJWKSService := services.NewGetJWKSService(config.Keys)
+	logoutService := services.NewLogoutService(revocationDAO, sessionDAO)
+	logoutAllService := services.NewLogoutAllService(revocationDAO, sessionDAO, config.Auth.TokenTTL)
+	listSessionsService := services.NewListSessionsService(sessionDAO)
+	revokeSessionService := services.NewRevokeSessionService(sessionDAO)
+	forgotPasswordService := services.NewForgotPasswordService(
+		userDAO,
+		config.EmailParser,
+		actionTokenDAO,
+		mailer,
+		tasks,
+		config.App.FrontendURL+"/password/reset",
+		config.Auth.PasswordResetTTL,
+	)
+	resetPasswordService := services.NewResetPasswordService(userDAO, config.PasswordPolicy, actionTokenDAO, logoutAllService)
+	changePasswordService := services.NewChangePasswordService(
+		userDAO,
+		config.PasswordHasher,
+		config.PasswordPolicy,
+		sessionDAO,
+		loginProtectionService,
+	)
+	addEmailDomainService := services.NewAddEmailDomainService(emailDomainDAO)
+	requestUserDeletionService := services.NewRequestUserDeletionService(
+		userDAO,
+		config.PasswordHasher,
+		mfaDAO,
+		logoutAllService,
+		loginProtectionService,
+		mailer,
+		tasks,
+		config.Auth.Deletion.GracePeriod,
+		config.Auth.MFA.TOTPSkew,
+	)
+	purgeDeletedUsersService := services.NewPurgeDeletedUsersService(
+		userDAO,
+		sessionDAO,
+		revocationDAO,
+		actionTokenDAO,
+		mfaDAO,
+		webAuthnCredentialDAO,
+		loginProtectionService,
+	)
+	go purgeDeletedUsers(logger, purgeDeletedUsersService, config.Auth.Deletion.PurgeInterval)
+	introspectService := services.NewIntrospectTokenService(introspectTokenService, config.Auth.Introspection.Clients.OAuthClients())
 
 	getUserHandler := handlers.NewGetUserHandler(getUserService)
+	getCurrentUserHandler := handlers.NewGetCurrentUserHandler(getCurrentUserService)
+	usernameAvailableHandler := handlers.NewUsernameAvailableHandler(usernameAvailableService)
+	updateUserHandler := handlers.NewUpdateUserHandler(updateUserService)
+	deleteUserHandler := handlers.NewDeleteUserHandler(requestUserDeletionService)
 	updateEmailHandler := handlers.NewUpdateEmailHandler(updateEmailService)
+	verifyEmailHandler := handlers.NewVerifyEmailHandler(verifyEmailService)
 	loginHandler := handlers.NewLoginHandler(loginService)
 	registerHandler := handlers.NewRegisterHandler(registerService)
+	jwksHandler := handlers.NewJWKSHandler(getJWKSService, config.Auth.JWKSCacheTTL)
+	refreshTokenHandler := handlers.NewRefreshTokenHandler(refreshTokenService)
+	logoutHandler := handlers.NewLogoutHandler(logoutService)
+	logoutAllHandler := handlers.NewLogoutAllHandler(logoutAllService)
+	listSessionsHandler := handlers.NewListSessionsHandler(listSessionsService)
+	revokeSessionHandler := handlers.NewRevokeSessionHandler(revokeSessionService)
+	forgotPasswordHandler := handlers.NewForgotPasswordHandler(forgotPasswordService)
+	resetPasswordHandler := handlers.NewResetPasswordHandler(resetPasswordService)
+	changePasswordHandler := handlers.NewChangePasswordHandler(changePasswordService)
+	introspectHandler := handlers.NewIntrospectHandler(introspectService, config.App.Name, config.Auth.Introspection.CacheTTL)
+	unlockLoginHandler := handlers.NewUnlockLoginHandler(loginProtectionService)
+	addEmailDomainHandler := handlers.NewAddEmailDomainHandler(addEmailDomainService)
+	loginMFAHandler := handlers.NewLoginMFAHandler(loginMFAService)
+	enrollTOTPHandler := handlers.NewEnrollTOTPHandler(enrollTOTPService)
+	confirmTOTPHandler := handlers.NewConfirmTOTPHandler(confirmTOTPService)
+	disableMFAHandler := handlers.NewDisableMFAHandler(disableMFAService)
+	cookieOptions := handlers.CookieOptions{Secure: config.App.Cookies.Secure, SameSite: config.App.Cookies.SameSiteMode()}
+	requestLoginLinkHandler := handlers.NewRequestLoginLinkHandler(requestLoginLinkService, config.Auth.LoginLinkTTL, cookieOptions)
+	verifyLoginLinkHandler := handlers.NewVerifyLoginLinkHandler(verifyLoginLinkService, cookieOptions)
+	beginWebAuthnRegistrationHandler := handlers.NewBeginWebAuthnRegistrationHandler(beginWebAuthnRegistrationService)
+	finishWebAuthnRegistrationHandler := handlers.NewFinishWebAuthnRegistrationHandler(finishWebAuthnRegistrationService)
+	beginWebAuthnLoginHandler := handlers.NewBeginWebAuthnLoginHandler(beginWebAuthnLoginService)
+	finishWebAuthnLoginHandler := handlers.NewFinishWebAuthnLoginHandler(finishWebAuthnLoginService)
+	listWebAuthnCredentialsHandler := handlers.NewListWebAuthnCredentialsHandler(listWebAuthnCredentialsService)
+	renameWebAuthnCredentialHandler := handlers.NewRenameWebAuthnCredentialHandler(renameWebAuthnCredentialService)
+	deleteWebAuthnCredentialHandler := handlers.NewDeleteWebAuthnCredentialHandler(deleteWebAuthnCredentialService)
+
+	// Routes registered on this group require a valid token.
+	authenticatedAPI := router.Group(
+		"",
+		api.Authenticate(introspectTokenService, config.App.Name),
+		rateLimit(rateLimitDAO, "authenticated"),
+	)
+	// Routes registered on this group are reserved to administration tools.
+	adminAPI := router.Group(
+		"/admin",
+		api.AuthenticateClient(config.Auth.Admin.Clients.OAuthClients(), config.App.Name),
+		rateLimit(rateLimitDAO, "admin"),
+	)
 
-	routerAPI.GET("/user", getUserHandler.Handle)
-	routerAPI.PUT("/user/email", updateEmailHandler.Handle)
-	routerAPI.POST("/user", loginHandler.Handle)
-	routerAPI.PUT("/user", registerHandler.Handle)
+	routerAPI.GET("/user", rateLimit(rateLimitDAO, "get_user"), getUserHandler.Handle)
+	authenticatedAPI.GET("/user/me", getCurrentUserHandler.Handle)
+	routerAPI.GET("/user/username/available", rateLimit(rateLimitDAO, "username_available"), usernameAvailableHandler.Handle)
+	authenticatedAPI.PUT("/user/email", updateEmailHandler.Handle)
+	routerAPI.POST("/user/email/verify", rateLimit(rateLimitDAO, "action_token"), verifyEmailHandler.Handle)
+	routerAPI.POST("/user", rateLimit(rateLimitDAO, "login"), loginHandler.Handle)
+	routerAPI.PUT("/user", rateLimit(rateLimitDAO, "register"), registerHandler.Handle)
+	authenticatedAPI.PATCH("/user", updateUserHandler.Handle)
+	authenticatedAPI.DELETE("/user", deleteUserHandler.Handle)
+	routerAPI.GET("/.well-known/jwks.json", jwksHandler.Handle)
+	routerAPI.POST("/token/refresh", rateLimit(rateLimitDAO, "token_refresh"), refreshTokenHandler.Handle)
+	authenticatedAPI.POST("/logout", logoutHandler.Handle)
+	authenticatedAPI.POST("/logout/all", logoutAllHandler.Handle)
+	authenticatedAPI.GET("/user/sessions", listSessionsHandler.Handle)
+	authenticatedAPI.DELETE("/user/sessions/:id", revokeSessionHandler.Handle)
+	routerAPI.POST("/oauth/introspect", introspectHandler.Handle)
+	routerAPI.POST("/user/password/forgot", rateLimit(rateLimitDAO, "password_forgot"), forgotPasswordHandler.Handle)
+	routerAPI.POST("/user/password/reset", rateLimit(rateLimitDAO, "action_token"), resetPasswordHandler.Handle)
+	authenticatedAPI.PUT("/user/password", changePasswordHandler.Handle)
+	routerAPI.POST("/user/login/mfa", rateLimit(rateLimitDAO, "login"), loginMFAHandler.Handle)
+	authenticatedAPI.POST("/user/mfa/totp", enrollTOTPHandler.Handle)
+	authenticatedAPI.POST("/user/mfa/totp/confirm", confirmTOTPHandler.Handle)
+	authenticatedAPI.DELETE("/user/mfa", disableMFAHandler.Handle)
+	routerAPI.POST("/user/login/link", rateLimit(rateLimitDAO, "login_link"), requestLoginLinkHandler.Handle)
+	routerAPI.POST("/user/login/link/verify", rateLimit(rateLimitDAO, "action_token"), verifyLoginLinkHandler.Handle)
+	routerAPI.POST("/user/login/webauthn/begin", rateLimit(rateLimitDAO, "login"), beginWebAuthnLoginHandler.Handle)
+	routerAPI.POST("/user/login/webauthn/finish", rateLimit(rateLimitDAO, "login"), finishWebAuthnLoginHandler.Handle)
+	authenticatedAPI.POST("/user/webauthn/register/begin", beginWebAuthnRegistrationHandler.Handle)
+	authenticatedAPI.POST("/user/webauthn/register/finish", finishWebAuthnRegistrationHandler.Handle)
+	authenticatedAPI.GET("/user/webauthn/credentials", listWebAuthnCredentialsHandler.Handle)
+	authenticatedAPI.PATCH("/user/webauthn/credentials/:id", renameWebAuthnCredentialHandler.Handle)
+	authenticatedAPI.DELETE("/user/webauthn/credentials/:id", deleteWebAuthnCredentialHandler.Handle)
+	adminAPI.POST("/login/unlock", unlockLoginHandler.Handle)
+	adminAPI.POST("/email-domains", addEmailDomainHandler.Handle)
 
 	if err := router.Run(fmt.Sprintf(":%d", config.App.Port)); err != nil {
 		logger.Fatal().Err(err).Msg("a fatal error occurred while running API, and the server had to shut down")
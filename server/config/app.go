@@ -2,7 +2,10 @@ package config
 
 import (
 	_ "embed"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 )
 
 //go:embed app.yml
@@ -18,6 +21,38 @@ type appConfig struct {
 	Name      string `yaml:"name"`
 	Port      int    `yaml:"port"`
 	ProjectID string `yaml:"project_id"`
+	// FrontendURL is the base URL of the web application, used to build the links sent by email.
+	FrontendURL string        `yaml:"frontend_url"`
+	Cookies     cookiesConfig `yaml:"cookies"`
+}
+
+type cookiesConfig struct {
+	// Secure cookies are only sent over HTTPS.
+	Secure bool `yaml:"secure"`
+	// SameSite is lax, strict or none.
+	SameSite string `yaml:"same_site"`
+}
+
+// SameSiteMode returns the SameSite attribute of cookies.
+func (cfg *cookiesConfig) SameSiteMode() http.SameSite {
+	switch cfg.SameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// FrontendOrigin returns the web origin of the frontend, like https://example.com.
+func (cfg *appConfig) FrontendOrigin() string {
+	frontendURL, err := url.Parse(cfg.FrontendURL)
+	if err != nil || frontendURL.Scheme == "" || frontendURL.Host == "" {
+		return ""
+	}
+
+	return frontendURL.Scheme + "://" + frontendURL.Host
 }
 
 var App *appConfig
@@ -28,5 +63,11 @@ func init() {
 		log.Fatalf("error loading app configuration: %v\n", err)
 	}
 
+	switch cfg.Cookies.SameSite {
+	case "lax", "strict", "none":
+	default:
+		log.Fatalf("error loading app configuration: %v\n", fmt.Errorf("invalid cookies same_site %q", cfg.Cookies.SameSite))
+	}
+
 	App = cfg
 }
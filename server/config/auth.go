@@ -0,0 +1,332 @@
+package config
+
+import (
+	_ "embed"
+	"github.com/samber/lo"
+	"log"
+	"net/url"
+	"strings"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/hasher"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"technical-interview/pkg/webauthn"
+	"time"
+)
+
+//go:embed auth.yml
+var authFile []byte
+
+//go:embed auth-dev.yml
+var authDevFile []byte
+
+//go:embed auth-prod.yml
+var authProdFile []byte
+
+type authConfig struct {
+	// TokenTTL is the lifetime of access tokens.
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// RefreshTokenTTL is the lifetime of a session, renewed every time its refresh token is used.
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	// JWKSCacheTTL is how long clients may cache the published keys. It sets the minimum delay between publishing
+	// a key and using it to sign tokens.
+	JWKSCacheTTL time.Duration `yaml:"jwks_cache_ttl"`
+	// PasswordResetTTL is the lifetime of password reset links.
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl"`
+	// EmailVerificationTTL is the lifetime of email verification links.
+	EmailVerificationTTL time.Duration `yaml:"email_verification_ttl"`
+	// LoginLinkTTL is the lifetime of passwordless login links.
+	LoginLinkTTL time.Duration `yaml:"login_link_ttl"`
+	// SessionLastSeenInterval is the minimum delay between two updates of the last use of a session.
+	SessionLastSeenInterval time.Duration `yaml:"session_last_seen_interval"`
+	// RequireVerifiedEmail prevents users with an unverified email from logging in.
+	RequireVerifiedEmail bool                  `yaml:"require_verified_email"`
+	JWT                  jwtConfig             `yaml:"jwt"`
+	Keys                 keysConfig            `yaml:"keys"`
+	Introspection        introspectionConfig   `yaml:"introspection"`
+	PasswordHashing      passwordHashingConfig `yaml:"password_hashing"`
+	PasswordPolicy       passwordPolicyConfig  `yaml:"password_policy"`
+	UsernamePolicy       usernamePolicyConfig  `yaml:"username_policy"`
+	Email                emailConfig           `yaml:"email"`
+	LoginProtection      loginProtectionConfig `yaml:"login_protection"`
+	MFA                  mfaConfig             `yaml:"mfa"`
+	WebAuthn             webAuthnConfig        `yaml:"webauthn"`
+	Deletion             deletionConfig        `yaml:"deletion"`
+	// Admin lists the clients allowed to use the administration routes.
+	Admin adminConfig `yaml:"admin"`
+}
+
+type passwordPolicyConfig struct {
+	MinLength  int     `yaml:"min_length"`
+	MaxLength  int     `yaml:"max_length"`
+	MinEntropy float64 `yaml:"min_entropy"`
+	// BreachedPasswordsFile lists the SHA-1 hashes of breached passwords. Optional.
+	BreachedPasswordsFile string `yaml:"breached_passwords_file"`
+}
+
+// load builds the password policy, reading the breached passwords file if any.
+func (cfg *passwordPolicyConfig) load() (policy.PasswordPolicy, error) {
+	var breached policy.BreachedPasswords
+	if cfg.BreachedPasswordsFile != "" {
+		var err error
+		if breached, err = policy.NewFileBreachedPasswords(cfg.BreachedPasswordsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	options := policy.PasswordOptions{MinLength: cfg.MinLength, MaxLength: cfg.MaxLength, MinEntropy: cfg.MinEntropy}
+	return policy.NewPasswordPolicy(options, breached), nil
+}
+
+type usernamePolicyConfig struct {
+	MinLength int `yaml:"min_length"`
+	MaxLength int `yaml:"max_length"`
+	// Charset is the content of a regular expression character class, matched against the lower case username.
+	Charset  string   `yaml:"charset"`
+	Reserved []string `yaml:"reserved"`
+}
+
+// Options returns the options of the username policy.
+func (cfg *usernamePolicyConfig) Options() policy.UsernameOptions {
+	return policy.UsernameOptions{
+		MinLength: cfg.MinLength,
+		MaxLength: cfg.MaxLength,
+		Charset:   cfg.Charset,
+		Reserved:  cfg.Reserved,
+	}
+}
+
+type emailConfig struct {
+	// Providers lists how mail providers deliver addresses, so aliases of the same mailbox are treated as one.
+	Providers []struct {
+		Domains         []string `yaml:"domains"`
+		CanonicalDomain string   `yaml:"canonical_domain"`
+		IgnoreDots      bool     `yaml:"ignore_dots"`
+		TagSeparators   string   `yaml:"tag_separators"`
+	} `yaml:"providers"`
+	Domains emailDomainsConfig `yaml:"domains"`
+}
+
+type emailDomainsConfig struct {
+	// AllowlistOnly only accepts allowed domains, for invite-only deployments.
+	AllowlistOnly bool     `yaml:"allowlist_only"`
+	Allowed       []string `yaml:"allowed"`
+	// DenylistFile lists domains to deny, in addition to the bundled list of disposable mail services. Optional.
+	DenylistFile string `yaml:"denylist_file"`
+}
+
+// Options returns the options of the email domain policy.
+func (cfg *emailDomainsConfig) Options() policy.EmailDomainOptions {
+	return policy.EmailDomainOptions{
+		AllowlistOnly: cfg.AllowlistOnly,
+		Allowed:       lo.Compact(cfg.Allowed),
+		DenylistFile:  cfg.DenylistFile,
+	}
+}
+
+// Options returns the options of the email parser.
+func (cfg *emailConfig) Options() emailaddr.Options {
+	rules := make([]emailaddr.Rule, len(cfg.Providers))
+	for i, provider := range cfg.Providers {
+		rules[i] = emailaddr.Rule{
+			Domains:         provider.Domains,
+			CanonicalDomain: provider.CanonicalDomain,
+			IgnoreDots:      provider.IgnoreDots,
+			TagSeparators:   provider.TagSeparators,
+		}
+	}
+
+	return emailaddr.Options{Rules: rules}
+}
+
+type passwordHashingConfig struct {
+	Algorithm string `yaml:"algorithm"`
+	Argon2id  struct {
+		Memory      uint32 `yaml:"memory"`
+		Iterations  uint32 `yaml:"iterations"`
+		Parallelism uint8  `yaml:"parallelism"`
+		SaltLength  uint32 `yaml:"salt_length"`
+		KeyLength   uint32 `yaml:"key_length"`
+	} `yaml:"argon2id"`
+	Bcrypt struct {
+		Cost int `yaml:"cost"`
+	} `yaml:"bcrypt"`
+	Pepper   string `yaml:"pepper"`
+	PepperID string `yaml:"pepper_id"`
+}
+
+// Options returns the options of the password hasher.
+func (cfg *passwordHashingConfig) Options() hasher.Options {
+	return hasher.Options{
+		Algorithm: cfg.Algorithm,
+		Argon2id: hasher.Argon2idParams{
+			Memory:      cfg.Argon2id.Memory,
+			Iterations:  cfg.Argon2id.Iterations,
+			Parallelism: cfg.Argon2id.Parallelism,
+			SaltLength:  cfg.Argon2id.SaltLength,
+			KeyLength:   cfg.Argon2id.KeyLength,
+		},
+		BcryptCost: cfg.Bcrypt.Cost,
+		Pepper:     cfg.Pepper,
+		PepperID:   cfg.PepperID,
+	}
+}
+
+type introspectionConfig struct {
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	Clients  clientsConfig `yaml:"clients"`
+}
+
+type adminConfig struct {
+	Clients clientsConfig `yaml:"clients"`
+}
+
+type clientsConfig []struct {
+	ID         string `yaml:"id"`
+	SecretHash string `yaml:"secret_hash"`
+}
+
+// OAuthClients returns the configured clients. Clients without an ID or a secret are ignored.
+func (cfg clientsConfig) OAuthClients() []models.OAuthClient {
+	output := make([]models.OAuthClient, 0, len(cfg))
+
+	for _, client := range cfg {
+		if client.ID == "" || client.SecretHash == "" {
+			continue
+		}
+
+		output = append(output, models.OAuthClient{ID: client.ID, SecretHash: strings.ToLower(client.SecretHash)})
+	}
+
+	return output
+}
+
+type mfaConfig struct {
+	// ChallengeTTL is how long users have to enter their second factor after their password.
+	ChallengeTTL time.Duration `yaml:"challenge_ttl"`
+	// TOTPSkew is the number of time steps a TOTP code is accepted early or late.
+	TOTPSkew int `yaml:"totp_skew"`
+}
+
+type deletionConfig struct {
+	// GracePeriod is how long users have to cancel the deletion of their account, by logging in.
+	GracePeriod time.Duration `yaml:"grace_period"`
+	// PurgeInterval is the delay between two runs of the job deleting the accounts whose grace period has ended.
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+type webAuthnConfig struct {
+	// RPID is the domain passkeys are bound to. Defaults to the host of the frontend URL.
+	RPID string `yaml:"rp_id"`
+	// Origins are the web origins allowed to use passkeys. Defaults to the origin of the frontend URL.
+	Origins []string `yaml:"origins"`
+	// Timeout is the time given to users to interact with their authenticator.
+	Timeout time.Duration `yaml:"timeout"`
+	// ChallengeTTL is how long a ceremony can take before its challenge expires.
+	ChallengeTTL     time.Duration `yaml:"challenge_ttl"`
+	UserVerification string        `yaml:"user_verification"`
+}
+
+// Options returns the options of the relying party, completed from the frontend URL.
+func (cfg *webAuthnConfig) Options() (webauthn.Options, error) {
+	frontendURL, err := url.Parse(App.FrontendURL)
+	if err != nil {
+		return webauthn.Options{}, err
+	}
+
+	options := webauthn.Options{
+		RPID:             lo.Ternary(cfg.RPID != "", cfg.RPID, frontendURL.Hostname()),
+		RPName:           App.Name,
+		Origins:          lo.Compact(cfg.Origins),
+		Timeout:          cfg.Timeout,
+		UserVerification: cfg.UserVerification,
+	}
+	if len(options.Origins) == 0 {
+		options.Origins = []string{App.FrontendOrigin()}
+	}
+
+	return options, nil
+}
+
+const (
+	LoginAttemptStoreMemory    = "memory"
+	LoginAttemptStoreFirestore = "firestore"
+)
+
+type loginProtectionConfig struct {
+	// Store selects where failure counters are kept: memory or firestore.
+	Store               string        `yaml:"store"`
+	AccountFreeAttempts int           `yaml:"account_free_attempts"`
+	IPFreeAttempts      int           `yaml:"ip_free_attempts"`
+	BaseDelay           time.Duration `yaml:"base_delay"`
+	MaxDelay            time.Duration `yaml:"max_delay"`
+	LockoutThreshold    int           `yaml:"lockout_threshold"`
+	LockoutDuration     time.Duration `yaml:"lockout_duration"`
+	FailureWindow       time.Duration `yaml:"failure_window"`
+}
+
+type jwtConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+var Auth *authConfig
+
+// Keys contains the keys used to sign and verify tokens.
+var Keys *models.KeySet
+
+// PasswordHasher hashes and verifies user passwords.
+var PasswordHasher hasher.PasswordHasher
+
+// PasswordPolicy rejects weak passwords.
+var PasswordPolicy policy.PasswordPolicy
+
+// UsernamePolicy rejects invalid and reserved usernames.
+var UsernamePolicy policy.UsernamePolicy
+
+// EmailParser validates and normalizes the emails of users.
+var EmailParser emailaddr.Parser
+
+func init() {
+	cfg := new(authConfig)
+	if err := loadEnv(EnvLoader{DefaultENV: authFile, ProdENV: authProdFile, DevENV: authDevFile}, cfg); err != nil {
+		log.Fatalf("error loading auth configuration: %v\n", err)
+	}
+
+	keys, err := loadKeys(cfg.Keys)
+	if err != nil {
+		log.Fatalf("error loading signing keys: %v\n", err)
+	}
+
+	passwordHasher, err := hasher.NewPasswordHasher(cfg.PasswordHashing.Options())
+	if err != nil {
+		log.Fatalf("error loading password hasher: %v\n", err)
+	}
+
+	passwordPolicy, err := cfg.PasswordPolicy.load()
+	if err != nil {
+		log.Fatalf("error loading password policy: %v\n", err)
+	}
+
+	usernamePolicy, err := policy.NewUsernamePolicy(cfg.UsernamePolicy.Options())
+	if err != nil {
+		log.Fatalf("error loading username policy: %v\n", err)
+	}
+
+	// Token revocations are deleted with the account, so tokens issued before the request must have expired.
+	if cfg.Deletion.GracePeriod <= cfg.TokenTTL {
+		log.Fatalf("error loading auth configuration: deletion grace period must be longer than the token TTL\n")
+	}
+	// A ticker panics on a non positive interval, which would stop the server.
+	if cfg.Deletion.PurgeInterval <= 0 {
+		log.Fatalf("error loading auth configuration: deletion purge interval must be positive\n")
+	}
+
+	Auth = cfg
+	Keys = keys
+	PasswordHasher = passwordHasher
+	PasswordPolicy = passwordPolicy
+	UsernamePolicy = usernamePolicy
+	EmailParser = emailaddr.NewParser(cfg.Email.Options())
+}
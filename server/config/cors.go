@@ -8,11 +8,17 @@ import (
 var Cors = cors.Config{
 	AllowOrigins: []string{"*"},
 	AllowMethods: cors.DefaultConfig().AllowMethods,
-	AllowHeaders: []string{"Origin", "Content-Type", "Authorization"},
+	AllowHeaders: []string{"Origin", "Content-Type", "Authorization", "If-Match"},
 	ExposeHeaders: []string{
 		"Content-Type",
 		"Content-Length",
 		"Access-Control-Allow-Origin",
+		"ETag",
+		"RateLimit-Policy",
+		"RateLimit-Limit",
+		"RateLimit-Remaining",
+		"RateLimit-Reset",
+		"Retry-After",
 	},
 	AllowCredentials: false,
 	MaxAge:           12 * time.Hour,
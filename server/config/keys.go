@@ -0,0 +1,192 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"technical-interview/pkg/models"
+)
+
+const ephemeralKeyID = "ephemeral"
+
+type keyConfig struct {
+	ID string `yaml:"id"`
+	// PrivateKey is either a PEM encoded PKCS #8 key, or the base64 encoded 32 bytes seed of the key.
+	PrivateKey string `yaml:"private_key"`
+	// PublicKey is either a PEM encoded PKIX key, or the base64 encoded raw public key. It is ignored if a private
+	// key is set.
+	PublicKey string `yaml:"public_key"`
+}
+
+type keysConfig struct {
+	// Active is the ID of the key used to sign new tokens.
+	Active string `yaml:"active"`
+	// Dir is a directory containing one <kid>.pem file per key. Keys that are no longer used for signing only need
+	// their public part.
+	Dir string `yaml:"dir"`
+	// List declares keys inline, usually through environment variables.
+	List []keyConfig `yaml:"list"`
+	// Ephemeral generates a random signing key when no active key is configured.
+	Ephemeral bool `yaml:"ephemeral"`
+}
+
+// loadKeys builds the key set from the configuration. To rotate keys without invalidating issued tokens, publish the
+// new key first, then switch the active ID, and only remove the old key once the tokens it signed have expired.
+func loadKeys(cfg keysConfig) (*models.KeySet, error) {
+	set := &models.KeySet{ActiveID: cfg.Active}
+
+	add := func(key *models.SigningKey) error {
+		if set.Find(key.ID) != nil {
+			return fmt.Errorf("duplicate key id %q", key.ID)
+		}
+
+		set.Keys = append(set.Keys, *key)
+		return nil
+	}
+
+	if cfg.Dir != "" {
+		files, err := filepath.Glob(filepath.Join(cfg.Dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+
+			key, err := parseKey(strings.TrimSuffix(filepath.Base(file), ".pem"), "", string(content))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+
+			if err := add(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, entry := range cfg.List {
+		key, err := parseKey(entry.ID, entry.PrivateKey, entry.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", entry.ID, err)
+		}
+
+		if err := add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if set.ActiveID == "" && cfg.Ephemeral {
+		publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		set.ActiveID = ephemeralKeyID
+		if err := add(&models.SigningKey{ID: ephemeralKeyID, PublicKey: publicKey, PrivateKey: privateKey}); err != nil {
+			return nil, err
+		}
+	}
+
+	if set.Active() == nil {
+		return nil, fmt.Errorf("no private key available for active key id %q", set.ActiveID)
+	}
+
+	return set, nil
+}
+
+// parseKey reads a key from its textual representation. The private key takes precedence over the public one.
+func parseKey(id, privateKey, publicKey string) (*models.SigningKey, error) {
+	if id == "" {
+		return nil, errors.New("missing key id")
+	}
+
+	privateKey = strings.TrimSpace(privateKey)
+	publicKey = strings.TrimSpace(publicKey)
+
+	// The private key may have been given through the public key field, or through a file.
+	if strings.Contains(publicKey, "PRIVATE KEY") {
+		privateKey, publicKey = publicKey, ""
+	}
+
+	if privateKey != "" {
+		key, err := parsePrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.SigningKey{ID: id, PrivateKey: key, PublicKey: key.Public().(ed25519.PublicKey)}, nil
+	}
+
+	if publicKey != "" {
+		key, err := parsePublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.SigningKey{ID: id, PublicKey: key}, nil
+	}
+
+	return nil, errors.New("missing key material")
+}
+
+func parsePrivateKey(raw string) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode([]byte(raw)); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+
+		return edKey, nil
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid seed size %d", len(seed))
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func parsePublicKey(raw string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(raw)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+
+		return edKey, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size %d", len(key))
+	}
+
+	return key, nil
+}
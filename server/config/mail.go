@@ -0,0 +1,46 @@
+package config
+
+import (
+	_ "embed"
+	"log"
+)
+
+//go:embed mail.yml
+var mailFile []byte
+
+//go:embed mail-dev.yml
+var mailDevFile []byte
+
+//go:embed mail-prod.yml
+var mailProdFile []byte
+
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+	MailDriverSMTP = "smtp"
+)
+
+type mailConfig struct {
+	From string `yaml:"from"`
+	// Driver selects how emails are sent: log, file or smtp.
+	Driver string `yaml:"driver"`
+	// Dir is the output directory of the file driver.
+	Dir  string `yaml:"dir"`
+	SMTP struct {
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"smtp"`
+}
+
+var Mail *mailConfig
+
+func init() {
+	cfg := new(mailConfig)
+	if err := loadEnv(EnvLoader{DefaultENV: mailFile, ProdENV: mailProdFile, DevENV: mailDevFile}, cfg); err != nil {
+		log.Fatalf("error loading mail configuration: %v\n", err)
+	}
+
+	Mail = cfg
+}
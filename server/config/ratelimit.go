@@ -0,0 +1,77 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"time"
+)
+
+//go:embed ratelimit.yml
+var rateLimitFile []byte
+
+//go:embed ratelimit-dev.yml
+var rateLimitDevFile []byte
+
+//go:embed ratelimit-prod.yml
+var rateLimitProdFile []byte
+
+const (
+	RateLimitStoreMemory    = "memory"
+	RateLimitStoreFirestore = "firestore"
+)
+
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyUser   = "user"
+	RateLimitKeyClient = "client"
+)
+
+type rateLimitRuleConfig struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+	// Key is what requests are counted for: ip, user or client.
+	Key string `yaml:"key"`
+}
+
+type rateLimitConfig struct {
+	// Store selects where counters are kept: memory or firestore.
+	Store string `yaml:"store"`
+	// Rules are referenced by name by the routes they apply to.
+	Rules map[string]rateLimitRuleConfig `yaml:"rules"`
+}
+
+func (cfg *rateLimitConfig) validate() error {
+	for name, rule := range cfg.Rules {
+		// A limit below one would deny every request, and leave no time at which to retry.
+		if rule.Limit < 1 {
+			return fmt.Errorf("rule %s: limit must be at least 1", name)
+		}
+		if rule.Window <= 0 {
+			return fmt.Errorf("rule %s: window must be positive", name)
+		}
+
+		switch rule.Key {
+		case RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyClient:
+		default:
+			return fmt.Errorf("rule %s: unknown key %q", name, rule.Key)
+		}
+	}
+
+	return nil
+}
+
+var RateLimit *rateLimitConfig
+
+func init() {
+	cfg := new(rateLimitConfig)
+	if err := loadEnv(EnvLoader{DefaultENV: rateLimitFile, ProdENV: rateLimitProdFile, DevENV: rateLimitDevFile}, cfg); err != nil {
+		log.Fatalf("error loading rate limit configuration: %v\n", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("invalid rate limit configuration: %v\n", err)
+	}
+
+	RateLimit = cfg
+}
@@ -12,8 +12,10 @@ func loadEnv(files EnvLoader, out interface{}) error {
 		ENV = DevENV
 	}
 
-	for env, file := range files {
-		if env != DefaultENV && env != ENV {
+	// Load defaults first, so environment specific files can override them.
+	for _, env := range []string{DefaultENV, ENV} {
+		file, ok := files[env]
+		if !ok {
 			continue
 		}
 
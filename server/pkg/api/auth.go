@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"time"
+)
+
+const principalKey = "principal"
+
+var (
+	ErrMissingToken = errors.New("missing authentication token")
+	ErrTokenInvalid = errors.New("invalid authentication token")
+)
+
+// Authenticate rejects requests without a valid token, and stores the authenticated user in the request, so handlers
+// can read it with GetPrincipal. Failures are answered with a 401 and an RFC 6750 WWW-Authenticate header.
+func Authenticate(service services.GetTokenStatusService, realm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			c.Header("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", realm))
+			_ = c.AbortWithError(http.StatusUnauthorized, ErrMissingToken)
+			return
+		}
+
+		status, err := service.GetTokenStatus(c, token, time.Now())
+		if err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+
+		if !status.OK {
+			description := tokenErrorDescription(status)
+			c.Header(
+				"WWW-Authenticate",
+				fmt.Sprintf("Bearer realm=%q, error=\"invalid_token\", error_description=%q", realm, description),
+			)
+			_ = c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("%w: %s", ErrTokenInvalid, status.Reason))
+			return
+		}
+
+		c.Set(principalKey, &models.Principal{UserID: status.Token.Payload.ID, Token: status.Token})
+		c.Next()
+	}
+}
+
+// GetPrincipal returns the user authenticated by the Authenticate middleware, or nil if the route is not protected.
+func GetPrincipal(c *gin.Context) *models.Principal {
+	principal, ok := c.Get(principalKey)
+	if !ok {
+		return nil
+	}
+
+	return principal.(*models.Principal)
+}
+
+// bearerToken extracts the token from an Authorization header. Tokens sent without a scheme are accepted as well, as
+// it is how older clients send them.
+func bearerToken(header string) string {
+	header = strings.TrimSpace(header)
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found {
+		return header
+	}
+	if !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func tokenErrorDescription(status *models.TokenIntrospection) string {
+	switch status.Reason {
+	case models.TokenReasonMalformed:
+		return "the token is malformed"
+	case models.TokenReasonUnknownKey, models.TokenReasonInvalidSignature:
+		return "the token signature is invalid"
+	case models.TokenReasonInvalidClaims:
+		return "the token was not issued for this service"
+	case models.TokenReasonRevoked:
+		return "the token has been revoked"
+	case models.TokenReasonNotIssued:
+		return "the token is not valid yet"
+	case models.TokenReasonExpired:
+		return "the token has expired"
+	default:
+		return "the token is invalid"
+	}
+}
@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type getTokenStatusServiceMock map[string]*models.TokenIntrospection
+
+func (mock getTokenStatusServiceMock) GetTokenStatus(_ context.Context, token string, _ time.Time) (*models.TokenIntrospection, error) {
+	if status, ok := mock[token]; ok {
+		return status, nil
+	}
+
+	return &models.TokenIntrospection{Malformed: true, Reason: models.TokenReasonMalformed, TokenRaw: token}, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	service := getTokenStatusServiceMock{
+		"valid-token": {
+			OK:     true,
+			Reason: models.TokenReasonValid,
+			Token:  &models.UserToken{Payload: models.UserTokenPayload{ID: "user-1"}},
+		},
+		"expired-token": {
+			Expired: true,
+			Reason:  models.TokenReasonExpired,
+			Token:   &models.UserToken{Payload: models.UserTokenPayload{ID: "user-1"}},
+		},
+	}
+
+	data := []struct {
+		name string
+
+		authorization string
+
+		expectStatus          int
+		expectWWWAuthenticate string
+	}{
+		{
+			name:          "Success",
+			authorization: "Bearer valid-token",
+			expectStatus:  http.StatusOK,
+		},
+		{
+			name:          "SuccessWithoutScheme",
+			authorization: "valid-token",
+			expectStatus:  http.StatusOK,
+		},
+		{
+			name:                  "MissingToken",
+			expectStatus:          http.StatusUnauthorized,
+			expectWWWAuthenticate: `Bearer realm="test"`,
+		},
+		{
+			name:                  "UnsupportedScheme",
+			authorization:         "Basic dXNlcjpwYXNzd29yZA==",
+			expectStatus:          http.StatusUnauthorized,
+			expectWWWAuthenticate: `Bearer realm="test"`,
+		},
+		{
+			name:                  "ExpiredToken",
+			authorization:         "Bearer expired-token",
+			expectStatus:          http.StatusUnauthorized,
+			expectWWWAuthenticate: `Bearer realm="test", error="invalid_token", error_description="the token has expired"`,
+		},
+		{
+			name:                  "MalformedToken",
+			authorization:         "Bearer garbage",
+			expectStatus:          http.StatusUnauthorized,
+			expectWWWAuthenticate: `Bearer realm="test", error="invalid_token", error_description="the token is malformed"`,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", api.Authenticate(service, "test"), func(c *gin.Context) {
+				require.Equal(t, "user-1", api.GetPrincipal(c).UserID)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if d.authorization != "" {
+				req.Header.Set("Authorization", d.authorization)
+			}
+
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, req)
+
+			require.Equal(t, d.expectStatus, res.Code)
+			require.Equal(t, d.expectWWWAuthenticate, res.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
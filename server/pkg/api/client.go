@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+)
+
+const clientIDKey = "clientID"
+
+// AuthenticateClient rejects requests that don't carry the HTTP Basic credentials of one of the clients. It protects
+// routes meant for other services or administration tools, rather than users.
+func AuthenticateClient(clients []models.OAuthClient, realm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, clientSecret, _ := c.Request.BasicAuth()
+
+		if err := services.AuthenticateClient(clients, clientID, clientSecret); err != nil {
+			c.Header("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+
+		c.Set(clientIDKey, clientID)
+		c.Next()
+	}
+}
+
+// GetClientID returns the client authenticated by the AuthenticateClient middleware.
+func GetClientID(c *gin.Context) string {
+	return c.GetString(clientIDKey)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"technical-interview/pkg/dao"
+	"time"
+)
+
+var (
+	ErrRateLimited = errors.New("rate limit exceeded")
+)
+
+// KeyFunc returns the identity a rate limit is counted for.
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP counts requests per client IP.
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUser counts requests per authenticated user, so users behind the same IP don't share a limit. It must be
+// registered after Authenticate, and falls back to the IP otherwise.
+func KeyByUser(c *gin.Context) string {
+	if principal := GetPrincipal(c); principal != nil {
+		return "user:" + principal.UserID
+	}
+
+	return KeyByIP(c)
+}
+
+// KeyByClient counts requests per API client. It must be registered after AuthenticateClient, and falls back to the
+// IP otherwise, so unauthenticated clients can't pick their own key.
+func KeyByClient(c *gin.Context) string {
+	if clientID := GetClientID(c); clientID != "" {
+		return "client:" + clientID
+	}
+
+	return KeyByIP(c)
+}
+
+type RateLimitRule struct {
+	// Name separates the counters of different rules that use the same key.
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    KeyFunc
+}
+
+// RateLimit rejects requests over the limit of the rule with a 429. Every response carries the RateLimit headers of
+// the IETF draft, and rejections a Retry-After header. If the counters can't be reached, requests are let through.
+func RateLimit(repository dao.RateLimitRepository, rule RateLimitRule) gin.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", rule.Limit, int(rule.Window.Seconds()))
+
+	return func(c *gin.Context) {
+		now := time.Now()
+
+		status, err := repository.Hit(c, rule.Name+":"+rule.Key(c), rule.Limit, rule.Window, now)
+		if err != nil {
+			// Availability matters more than strict limits.
+			_ = c.Error(err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(status.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+		c.Header("RateLimit-Reset", seconds(status.ResetAt.Sub(now)))
+
+		if !status.Allowed {
+			c.Header("Retry-After", seconds(status.RetryAt.Sub(now)))
+			_ = c.AbortWithError(http.StatusTooManyRequests, ErrRateLimited)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// seconds formats a delay as a whole number of seconds, rounded up.
+func seconds(delay time.Duration) string {
+	return strconv.Itoa(int(math.Max(0, math.Ceil(delay.Seconds()))))
+}
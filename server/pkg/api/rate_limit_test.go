@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("ByIP", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/", api.RateLimit(dao.NewMemoryRateLimitRepository(), api.RateLimitRule{
+			Name:   "test",
+			Limit:  2,
+			Window: time.Hour,
+			Key:    api.KeyByIP,
+		}), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		expectStatuses := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+		expectRemaining := []string{"1", "0", "0"}
+
+		for i, expectStatus := range expectStatuses {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			res := httptest.NewRecorder()
+
+			router.ServeHTTP(res, req)
+
+			require.Equal(t, expectStatus, res.Code)
+			require.Equal(t, "2;w=3600", res.Header().Get("RateLimit-Policy"))
+			require.Equal(t, "2", res.Header().Get("RateLimit-Limit"))
+			require.Equal(t, expectRemaining[i], res.Header().Get("RateLimit-Remaining"))
+			require.NotEmpty(t, res.Header().Get("RateLimit-Reset"))
+
+			if expectStatus == http.StatusTooManyRequests {
+				require.NotEmpty(t, res.Header().Get("Retry-After"))
+			} else {
+				require.Empty(t, res.Header().Get("Retry-After"))
+			}
+		}
+
+		// Other IPs have their own limit.
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		res := httptest.NewRecorder()
+
+		router.ServeHTTP(res, req)
+		require.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("ByUser", func(t *testing.T) {
+		repository := dao.NewMemoryRateLimitRepository()
+		rule := api.RateLimitRule{Name: "test", Limit: 1, Window: time.Hour, Key: api.KeyByUser}
+
+		service := getTokenStatusServiceMock{
+			"token-1": {OK: true, Token: &models.UserToken{Payload: models.UserTokenPayload{ID: "user-1"}}},
+			"token-2": {OK: true, Token: &models.UserToken{Payload: models.UserTokenPayload{ID: "user-2"}}},
+		}
+
+		handler := func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		}
+
+		router := gin.New()
+		router.GET("/private", api.Authenticate(service, "test"), api.RateLimit(repository, rule), handler)
+		router.GET("/public", api.RateLimit(repository, rule), handler)
+
+		data := []struct {
+			path          string
+			authorization string
+			expectStatus  int
+		}{
+			{path: "/private", authorization: "token-1", expectStatus: http.StatusOK},
+			{path: "/private", authorization: "token-1", expectStatus: http.StatusTooManyRequests},
+			// Users sharing an IP don't share a limit.
+			{path: "/private", authorization: "token-2", expectStatus: http.StatusOK},
+			// Anonymous requests are counted by IP.
+			{path: "/public", expectStatus: http.StatusOK},
+			{path: "/public", expectStatus: http.StatusTooManyRequests},
+		}
+
+		for _, d := range data {
+			req := httptest.NewRequest(http.MethodGet, d.path, nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("Authorization", d.authorization)
+			res := httptest.NewRecorder()
+
+			router.ServeHTTP(res, req)
+			require.Equal(t, d.expectStatus, res.Code, d.path)
+		}
+	})
+}
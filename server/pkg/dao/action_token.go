@@ -0,0 +1,126 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrActionTokenNotFound = errors.New("action token not found")
+	ErrActionTokenExpired  = errors.New("action token expired")
+	ErrActionTokenUsed     = errors.New("action token already used")
+)
+
+type ActionTokenRepository interface {
+	Create(ctx context.Context, tokenHash string, purpose models.ActionTokenPurpose, userID string, email string, now time.Time, expiresAt time.Time) (*models.ActionToken, error)
+	// Get returns the token if it could be consumed, without consuming it.
+	Get(ctx context.Context, tokenHash string, purpose models.ActionTokenPurpose, now time.Time) (*models.ActionToken, error)
+	// Consume marks the token as used, and returns it. A token can only be consumed once, and only for the purpose
+	// it was created for.
+	Consume(ctx context.Context, tokenHash string, purpose models.ActionTokenPurpose, now time.Time) (*models.ActionToken, error)
+	// DeleteByUser deletes every token issued to the user, used or not.
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
+func NewActionTokenRepository(client *firestore.Client, collection *firestore.CollectionRef) ActionTokenRepository {
+	return &actionTokenRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type actionTokenRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+func (repository *actionTokenRepositoryImpl) Create(ctx context.Context, tokenHash string, purpose models.ActionTokenPurpose, userID string, email string, now time.Time, expiresAt time.Time) (*models.ActionToken, error) {
+	output := &models.ActionToken{
+		ID:        tokenHash,
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+
+	_, err := repository.collection.Doc(tokenHash).Create(ctx, output)
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// checkActionToken returns an error if the token can't be used for purpose at the given time.
+func checkActionToken(token *models.ActionToken, purpose models.ActionTokenPurpose, now time.Time) error {
+	// A token used for another purpose is reported as missing, so it can't be probed.
+	if token.Purpose != purpose {
+		return ErrActionTokenNotFound
+	}
+	if token.UsedAt != nil {
+		return ErrActionTokenUsed
+	}
+	if !token.ExpiresAt.After(now) {
+		return ErrActionTokenExpired
+	}
+
+	return nil
+}
+
+func (repository *actionTokenRepositoryImpl) Get(ctx context.Context, tokenHash string, purpose models.ActionTokenPurpose, now time.Time) (*models.ActionToken, error) {
+	doc, err := repository.collection.Doc(tokenHash).Get(ctx)
+	if err != nil {
+		return nil, lo.Ternary(status.Code(err) == codes.NotFound, ErrActionTokenNotFound, err)
+	}
+
+	output := new(models.ActionToken)
+	if err := doc.DataTo(output); err != nil {
+		return nil, errors.Join(ErrParseDocument, err)
+	}
+
+	if err := checkActionToken(output, purpose, now); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (repository *actionTokenRepositoryImpl) Consume(ctx context.Context, tokenHash string, purpose models.ActionTokenPurpose, now time.Time) (*models.ActionToken, error) {
+	output := new(models.ActionToken)
+	ref := repository.collection.Doc(tokenHash)
+
+	err := repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return lo.Ternary(status.Code(err) == codes.NotFound, ErrActionTokenNotFound, err)
+		}
+
+		if err := doc.DataTo(output); err != nil {
+			return errors.Join(ErrParseDocument, err)
+		}
+
+		if err := checkActionToken(output, purpose, now); err != nil {
+			return err
+		}
+
+		output.UsedAt = &now
+		return tx.Update(ref, []firestore.Update{{Path: "used_at", Value: now}})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (repository *actionTokenRepositoryImpl) DeleteByUser(ctx context.Context, userID string) error {
+	return deleteDocuments(ctx, repository.collection.Where("user_id", "==", userID))
+}
@@ -0,0 +1,192 @@
+package dao_test
+
+import (
+	"context"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const ActionTokensTestCollection = "test-action-tokens"
+
+func TestActionTokenConsume(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewActionTokenRepository(firestoreClient, firestoreClient.Collection(ActionTokensTestCollection))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	usedAt := now.Add(-time.Minute)
+
+	fixtures := map[string]interface{}{
+		"hash-valid": map[string]interface{}{
+			"id":         "hash-valid",
+			"purpose":    "password_reset",
+			"user_id":    "user-1",
+			"created_at": now.Add(-time.Minute),
+			"expires_at": now.Add(time.Hour),
+		},
+		"hash-expired": map[string]interface{}{
+			"id":         "hash-expired",
+			"purpose":    "password_reset",
+			"user_id":    "user-1",
+			"created_at": now.Add(-2 * time.Hour),
+			"expires_at": now.Add(-time.Hour),
+		},
+		"hash-used": map[string]interface{}{
+			"id":         "hash-used",
+			"purpose":    "password_reset",
+			"user_id":    "user-1",
+			"created_at": now.Add(-2 * time.Minute),
+			"expires_at": now.Add(time.Hour),
+			"used_at":    usedAt,
+		},
+	}
+
+	data := []struct {
+		name string
+
+		tokenHash string
+		purpose   models.ActionTokenPurpose
+
+		expectErr error
+	}{
+		{
+			name:      "Success",
+			tokenHash: "hash-valid",
+			purpose:   models.ActionTokenPasswordReset,
+		},
+		{
+			name:      "WrongPurpose",
+			tokenHash: "hash-valid",
+			purpose:   "other",
+			expectErr: dao.ErrActionTokenNotFound,
+		},
+		{
+			name:      "Expired",
+			tokenHash: "hash-expired",
+			purpose:   models.ActionTokenPasswordReset,
+			expectErr: dao.ErrActionTokenExpired,
+		},
+		{
+			name:      "Used",
+			tokenHash: "hash-used",
+			purpose:   models.ActionTokenPasswordReset,
+			expectErr: dao.ErrActionTokenUsed,
+		},
+		{
+			name:      "NotFound",
+			tokenHash: "hash-unknown",
+			purpose:   models.ActionTokenPasswordReset,
+			expectErr: dao.ErrActionTokenNotFound,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				require.NoError(t, CleanFirestore(firestoreClient))
+			}()
+
+			for id, token := range fixtures {
+				_, err := firestoreClient.Collection(ActionTokensTestCollection).Doc(id).Set(context.Background(), token)
+				require.NoError(t, err)
+			}
+
+			res, err := repository.Consume(context.Background(), d.tokenHash, d.purpose, now)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				require.Equal(t, "user-1", res.UserID)
+
+				// Tokens are single-use.
+				_, err = repository.Consume(context.Background(), d.tokenHash, d.purpose, now)
+				require.ErrorIs(t, err, dao.ErrActionTokenUsed)
+			}
+		})
+	}
+}
+
+func TestActionTokenGet(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewActionTokenRepository(firestoreClient, firestoreClient.Collection(ActionTokensTestCollection))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fixtures := map[string]interface{}{
+		"hash-valid": map[string]interface{}{
+			"id":         "hash-valid",
+			"purpose":    "password_reset",
+			"user_id":    "user-1",
+			"created_at": now.Add(-time.Minute),
+			"expires_at": now.Add(time.Hour),
+		},
+		"hash-used": map[string]interface{}{
+			"id":         "hash-used",
+			"purpose":    "password_reset",
+			"user_id":    "user-1",
+			"created_at": now.Add(-2 * time.Minute),
+			"expires_at": now.Add(time.Hour),
+			"used_at":    now.Add(-time.Minute),
+		},
+	}
+
+	data := []struct {
+		name string
+
+		tokenHash string
+		purpose   models.ActionTokenPurpose
+
+		expectErr error
+	}{
+		{
+			name:      "Success",
+			tokenHash: "hash-valid",
+			purpose:   models.ActionTokenPasswordReset,
+		},
+		{
+			name:      "WrongPurpose",
+			tokenHash: "hash-valid",
+			purpose:   models.ActionTokenEmailVerification,
+			expectErr: dao.ErrActionTokenNotFound,
+		},
+		{
+			name:      "Used",
+			tokenHash: "hash-used",
+			purpose:   models.ActionTokenPasswordReset,
+			expectErr: dao.ErrActionTokenUsed,
+		},
+		{
+			name:      "NotFound",
+			tokenHash: "hash-unknown",
+			purpose:   models.ActionTokenPasswordReset,
+			expectErr: dao.ErrActionTokenNotFound,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				require.NoError(t, CleanFirestore(firestoreClient))
+			}()
+
+			for id, token := range fixtures {
+				_, err := firestoreClient.Collection(ActionTokensTestCollection).Doc(id).Set(context.Background(), token)
+				require.NoError(t, err)
+			}
+
+			res, err := repository.Get(context.Background(), d.tokenHash, d.purpose, now)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				require.Equal(t, "user-1", res.UserID)
+
+				// Get does not consume the token.
+				_, err = repository.Consume(context.Background(), d.tokenHash, d.purpose, now)
+				require.NoError(t, err)
+			}
+		})
+	}
+}
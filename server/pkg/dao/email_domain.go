@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// EmailDomainRepository stores the email domains allowed or denied by administrators, while the server is running.
+type EmailDomainRepository interface {
+	// Put adds the entry, replacing any previous entry of the same domain.
+	Put(ctx context.Context, entry *models.EmailDomain) error
+	// Find returns the entries of the given domains. Domains without an entry are skipped.
+	Find(ctx context.Context, domains []string) ([]*models.EmailDomain, error)
+}
+
+func NewEmailDomainRepository(client *firestore.Client, collection *firestore.CollectionRef) EmailDomainRepository {
+	return &emailDomainRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type emailDomainRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+func (repository *emailDomainRepositoryImpl) Put(ctx context.Context, entry *models.EmailDomain) error {
+	_, err := repository.collection.Doc(entry.Domain).Set(ctx, entry)
+	return err
+}
+
+func (repository *emailDomainRepositoryImpl) Find(ctx context.Context, domains []string) ([]*models.EmailDomain, error) {
+	refs := make([]*firestore.DocumentRef, len(domains))
+	for i, domain := range domains {
+		refs[i] = repository.collection.Doc(domain)
+	}
+
+	// Entries are read in a single round trip, as they are looked up on every registration.
+	docs, err := repository.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*models.EmailDomain, 0, len(docs))
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+
+		entry := new(models.EmailDomain)
+		if err := doc.DataTo(entry); err != nil {
+			return nil, errors.Join(ErrParseDocument, err)
+		}
+
+		output = append(output, entry)
+	}
+
+	return output, nil
+}
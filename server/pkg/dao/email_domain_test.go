@@ -0,0 +1,49 @@
+package dao_test
+
+import (
+	"context"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const EmailDomainsTestCollection = "test-email-domains"
+
+func TestEmailDomainFind(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewEmailDomainRepository(firestoreClient, firestoreClient.Collection(EmailDomainsTestCollection))
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, repository.Put(context.Background(), &models.EmailDomain{
+		Domain:    "example.com",
+		List:      models.EmailDomainDeny,
+		CreatedAt: now,
+	}))
+	require.NoError(t, repository.Put(context.Background(), &models.EmailDomain{
+		Domain:    "mail.example.com",
+		List:      models.EmailDomainDeny,
+		CreatedAt: now,
+	}))
+	// Entries are replaced.
+	require.NoError(t, repository.Put(context.Background(), &models.EmailDomain{
+		Domain:    "mail.example.com",
+		List:      models.EmailDomainAllow,
+		CreatedAt: now,
+	}))
+
+	entries, err := repository.Find(context.Background(), []string{"a.mail.example.com", "mail.example.com", "example.com", "com"})
+	require.NoError(t, err)
+	require.Equal(t, []*models.EmailDomain{
+		{Domain: "mail.example.com", List: models.EmailDomainAllow, CreatedAt: now},
+		{Domain: "example.com", List: models.EmailDomainDeny, CreatedAt: now},
+	}, entries)
+}
@@ -0,0 +1,111 @@
+package dao
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LoginAttemptRepository stores the failed login counters. Keys identify what is counted, like an account or an IP.
+type LoginAttemptRepository interface {
+	// Get returns the counter of the key. A missing or expired counter is returned empty.
+	Get(ctx context.Context, key string, now time.Time) (*models.LoginAttempts, error)
+	// RecordFailure increments the counter of the key, and returns it. The counter expires once no failure has been
+	// recorded for window.
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempts, error)
+	// Reset deletes the counter of the key.
+	Reset(ctx context.Context, key string) error
+}
+
+// NewLoginAttemptRepository stores counters in Firestore, so they are shared by every instance of the server.
+func NewLoginAttemptRepository(client *firestore.Client, collection *firestore.CollectionRef) LoginAttemptRepository {
+	return &loginAttemptRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type loginAttemptRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+// hashDocID hashes a key into a document ID, so emails and IPs are not stored in clear, and can't contain invalid
+// characters.
+func hashDocID(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// activeLoginAttempts returns the attempts, or an empty counter if they expired. Firestore TTL deletion can take a
+// day, so expired documents may still be read.
+func activeLoginAttempts(attempts *models.LoginAttempts, now time.Time) *models.LoginAttempts {
+	if attempts == nil || !attempts.ExpiresAt.After(now) {
+		return new(models.LoginAttempts)
+	}
+
+	return attempts
+}
+
+func (repository *loginAttemptRepositoryImpl) Get(ctx context.Context, key string, now time.Time) (*models.LoginAttempts, error) {
+	doc, err := repository.collection.Doc(hashDocID(key)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return new(models.LoginAttempts), nil
+		}
+
+		return nil, err
+	}
+
+	output := new(models.LoginAttempts)
+	if err := doc.DataTo(output); err != nil {
+		return nil, errors.Join(ErrParseDocument, err)
+	}
+
+	return activeLoginAttempts(output, now), nil
+}
+
+func (repository *loginAttemptRepositoryImpl) RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	ref := repository.collection.Doc(hashDocID(key))
+	output := new(models.LoginAttempts)
+
+	err := repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		current := new(models.LoginAttempts)
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(current); err != nil {
+				return errors.Join(ErrParseDocument, err)
+			}
+		}
+
+		*output = models.LoginAttempts{
+			Failures:      activeLoginAttempts(current, now).Failures + 1,
+			LastFailureAt: now,
+			ExpiresAt:     now.Add(window),
+		}
+
+		return tx.Set(ref, output)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (repository *loginAttemptRepositoryImpl) Reset(ctx context.Context, key string) error {
+	// Deleting a missing document is not an error.
+	_, err := repository.collection.Doc(hashDocID(key)).Delete(ctx)
+	return err
+}
@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+// NewMemoryLoginAttemptRepository stores counters in memory. They are lost on restart and are not shared between
+// instances, so it only fits deploys with a single instance.
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &memoryLoginAttemptRepositoryImpl{
+		attempts: make(map[string]models.LoginAttempts),
+	}
+}
+
+type memoryLoginAttemptRepositoryImpl struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+	// lastPrune is the last time expired counters were removed.
+	lastPrune time.Time
+}
+
+func (repository *memoryLoginAttemptRepositoryImpl) Get(_ context.Context, key string, now time.Time) (*models.LoginAttempts, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	attempts, ok := repository.attempts[key]
+	if !ok {
+		return new(models.LoginAttempts), nil
+	}
+
+	return activeLoginAttempts(&attempts, now), nil
+}
+
+func (repository *memoryLoginAttemptRepositoryImpl) RecordFailure(_ context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	repository.prune(now, window)
+
+	current := repository.attempts[key]
+	output := models.LoginAttempts{
+		Failures:      activeLoginAttempts(&current, now).Failures + 1,
+		LastFailureAt: now,
+		ExpiresAt:     now.Add(window),
+	}
+	repository.attempts[key] = output
+
+	return &output, nil
+}
+
+func (repository *memoryLoginAttemptRepositoryImpl) Reset(_ context.Context, key string) error {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	delete(repository.attempts, key)
+	return nil
+}
+
+// prune removes expired counters, at most once per window, so memory does not grow with every IP ever seen.
+func (repository *memoryLoginAttemptRepositoryImpl) prune(now time.Time, window time.Duration) {
+	if now.Sub(repository.lastPrune) < window {
+		return
+	}
+
+	for key, attempts := range repository.attempts {
+		if !attempts.ExpiresAt.After(now) {
+			delete(repository.attempts, key)
+		}
+	}
+
+	repository.lastPrune = now
+}
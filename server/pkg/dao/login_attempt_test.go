@@ -0,0 +1,95 @@
+package dao_test
+
+import (
+	"context"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const LoginAttemptsTestCollection = "test-login-attempts"
+
+func TestLoginAttempts(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+
+	repositories := map[string]func() dao.LoginAttemptRepository{
+		"Firestore": func() dao.LoginAttemptRepository {
+			return dao.NewLoginAttemptRepository(firestoreClient, firestoreClient.Collection(LoginAttemptsTestCollection))
+		},
+		"Memory": dao.NewMemoryLoginAttemptRepository,
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := time.Hour
+
+	data := []struct {
+		name string
+
+		// failures are recorded at these offsets from now.
+		failures []time.Duration
+		reset    bool
+
+		expectFailures int
+	}{
+		{
+			name:           "NoFailure",
+			expectFailures: 0,
+		},
+		{
+			name:           "Failures",
+			failures:       []time.Duration{-2 * time.Minute, -time.Minute, 0},
+			expectFailures: 3,
+		},
+		{
+			name:           "WindowElapsed",
+			failures:       []time.Duration{-3 * time.Hour, -2 * time.Hour},
+			expectFailures: 0,
+		},
+		{
+			name:           "RestartAfterWindow",
+			failures:       []time.Duration{-3 * time.Hour, -time.Minute},
+			expectFailures: 1,
+		},
+		{
+			name:           "Reset",
+			failures:       []time.Duration{-time.Minute, 0},
+			reset:          true,
+			expectFailures: 0,
+		},
+	}
+
+	for repositoryName, newRepository := range repositories {
+		for _, d := range data {
+			t.Run(repositoryName+"/"+d.name, func(t *testing.T) {
+				defer func() {
+					require.NoError(t, CleanFirestore(firestoreClient))
+				}()
+
+				repository := newRepository()
+
+				for _, offset := range d.failures {
+					res, err := repository.RecordFailure(context.Background(), "account:user@example.com", now.Add(offset), window)
+					require.NoError(t, err)
+					require.Equal(t, now.Add(offset).Add(window), res.ExpiresAt)
+					require.Positive(t, res.Failures)
+				}
+
+				if d.reset {
+					require.NoError(t, repository.Reset(context.Background(), "account:user@example.com"))
+				}
+
+				res, err := repository.Get(context.Background(), "account:user@example.com", now)
+				require.NoError(t, err)
+				require.Equal(t, d.expectFailures, res.Failures)
+
+				// Other keys are not affected.
+				res, err = repository.Get(context.Background(), "ip:10.0.0.1", now)
+				require.NoError(t, err)
+				require.Zero(t, res.Failures)
+			})
+		}
+	}
+}
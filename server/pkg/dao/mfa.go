@@ -0,0 +1,152 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrMFAAlreadyEnabled    = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled        = errors.New("two-factor authentication not enabled")
+	ErrNoPendingTOTP        = errors.New("no pending TOTP enrolment")
+	ErrTOTPCodeReused       = errors.New("TOTP code already used")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+)
+
+type MFARepository interface {
+	// Get returns the second factors of the user. Users without any get an empty MFA.
+	Get(ctx context.Context, userID string) (*models.MFA, error)
+	// SetPendingTOTP starts an enrolment, replacing any previous unconfirmed one.
+	SetPendingTOTP(ctx context.Context, userID string, secret string) error
+	// EnableTOTP confirms the pending enrolment. counter is the time step of the code that confirmed it.
+	EnableTOTP(ctx context.Context, userID string, counter int64, recoveryCodeHashes []string, now time.Time) error
+	// Disable removes every second factor of the user.
+	Disable(ctx context.Context, userID string) error
+	// UseTOTPCounter records the time step of an accepted code. It fails with ErrTOTPCodeReused if a code of the
+	// same or a later step was already accepted.
+	UseTOTPCounter(ctx context.Context, userID string, counter int64) error
+	// UseRecoveryCode removes the recovery code, so it can only be used once.
+	UseRecoveryCode(ctx context.Context, userID string, codeHash string) error
+}
+
+func NewMFARepository(client *firestore.Client, collection *firestore.CollectionRef) MFARepository {
+	return &mfaRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type mfaRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+func (repository *mfaRepositoryImpl) Get(ctx context.Context, userID string) (*models.MFA, error) {
+	doc, err := repository.collection.Doc(userID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return &models.MFA{UserID: userID}, nil
+		}
+
+		return nil, err
+	}
+
+	output := new(models.MFA)
+	if err := doc.DataTo(output); err != nil {
+		return nil, errors.Join(ErrParseDocument, err)
+	}
+
+	return output, nil
+}
+
+// update runs fn on the current MFA of the user in a transaction, and saves the result.
+func (repository *mfaRepositoryImpl) update(ctx context.Context, userID string, fn func(mfa *models.MFA) error) error {
+	ref := repository.collection.Doc(userID)
+
+	return repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		mfa := &models.MFA{UserID: userID}
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(mfa); err != nil {
+				return errors.Join(ErrParseDocument, err)
+			}
+		}
+
+		if err := fn(mfa); err != nil {
+			return err
+		}
+
+		return tx.Set(ref, mfa)
+	})
+}
+
+func (repository *mfaRepositoryImpl) SetPendingTOTP(ctx context.Context, userID string, secret string) error {
+	return repository.update(ctx, userID, func(mfa *models.MFA) error {
+		if mfa.TOTPEnabled() {
+			return ErrMFAAlreadyEnabled
+		}
+
+		mfa.TOTPPendingSecret = secret
+		return nil
+	})
+}
+
+func (repository *mfaRepositoryImpl) EnableTOTP(ctx context.Context, userID string, counter int64, recoveryCodeHashes []string, now time.Time) error {
+	return repository.update(ctx, userID, func(mfa *models.MFA) error {
+		if mfa.TOTPEnabled() {
+			return ErrMFAAlreadyEnabled
+		}
+		if mfa.TOTPPendingSecret == "" {
+			return ErrNoPendingTOTP
+		}
+
+		mfa.TOTPSecret = mfa.TOTPPendingSecret
+		mfa.TOTPPendingSecret = ""
+		mfa.TOTPEnabledAt = &now
+		mfa.TOTPLastCounter = counter
+		mfa.RecoveryCodeHashes = recoveryCodeHashes
+		return nil
+	})
+}
+
+func (repository *mfaRepositoryImpl) Disable(ctx context.Context, userID string) error {
+	_, err := repository.collection.Doc(userID).Delete(ctx)
+	return err
+}
+
+func (repository *mfaRepositoryImpl) UseTOTPCounter(ctx context.Context, userID string, counter int64) error {
+	return repository.update(ctx, userID, func(mfa *models.MFA) error {
+		if !mfa.TOTPEnabled() {
+			return ErrMFANotEnabled
+		}
+		if counter <= mfa.TOTPLastCounter {
+			return ErrTOTPCodeReused
+		}
+
+		mfa.TOTPLastCounter = counter
+		return nil
+	})
+}
+
+func (repository *mfaRepositoryImpl) UseRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	return repository.update(ctx, userID, func(mfa *models.MFA) error {
+		index := slices.Index(mfa.RecoveryCodeHashes, codeHash)
+		if index < 0 {
+			return ErrRecoveryCodeNotFound
+		}
+
+		mfa.RecoveryCodeHashes = slices.Delete(mfa.RecoveryCodeHashes, index, index+1)
+		return nil
+	})
+}
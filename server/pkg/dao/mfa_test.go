@@ -0,0 +1,61 @@
+package dao_test
+
+import (
+	"context"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const MFATestCollection = "test-mfa"
+
+func TestMFATOTP(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewMFARepository(firestoreClient, firestoreClient.Collection(MFATestCollection))
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mfa, err := repository.Get(ctx, "user-1")
+	require.NoError(t, err)
+	require.False(t, mfa.TOTPEnabled())
+
+	require.ErrorIs(t, repository.EnableTOTP(ctx, "user-1", 10, nil, now), dao.ErrNoPendingTOTP)
+	require.ErrorIs(t, repository.UseTOTPCounter(ctx, "user-1", 10), dao.ErrMFANotEnabled)
+
+	require.NoError(t, repository.SetPendingTOTP(ctx, "user-1", "secret"))
+	require.NoError(t, repository.EnableTOTP(ctx, "user-1", 10, []string{"hash-1", "hash-2"}, now))
+	require.ErrorIs(t, repository.SetPendingTOTP(ctx, "user-1", "other"), dao.ErrMFAAlreadyEnabled)
+
+	mfa, err = repository.Get(ctx, "user-1")
+	require.NoError(t, err)
+	require.True(t, mfa.TOTPEnabled())
+	require.Equal(t, "secret", mfa.TOTPSecret)
+	require.Empty(t, mfa.TOTPPendingSecret)
+	require.Equal(t, int64(10), mfa.TOTPLastCounter)
+
+	require.ErrorIs(t, repository.UseTOTPCounter(ctx, "user-1", 10), dao.ErrTOTPCodeReused)
+	require.NoError(t, repository.UseTOTPCounter(ctx, "user-1", 11))
+	require.ErrorIs(t, repository.UseTOTPCounter(ctx, "user-1", 9), dao.ErrTOTPCodeReused)
+
+	require.NoError(t, repository.UseRecoveryCode(ctx, "user-1", "hash-1"))
+	require.ErrorIs(t, repository.UseRecoveryCode(ctx, "user-1", "hash-1"), dao.ErrRecoveryCodeNotFound)
+
+	mfa, err = repository.Get(ctx, "user-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"hash-2"}, mfa.RecoveryCodeHashes)
+
+	require.NoError(t, repository.Disable(ctx, "user-1"))
+
+	mfa, err = repository.Get(ctx, "user-1")
+	require.NoError(t, err)
+	require.False(t, mfa.TOTPEnabled())
+	require.Empty(t, mfa.RecoveryCodeHashes)
+}
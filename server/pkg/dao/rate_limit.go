@@ -0,0 +1,131 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"math"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimitRepository counts requests with a sliding window: the count of the previous fixed window is weighted by
+// how much of it is still within the last window duration. It smooths bursts at window boundaries, with only two
+// counters per key.
+type RateLimitRepository interface {
+	// Hit counts a request for the key if it is within the limit. Denied requests are not counted.
+	Hit(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (*models.RateLimitStatus, error)
+}
+
+// rateLimitCounter holds the counts of the current and previous fixed windows of a key.
+type rateLimitCounter struct {
+	WindowStart time.Time `firestore:"window_start"`
+	Previous    int       `firestore:"previous"`
+	Current     int       `firestore:"current"`
+	// ExpiresAt is used as a Firestore TTL field, once the counter no longer affects any window.
+	ExpiresAt time.Time `firestore:"expires_at"`
+}
+
+// hit updates the counter for a request made at now, and returns the status of the limit.
+func (counter *rateLimitCounter) hit(limit int, window time.Duration, now time.Time) *models.RateLimitStatus {
+	windowStart := now.Truncate(window)
+
+	switch {
+	case counter.WindowStart.Equal(windowStart):
+	case counter.WindowStart.Equal(windowStart.Add(-window)):
+		counter.Previous, counter.Current = counter.Current, 0
+	default:
+		counter.Previous, counter.Current = 0, 0
+	}
+	counter.WindowStart = windowStart
+	counter.ExpiresAt = windowStart.Add(2 * window)
+
+	elapsed := now.Sub(windowStart)
+	weight := float64(window-elapsed) / float64(window)
+	count := float64(counter.Previous)*weight + float64(counter.Current)
+
+	output := &models.RateLimitStatus{
+		Limit:   limit,
+		ResetAt: windowStart.Add(window),
+	}
+
+	if count+1 > float64(limit) {
+		output.RetryAt = counter.retryAt(limit, window)
+		return output
+	}
+
+	counter.Current++
+	output.Allowed = true
+	output.Remaining = int(math.Floor(float64(limit) - count - 1))
+	return output
+}
+
+// retryAt returns when the weighted count will leave room for one more request.
+func (counter *rateLimitCounter) retryAt(limit int, window time.Duration) time.Time {
+	// No request ever fits a limit below one. Rules are validated when loaded, so this only guards the divisions below.
+	if limit < 1 {
+		return counter.WindowStart.Add(window)
+	}
+
+	// The previous count must decrease enough for the current one to fit.
+	if counter.Current < limit && counter.Previous > 0 {
+		ratio := float64(limit-1-counter.Current) / float64(counter.Previous)
+		return counter.WindowStart.Add(time.Duration(float64(window) * (1 - ratio)))
+	}
+
+	// The current count fills the limit on its own: it must become the previous count, and decrease in turn.
+	if counter.Current == 0 {
+		return counter.WindowStart.Add(window)
+	}
+
+	ratio := float64(limit-1) / float64(counter.Current)
+	return counter.WindowStart.Add(window).Add(time.Duration(float64(window) * (1 - ratio)))
+}
+
+// NewRateLimitRepository stores counters in Firestore, so limits hold across every instance of the server.
+func NewRateLimitRepository(client *firestore.Client, collection *firestore.CollectionRef) RateLimitRepository {
+	return &rateLimitRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type rateLimitRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+func (repository *rateLimitRepositoryImpl) Hit(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (*models.RateLimitStatus, error) {
+	ref := repository.collection.Doc(hashDocID(key))
+
+	var output *models.RateLimitStatus
+
+	err := repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		counter := new(rateLimitCounter)
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(counter); err != nil {
+				return errors.Join(ErrParseDocument, err)
+			}
+		}
+
+		output = counter.hit(limit, window, now)
+		if !output.Allowed {
+			return nil
+		}
+
+		return tx.Set(ref, counter)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
@@ -0,0 +1,54 @@
+package dao
+
+import (
+	"context"
+	"sync"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+// pruneInterval is the minimum delay between two removals of expired rate limit counters.
+const pruneInterval = time.Minute
+
+// NewMemoryRateLimitRepository stores counters in memory. Each instance of the server enforces its own limits.
+func NewMemoryRateLimitRepository() RateLimitRepository {
+	return &memoryRateLimitRepositoryImpl{
+		counters: make(map[string]*rateLimitCounter),
+	}
+}
+
+type memoryRateLimitRepositoryImpl struct {
+	mu        sync.Mutex
+	counters  map[string]*rateLimitCounter
+	lastPrune time.Time
+}
+
+func (repository *memoryRateLimitRepositoryImpl) Hit(_ context.Context, key string, limit int, window time.Duration, now time.Time) (*models.RateLimitStatus, error) {
+	repository.mu.Lock()
+	defer repository.mu.Unlock()
+
+	repository.prune(now)
+
+	counter, ok := repository.counters[key]
+	if !ok {
+		counter = new(rateLimitCounter)
+		repository.counters[key] = counter
+	}
+
+	return counter.hit(limit, window, now), nil
+}
+
+// prune removes counters that no longer affect any window, so memory does not grow with every client ever seen.
+func (repository *memoryRateLimitRepositoryImpl) prune(now time.Time) {
+	if now.Sub(repository.lastPrune) < pruneInterval {
+		return
+	}
+
+	for key, counter := range repository.counters {
+		if !counter.ExpiresAt.After(now) {
+			delete(repository.counters, key)
+		}
+	}
+
+	repository.lastPrune = now
+}
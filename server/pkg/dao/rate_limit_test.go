@@ -0,0 +1,124 @@
+package dao_test
+
+import (
+	"context"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const RateLimitsTestCollection = "test-rate-limits"
+
+func TestRateLimitHit(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+
+	repositories := map[string]func() dao.RateLimitRepository{
+		"Firestore": func() dao.RateLimitRepository {
+			return dao.NewRateLimitRepository(firestoreClient, firestoreClient.Collection(RateLimitsTestCollection))
+		},
+		"Memory": dao.NewMemoryRateLimitRepository,
+	}
+
+	windowStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := time.Minute
+
+	data := []struct {
+		name string
+
+		limit int
+		// hits are made at these offsets from the start of a window.
+		hits []time.Duration
+
+		expectAllowed   []bool
+		expectRemaining []int
+		// expectRetryAt is the offset at which the last hit could be retried, if it was denied.
+		expectRetryAt time.Duration
+	}{
+		{
+			name:            "WithinLimit",
+			limit:           4,
+			hits:            []time.Duration{0, time.Second, 2 * time.Second},
+			expectAllowed:   []bool{true, true, true},
+			expectRemaining: []int{3, 2, 1},
+		},
+		{
+			name:            "OverLimit",
+			limit:           4,
+			hits:            []time.Duration{0, 0, 0, 0, 0},
+			expectAllowed:   []bool{true, true, true, true, false},
+			expectRemaining: []int{3, 2, 1, 0, 0},
+			// The 4 hits must weigh less than 3 in the next window.
+			expectRetryAt: window + window/4,
+		},
+		{
+			name:  "PreviousWindowWeighted",
+			limit: 4,
+			// 4 hits in the first window weigh as 2 hits in the middle of the second one.
+			hits:            []time.Duration{0, 0, 0, 0, window + window/2, window + window/2, window + window/2},
+			expectAllowed:   []bool{true, true, true, true, true, true, false},
+			expectRemaining: []int{3, 2, 1, 0, 1, 0, 0},
+			// 2 hits in the current window: the previous 4 must weigh 1 at most.
+			expectRetryAt: window + 3*window/4,
+		},
+		{
+			name:            "WindowsElapsed",
+			limit:           4,
+			hits:            []time.Duration{0, 0, 0, 0, 2 * window},
+			expectAllowed:   []bool{true, true, true, true, true},
+			expectRemaining: []int{3, 2, 1, 0, 3},
+		},
+		{
+			name:            "LimitOne",
+			limit:           1,
+			hits:            []time.Duration{0, 0},
+			expectAllowed:   []bool{true, false},
+			expectRemaining: []int{0, 0},
+			// The hit must no longer weigh anything in the next window.
+			expectRetryAt: 2 * window,
+		},
+		{
+			name:            "LimitOnePreviousWindow",
+			limit:           1,
+			hits:            []time.Duration{0, window + window/2},
+			expectAllowed:   []bool{true, false},
+			expectRemaining: []int{0, 0},
+			// Nothing was counted in the current window, so only the previous one must elapse.
+			expectRetryAt: 2 * window,
+		},
+		{
+			// Rules are validated when loaded, but a zero limit must not make the retry time overflow.
+			name:            "ZeroLimit",
+			limit:           0,
+			hits:            []time.Duration{0},
+			expectAllowed:   []bool{false},
+			expectRemaining: []int{0},
+			expectRetryAt:   window,
+		},
+	}
+
+	for repositoryName, newRepository := range repositories {
+		for _, d := range data {
+			t.Run(repositoryName+"/"+d.name, func(t *testing.T) {
+				defer func() {
+					require.NoError(t, CleanFirestore(firestoreClient))
+				}()
+
+				repository := newRepository()
+
+				for i, offset := range d.hits {
+					res, err := repository.Hit(context.Background(), "test:ip:10.0.0.1", d.limit, window, windowStart.Add(offset))
+					require.NoError(t, err)
+					require.Equal(t, d.expectAllowed[i], res.Allowed, i)
+					require.Equal(t, d.expectRemaining[i], res.Remaining, i)
+
+					if !res.Allowed && i == len(d.hits)-1 {
+						require.Equal(t, windowStart.Add(d.expectRetryAt), res.RetryAt)
+					}
+				}
+			})
+		}
+	}
+}
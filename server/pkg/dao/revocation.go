@@ -0,0 +1,124 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userRevocationPrefix is prepended to the user ID, for documents that revoke every token of a user at once. Token
+// documents use the token ID, which is a UUID, so both kinds can't collide.
+const userRevocationPrefix = "user:"
+
+type RevocationRepository interface {
+	// RevokeToken revokes a single token. The entry is removed once expiresAt is reached, so it must not be earlier
+	// than the expiration date of the token.
+	RevokeToken(ctx context.Context, id string, userID string, expiresAt time.Time) error
+	// RevokeUserTokens revokes every token issued to the user before the given date. The date is truncated to the
+	// second, like the iat claim of JWTs, so a token issued in the same second, just after the revocation, is still
+	// accepted. Tokens bound to a session are revoked with it anyway. The entry is removed once expiresAt is reached,
+	// so it must be set after the expiration of the last token issued before that date.
+	RevokeUserTokens(ctx context.Context, userID string, before time.Time, expiresAt time.Time) error
+	// IsRevoked returns true if the token was revoked, either directly, or through its user.
+	IsRevoked(ctx context.Context, id string, userID string, issuedAt time.Time) (bool, error)
+	// DeleteByUser deletes every revocation of the user, and of their tokens. Tokens would be accepted again, so it
+	// must only be called once they have all expired.
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
+func NewRevocationRepository(client *firestore.Client, collection *firestore.CollectionRef) RevocationRepository {
+	return &revocationRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type revocationRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+type revocationDocument struct {
+	UserID string `firestore:"user_id"`
+	// RevokedBefore is only set for user revocations.
+	RevokedBefore time.Time `firestore:"revoked_before"`
+	// ExpiresAt is used as a Firestore TTL field, so expired entries are deleted automatically.
+	ExpiresAt time.Time `firestore:"expires_at"`
+}
+
+func (repository *revocationRepositoryImpl) RevokeToken(ctx context.Context, id string, userID string, expiresAt time.Time) error {
+	_, err := repository.collection.Doc(id).Set(ctx, revocationDocument{UserID: userID, ExpiresAt: expiresAt})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (repository *revocationRepositoryImpl) RevokeUserTokens(ctx context.Context, userID string, before time.Time, expiresAt time.Time) error {
+	ref := repository.collection.Doc(userRevocationPrefix + userID)
+	before = before.Truncate(time.Second)
+
+	// Never move the revocation date back, in case of concurrent calls.
+	return repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		current := new(revocationDocument)
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(current); err != nil {
+				return errors.Join(ErrParseDocument, err)
+			}
+		}
+
+		if current.RevokedBefore.After(before) {
+			before = current.RevokedBefore
+		}
+		if current.ExpiresAt.After(expiresAt) {
+			expiresAt = current.ExpiresAt
+		}
+
+		return tx.Set(ref, revocationDocument{UserID: userID, RevokedBefore: before, ExpiresAt: expiresAt})
+	})
+}
+
+func (repository *revocationRepositoryImpl) IsRevoked(ctx context.Context, id string, userID string, issuedAt time.Time) (bool, error) {
+	docs, err := repository.client.GetAll(ctx, []*firestore.DocumentRef{
+		repository.collection.Doc(id),
+		repository.collection.Doc(userRevocationPrefix + userID),
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(docs) != 2 {
+		return false, fmt.Errorf("expected 2 documents, got %d", len(docs))
+	}
+
+	if docs[0].Exists() {
+		return true, nil
+	}
+
+	if docs[1].Exists() {
+		userRevocation := new(revocationDocument)
+		if err := docs[1].DataTo(userRevocation); err != nil {
+			return false, errors.Join(ErrParseDocument, err)
+		}
+
+		if issuedAt.Before(userRevocation.RevokedBefore) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (repository *revocationRepositoryImpl) DeleteByUser(ctx context.Context, userID string) error {
+	return deleteDocuments(ctx, repository.collection.Where("user_id", "==", userID))
+}
@@ -0,0 +1,82 @@
+package dao_test
+
+import (
+	"context"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const RevocationsTestCollection = "test-revoked-tokens"
+
+func TestIsRevoked(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewRevocationRepository(firestoreClient, firestoreClient.Collection(RevocationsTestCollection))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		name string
+
+		id       string
+		userID   string
+		issuedAt time.Time
+
+		expectRevoked bool
+	}{
+		{
+			name:          "RevokedToken",
+			id:            "01010101-0101-0101-0101-010101010101",
+			userID:        "user-1",
+			issuedAt:      now,
+			expectRevoked: true,
+		},
+		{
+			name:     "ValidToken",
+			id:       "02020202-0202-0202-0202-020202020202",
+			userID:   "user-1",
+			issuedAt: now,
+		},
+		{
+			name:          "TokenIssuedBeforeUserRevocation",
+			id:            "03030303-0303-0303-0303-030303030303",
+			userID:        "user-2",
+			issuedAt:      now.Add(-2 * time.Minute),
+			expectRevoked: true,
+		},
+		{
+			name:     "TokenIssuedAfterUserRevocation",
+			id:       "04040404-0404-0404-0404-040404040404",
+			userID:   "user-2",
+			issuedAt: now,
+		},
+		{
+			// JWTs are issued with a precision of one second.
+			name:     "TokenIssuedInUserRevocationSecond",
+			id:       "05050505-0505-0505-0505-050505050505",
+			userID:   "user-3",
+			issuedAt: now,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				require.NoError(t, CleanFirestore(firestoreClient))
+			}()
+
+			require.NoError(t, repository.RevokeToken(context.Background(), "01010101-0101-0101-0101-010101010101", "user-1", now.Add(time.Hour)))
+			require.NoError(t, repository.RevokeUserTokens(context.Background(), "user-2", now.Add(-time.Minute), now.Add(time.Hour)))
+			require.NoError(t, repository.RevokeUserTokens(context.Background(), "user-3", now.Add(time.Second/2), now.Add(time.Hour)))
+			// An older revocation must not move the date back.
+			require.NoError(t, repository.RevokeUserTokens(context.Background(), "user-2", now.Add(-time.Hour), now.Add(time.Hour)))
+
+			revoked, err := repository.IsRevoked(context.Background(), d.id, d.userID, d.issuedAt)
+			require.NoError(t, err)
+			require.Equal(t, d.expectRevoked, revoked)
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrSessionExpired      = errors.New("session expired")
+	ErrSessionRevoked      = errors.New("session revoked")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// maxRetiredTokenHashes bounds the retired hashes kept on a session, so the document doesn't grow with every refresh.
+// Only the most recent tokens are worth detecting, as a stolen token is usually replayed shortly after it was issued.
+// Older tokens are simply rejected as invalid.
+const maxRetiredTokenHashes = 50
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	// ListByUser returns every session of the user, including revoked and expired ones, the most recently seen first.
+	ListByUser(ctx context.Context, userID string) ([]*models.Session, error)
+	// Touch records that a token of the session was used at now.
+	Touch(ctx context.Context, id string, now time.Time) error
+	// Rotate exchanges the current refresh token of the session for a new one. If the presented token was already
+	// exchanged, the session is revoked and ErrRefreshTokenReused is returned.
+	Rotate(ctx context.Context, id string, refreshTokenHash string, newRefreshTokenHash string, now time.Time, expiresAt time.Time) (*models.Session, error)
+	Revoke(ctx context.Context, id string, now time.Time) error
+	// RevokeUserSessions revokes every active session of the user, except the one with exceptID, if not empty.
+	RevokeUserSessions(ctx context.Context, userID string, exceptID string, now time.Time) error
+	// DeleteByUser deletes every session of the user, including revoked and expired ones.
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
+func NewSessionRepository(client *firestore.Client, collection *firestore.CollectionRef) SessionRepository {
+	return &sessionRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type sessionRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+func (repository *sessionRepositoryImpl) Create(ctx context.Context, session *models.Session) error {
+	if session.RetiredTokenHashes == nil {
+		session.RetiredTokenHashes = []string{}
+	}
+
+	// Create fails if the document exists, so a session can never be overwritten.
+	_, err := repository.collection.Doc(session.ID).Create(ctx, session)
+	return err
+}
+
+func (repository *sessionRepositoryImpl) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	output := new(models.Session)
+
+	doc, err := repository.collection.Doc(id).Get(ctx)
+	if err != nil {
+		return nil, lo.Ternary(status.Code(err) == codes.NotFound, ErrSessionNotFound, err)
+	}
+
+	if err := doc.DataTo(output); err != nil {
+		return nil, errors.Join(ErrParseDocument, err)
+	}
+
+	return output, nil
+}
+
+func (repository *sessionRepositoryImpl) ListByUser(ctx context.Context, userID string) ([]*models.Session, error) {
+	docs, err := repository.collection.Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*models.Session, 0, len(docs))
+	for _, doc := range docs {
+		session := new(models.Session)
+		if err := doc.DataTo(session); err != nil {
+			return nil, errors.Join(ErrParseDocument, err)
+		}
+
+		output = append(output, session)
+	}
+
+	// Sorted in memory, so the query doesn't need a composite index.
+	slices.SortFunc(output, func(a, b *models.Session) int {
+		return b.LastSeenAt.Compare(a.LastSeenAt)
+	})
+
+	return output, nil
+}
+
+func (repository *sessionRepositoryImpl) Touch(ctx context.Context, id string, now time.Time) error {
+	_, err := repository.collection.Doc(id).Update(ctx, []firestore.Update{{Path: "last_seen_at", Value: now}})
+	if err != nil {
+		return lo.Ternary(status.Code(err) == codes.NotFound, ErrSessionNotFound, err)
+	}
+
+	return nil
+}
+
+func (repository *sessionRepositoryImpl) Rotate(ctx context.Context, id string, refreshTokenHash string, newRefreshTokenHash string, now time.Time, expiresAt time.Time) (*models.Session, error) {
+	output := new(models.Session)
+	ref := repository.collection.Doc(id)
+
+	// Writes are discarded when the transaction returns an error, so the reuse is reported after the revocation
+	// has been committed.
+	var reused bool
+
+	err := repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		reused = false
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return lo.Ternary(status.Code(err) == codes.NotFound, ErrSessionNotFound, err)
+		}
+
+		if err := doc.DataTo(output); err != nil {
+			return errors.Join(ErrParseDocument, err)
+		}
+
+		if output.RevokedAt != nil {
+			return ErrSessionRevoked
+		}
+
+		if output.RefreshTokenHash != refreshTokenHash {
+			if !lo.Contains(output.RetiredTokenHashes, refreshTokenHash) {
+				return ErrInvalidRefreshToken
+			}
+
+			reused = true
+			output.RevokedAt = &now
+			return tx.Update(ref, []firestore.Update{{Path: "revoked_at", Value: now}})
+		}
+
+		if !output.ExpiresAt.After(now) {
+			return ErrSessionExpired
+		}
+
+		output.RetiredTokenHashes = append(output.RetiredTokenHashes, output.RefreshTokenHash)
+		if len(output.RetiredTokenHashes) > maxRetiredTokenHashes {
+			output.RetiredTokenHashes = output.RetiredTokenHashes[len(output.RetiredTokenHashes)-maxRetiredTokenHashes:]
+		}
+		output.RefreshTokenHash = newRefreshTokenHash
+		output.RefreshedAt = now
+		output.LastSeenAt = now
+		output.ExpiresAt = expiresAt
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "refresh_token_hash", Value: output.RefreshTokenHash},
+			{Path: "retired_token_hashes", Value: output.RetiredTokenHashes},
+			{Path: "refreshed_at", Value: output.RefreshedAt},
+			{Path: "last_seen_at", Value: output.LastSeenAt},
+			{Path: "expires_at", Value: output.ExpiresAt},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return output, nil
+}
+
+func (repository *sessionRepositoryImpl) Revoke(ctx context.Context, id string, now time.Time) error {
+	_, err := repository.collection.Doc(id).Update(ctx, []firestore.Update{{Path: "revoked_at", Value: now}})
+	if err != nil {
+		return lo.Ternary(status.Code(err) == codes.NotFound, ErrSessionNotFound, err)
+	}
+
+	return nil
+}
+
+func (repository *sessionRepositoryImpl) RevokeUserSessions(ctx context.Context, userID string, exceptID string, now time.Time) error {
+	docs, err := repository.collection.Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		session := new(models.Session)
+		if err := doc.DataTo(session); err != nil {
+			return errors.Join(ErrParseDocument, err)
+		}
+		if session.RevokedAt != nil || session.ID == exceptID {
+			continue
+		}
+
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{{Path: "revoked_at", Value: now}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repository *sessionRepositoryImpl) DeleteByUser(ctx context.Context, userID string) error {
+	return deleteDocuments(ctx, repository.collection.Where("user_id", "==", userID))
+}
+
+// deleteDocuments deletes every document matched by the query. Documents are deleted one at a time, as the queries
+// only match the few records of a single user.
+func deleteDocuments(ctx context.Context, query firestore.Query) error {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,281 @@
+package dao_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const SessionsTestCollection = "test-sessions"
+
+func TestSessionRotate(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewSessionRepository(firestoreClient, firestoreClient.Collection(SessionsTestCollection))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	revokedAt := now.Add(-time.Hour)
+
+	fixtures := map[string]interface{}{
+		"01010101-0101-0101-0101-010101010101": map[string]interface{}{
+			"id":                   "01010101-0101-0101-0101-010101010101",
+			"user_id":              "user-1",
+			"refresh_token_hash":   "hash-2",
+			"retired_token_hashes": []string{"hash-1"},
+			"created_at":           now.Add(-2 * time.Hour),
+			"refreshed_at":         now.Add(-time.Hour),
+			"expires_at":           now.Add(time.Hour),
+		},
+		"02020202-0202-0202-0202-020202020202": map[string]interface{}{
+			"id":                   "02020202-0202-0202-0202-020202020202",
+			"user_id":              "user-1",
+			"refresh_token_hash":   "hash-1",
+			"retired_token_hashes": []string{},
+			"created_at":           now.Add(-2 * time.Hour),
+			"refreshed_at":         now.Add(-2 * time.Hour),
+			"expires_at":           now.Add(-time.Hour),
+		},
+		"03030303-0303-0303-0303-030303030303": map[string]interface{}{
+			"id":                   "03030303-0303-0303-0303-030303030303",
+			"user_id":              "user-1",
+			"refresh_token_hash":   "hash-1",
+			"retired_token_hashes": []string{},
+			"created_at":           now.Add(-2 * time.Hour),
+			"refreshed_at":         now.Add(-2 * time.Hour),
+			"expires_at":           now.Add(time.Hour),
+			"revoked_at":           revokedAt,
+		},
+	}
+
+	data := []struct {
+		name string
+
+		id               string
+		refreshTokenHash string
+
+		expectErr     error
+		expectRevoked bool
+	}{
+		{
+			name:             "Success",
+			id:               "01010101-0101-0101-0101-010101010101",
+			refreshTokenHash: "hash-2",
+		},
+		{
+			name:             "Reused",
+			id:               "01010101-0101-0101-0101-010101010101",
+			refreshTokenHash: "hash-1",
+			expectErr:        dao.ErrRefreshTokenReused,
+			expectRevoked:    true,
+		},
+		{
+			name:             "InvalidToken",
+			id:               "01010101-0101-0101-0101-010101010101",
+			refreshTokenHash: "hash-0",
+			expectErr:        dao.ErrInvalidRefreshToken,
+		},
+		{
+			name:             "Expired",
+			id:               "02020202-0202-0202-0202-020202020202",
+			refreshTokenHash: "hash-1",
+			expectErr:        dao.ErrSessionExpired,
+		},
+		{
+			name:             "Revoked",
+			id:               "03030303-0303-0303-0303-030303030303",
+			refreshTokenHash: "hash-1",
+			expectErr:        dao.ErrSessionRevoked,
+			expectRevoked:    true,
+		},
+		{
+			name:             "SessionNotFound",
+			id:               "04040404-0404-0404-0404-040404040404",
+			refreshTokenHash: "hash-1",
+			expectErr:        dao.ErrSessionNotFound,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				require.NoError(t, CleanFirestore(firestoreClient))
+			}()
+
+			for id, session := range fixtures {
+				_, err := firestoreClient.Collection(SessionsTestCollection).Doc(id).Set(context.Background(), session)
+				require.NoError(t, err)
+			}
+
+			res, err := repository.Rotate(context.Background(), d.id, d.refreshTokenHash, "hash-new", now, now.Add(24*time.Hour))
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				require.Equal(t, "hash-new", res.RefreshTokenHash)
+				require.Contains(t, res.RetiredTokenHashes, d.refreshTokenHash)
+				require.True(t, now.Add(24*time.Hour).Equal(res.ExpiresAt))
+			}
+
+			if !errors.Is(d.expectErr, dao.ErrSessionNotFound) {
+				session, err := repository.GetSession(context.Background(), d.id)
+				require.NoError(t, err)
+				require.Equal(t, d.expectRevoked, session.RevokedAt != nil)
+			}
+		})
+	}
+}
+
+func TestSessionRotateMany(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewSessionRepository(firestoreClient, firestoreClient.Collection(SessionsTestCollection))
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	require.NoError(t, repository.Create(ctx, &models.Session{
+		ID:               "01010101-0101-0101-0101-010101010101",
+		UserID:           "user-1",
+		RefreshTokenHash: "hash-0",
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(time.Hour),
+	}))
+
+	const rotations = 200
+	for i := 0; i < rotations; i++ {
+		_, err := repository.Rotate(
+			ctx,
+			"01010101-0101-0101-0101-010101010101",
+			fmt.Sprintf("hash-%d", i),
+			fmt.Sprintf("hash-%d", i+1),
+			now,
+			now.Add(time.Hour),
+		)
+		require.NoError(t, err)
+	}
+
+	// Only the most recent hashes are kept, so the document stays small however long the session is used.
+	session, err := repository.GetSession(ctx, "01010101-0101-0101-0101-010101010101")
+	require.NoError(t, err)
+	require.Less(t, len(session.RetiredTokenHashes), rotations)
+	require.Contains(t, session.RetiredTokenHashes, fmt.Sprintf("hash-%d", rotations-1))
+	require.NotContains(t, session.RetiredTokenHashes, "hash-0")
+
+	// Forgotten tokens are rejected without revoking the session.
+	_, err = repository.Rotate(ctx, "01010101-0101-0101-0101-010101010101", "hash-0", "hash-new", now, now.Add(time.Hour))
+	require.ErrorIs(t, err, dao.ErrInvalidRefreshToken)
+
+	// Recent ones are still detected as reused.
+	_, err = repository.Rotate(ctx, "01010101-0101-0101-0101-010101010101", fmt.Sprintf("hash-%d", rotations-1), "hash-new", now, now.Add(time.Hour))
+	require.ErrorIs(t, err, dao.ErrRefreshTokenReused)
+}
+
+func TestSessionRevoke(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewSessionRepository(firestoreClient, firestoreClient.Collection(SessionsTestCollection))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	err := repository.Create(context.Background(), &models.Session{
+		ID:               "01010101-0101-0101-0101-010101010101",
+		UserID:           "user-1",
+		RefreshTokenHash: "hash-1",
+		CreatedAt:        now,
+		RefreshedAt:      now,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repository.Revoke(context.Background(), "01010101-0101-0101-0101-010101010101", now))
+	require.ErrorIs(t, repository.Revoke(context.Background(), "02020202-0202-0202-0202-020202020202", now), dao.ErrSessionNotFound)
+
+	_, err = repository.Rotate(context.Background(), "01010101-0101-0101-0101-010101010101", "hash-1", "hash-2", now, now.Add(time.Hour))
+	require.ErrorIs(t, err, dao.ErrSessionRevoked)
+}
+
+func TestSessionListByUser(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewSessionRepository(firestoreClient, firestoreClient.Collection(SessionsTestCollection))
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	for i, id := range []string{"01010101-0101-0101-0101-010101010101", "02020202-0202-0202-0202-020202020202"} {
+		require.NoError(t, repository.Create(ctx, &models.Session{
+			ID:          id,
+			UserID:      "user-1",
+			DeviceLabel: "Firefox on Linux",
+			CreatedAt:   now,
+			LastSeenAt:  now.Add(time.Duration(-i) * time.Hour),
+			ExpiresAt:   now.Add(time.Hour),
+		}))
+	}
+
+	require.NoError(t, repository.Touch(ctx, "02020202-0202-0202-0202-020202020202", now.Add(time.Minute)))
+	require.ErrorIs(t, repository.Touch(ctx, "03030303-0303-0303-0303-030303030303", now), dao.ErrSessionNotFound)
+
+	sessions, err := repository.ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "02020202-0202-0202-0202-020202020202", sessions[0].ID)
+	require.True(t, now.Add(time.Minute).Equal(sessions[0].LastSeenAt))
+	require.Equal(t, "Firefox on Linux", sessions[0].DeviceLabel)
+	require.Equal(t, "01010101-0101-0101-0101-010101010101", sessions[1].ID)
+
+	sessions, err = repository.ListByUser(ctx, "user-2")
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
+
+func TestSessionDeleteByUser(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewSessionRepository(firestoreClient, firestoreClient.Collection(SessionsTestCollection))
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	sessions := []*models.Session{
+		{ID: "01010101-0101-0101-0101-010101010101", UserID: "user-1"},
+		{ID: "02020202-0202-0202-0202-020202020202", UserID: "user-1"},
+		{ID: "03030303-0303-0303-0303-030303030303", UserID: "user-2"},
+	}
+	for _, session := range sessions {
+		session.CreatedAt = now
+		session.ExpiresAt = now.Add(time.Hour)
+		require.NoError(t, repository.Create(ctx, session))
+	}
+
+	// Revoked sessions are deleted too.
+	require.NoError(t, repository.Revoke(ctx, "01010101-0101-0101-0101-010101010101", now))
+
+	require.NoError(t, repository.DeleteByUser(ctx, "user-1"))
+
+	output, err := repository.ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	require.Empty(t, output)
+
+	output, err = repository.ListByUser(ctx, "user-2")
+	require.NoError(t, err)
+	require.Len(t, output, 1)
+}
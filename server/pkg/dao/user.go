@@ -3,53 +3,99 @@ package dao
 import (
 	"context"
 	"errors"
+	"reflect"
+	"strings"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/hasher"
 	"technical-interview/pkg/models"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/google/uuid"
 	"github.com/samber/lo"
-	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/api/iterator"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
 var (
-	ErrUserNotFound  = errors.New("user not found")
-	ErrParseDocument = errors.New("error while parsing document")
-	ErrEmailTaken    = errors.New("email already taken")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrParseDocument  = errors.New("error while parsing document")
+	ErrEmailTaken     = errors.New("email already taken")
+	ErrUsernameTaken  = errors.New("username already taken")
+	ErrEmailMismatch  = errors.New("email is neither the current nor the pending email of the user")
+	ErrUserModified   = errors.New("user was modified since it was read")
+	ErrReadOnlyField  = errors.New("field can't be changed with an update")
+	ErrDeletionNotDue = errors.New("user deletion is not due")
 )
 
+// userReadOnlyFields are the fields Update can't change, as they have dedicated methods.
+var userReadOnlyFields = map[string]bool{
+	"id":                true,
+	"email":             true,
+	"pending_email":     true,
+	"email_verified":    true,
+	"email_verified_at": true,
+	"password":          true,
+}
+
 type UserRepository interface {
 	Create(ctx context.Context, email string, password string, username string) (*models.User, error)
+	GetUser(ctx context.Context, id string) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	// GetUserByUsername finds a user by their username, whatever its case.
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
 	UpdateEmail(ctx context.Context, id string, email string) error
+	// SetPendingEmail stores the address the user wants to switch to, until they verify it.
+	SetPendingEmail(ctx context.Context, id string, email string) error
+	// VerifyEmail marks the email as verified. If it is the pending email of the user, it replaces the current one.
+	VerifyEmail(ctx context.Context, id string, email string, now time.Time) error
+	UpdatePassword(ctx context.Context, id string, password string) error
+	// Update changes the user with fn, and only writes the fields it changed. If ifUpdatedAt is not zero, it returns
+	// ErrUserModified when the user was updated since then. Fields with a dedicated method, like the email and the
+	// password, can't be changed. fn may be called more than once, if the transaction is retried.
+	Update(ctx context.Context, id string, ifUpdatedAt time.Time, fn func(user *models.User) error) (*models.User, error)
+	// ListDueDeletions returns the users whose deletion is scheduled at or before now.
+	ListDueDeletions(ctx context.Context, now time.Time) ([]*models.User, error)
+	// Delete removes the user, and releases their email and username, so they can be registered again. It fails with
+	// ErrDeletionNotDue if the deletion of the user is not scheduled at or before now, in case it was cancelled since
+	// the user was listed.
+	Delete(ctx context.Context, id string, now time.Time) error
 }
 
-func NewUserRepository(collection *firestore.CollectionRef) UserRepository {
+// NewUserRepository creates the user repository. Passwords are hashed with passwordHasher, so they don't get exposed
+// in case of data leak. Emails are reserved in the emails collection under their canonical key, computed by
+// emailParser, so a mailbox can only belong to one user, even under concurrent writes. Emails must be given in
+// normalized form. Usernames are reserved the same way in the usernames collection, whatever their case.
+func NewUserRepository(
+	client *firestore.Client,
+	collection *firestore.CollectionRef,
+	emails *firestore.CollectionRef,
+	usernames *firestore.CollectionRef,
+	emailParser emailaddr.Parser,
+	passwordHasher hasher.PasswordHasher,
+) UserRepository {
 	return &userRepositoryImpl{
+		client:     client,
 		collection: collection,
+		emails:     &emailIndex{users: collection, emails: emails, parser: emailParser},
+		usernames:  &usernameIndex{users: collection, usernames: usernames},
+		hasher:     passwordHasher,
 	}
 }
 
 type userRepositoryImpl struct {
+	client     *firestore.Client
 	collection *firestore.CollectionRef
+	emails     *emailIndex
+	usernames  *usernameIndex
+	hasher     hasher.PasswordHasher
 }
 
 func (repository *userRepositoryImpl) Create(ctx context.Context, email string, password string, username string) (*models.User, error) {
 	id := uuid.New()
 
-	// Verify email is available.
-	_, err := repository.GetUserByEmail(ctx, email)
-	if err == nil {
-		return nil, ErrEmailTaken
-	}
-	if !errors.Is(err, ErrUserNotFound) {
-		return nil, err
-	}
-
-	// Hash the password so it doesn't get exposed in case of data leak.
-	passwordHashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	passwordHashed, err := repository.hasher.Hash(password)
 	if err != nil {
 		return nil, err
 	}
@@ -58,10 +104,26 @@ func (repository *userRepositoryImpl) Create(ctx context.Context, email string,
 		ID:       id.String(),
 		Email:    email,
 		Username: username,
-		Password: string(passwordHashed),
+		Password: passwordHashed,
 	}
 
-	_, err = repository.collection.Doc(id.String()).Set(ctx, output)
+	err = repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := repository.emails.checkAvailable(tx, email, output.ID); err != nil {
+			return err
+		}
+		if err := repository.usernames.checkAvailable(tx, username, output.ID); err != nil {
+			return err
+		}
+
+		if err := repository.emails.reserve(tx, email, output.ID, time.Now()); err != nil {
+			return err
+		}
+		if err := repository.usernames.reserve(tx, username, output.ID, time.Now()); err != nil {
+			return err
+		}
+
+		return tx.Create(repository.collection.Doc(output.ID), output)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -69,42 +131,304 @@ func (repository *userRepositoryImpl) Create(ctx context.Context, email string,
 	return output, nil
 }
 
+func (repository *userRepositoryImpl) GetUser(ctx context.Context, id string) (*models.User, error) {
+	doc, err := repository.collection.Doc(id).Get(ctx)
+	if err != nil {
+		return nil, lo.Ternary(status.Code(err) == codes.NotFound, ErrUserNotFound, err)
+	}
+
+	return parseUser(doc)
+}
+
 func (repository *userRepositoryImpl) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	output := new(models.User)
+	// Any alias of the mailbox finds its owner.
+	doc, err := repository.emails.doc(email).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return nil, err
+	}
+	if err == nil {
+		reservation := new(models.UserEmail)
+		if err := doc.DataTo(reservation); err != nil {
+			return nil, errors.Join(ErrParseDocument, err)
+		}
+
+		return repository.GetUser(ctx, reservation.UserID)
+	}
+
+	// Users whose email is not reserved yet are found by their exact email, until the migration has run.
+	doc, err = repository.collection.Where("email", "==", email).Limit(1).Documents(ctx).Next()
+	if err != nil {
+		return nil, lo.Ternary(err == iterator.Done, ErrUserNotFound, err)
+	}
 
-	doc, err := repository.collection.Where("email", "==", email).Limit(1).Documents(ctx).Next()
+	return parseUser(doc)
+}
+
+func (repository *userRepositoryImpl) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	doc, err := repository.usernames.doc(username).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return nil, err
+	}
+	if err == nil {
+		reservation := new(models.UserUsername)
+		if err := doc.DataTo(reservation); err != nil {
+			return nil, errors.Join(ErrParseDocument, err)
+		}
+
+		return repository.GetUser(ctx, reservation.UserID)
+	}
+
+	// Users whose username is not reserved yet are found by their exact username, until the migration has run.
+	doc, err = repository.collection.Where("username", "==", username).Limit(1).Documents(ctx).Next()
 	if err != nil {
 		return nil, lo.Ternary(err == iterator.Done, ErrUserNotFound, err)
 	}
 
+	return parseUser(doc)
+}
+
+// parseUser reads a user document, with its update time.
+func parseUser(doc *firestore.DocumentSnapshot) (*models.User, error) {
+	output := new(models.User)
 	if err := doc.DataTo(output); err != nil {
 		return nil, errors.Join(ErrParseDocument, err)
 	}
+	output.UpdatedAt = doc.UpdateTime
 
 	return output, nil
 }
 
-func (repository *userRepositoryImpl) UpdateEmail(ctx context.Context, id string, email string) error {
-	// Verify email is available.
-	_, err := repository.GetUserByEmail(ctx, email)
-	if err == nil {
-		return ErrEmailTaken
+// getUser reads a user from within a transaction.
+func (repository *userRepositoryImpl) getUser(tx *firestore.Transaction, id string) (*models.User, error) {
+	doc, err := tx.Get(repository.collection.Doc(id))
+	if err != nil {
+		return nil, lo.Ternary(status.Code(err) == codes.NotFound, ErrUserNotFound, err)
 	}
-	if !errors.Is(err, ErrUserNotFound) {
+
+	return parseUser(doc)
+}
+
+// replaceEmail moves the reservation of the user from their current email to the new one, and applies the updates to
+// the user in the same transaction.
+func (repository *userRepositoryImpl) replaceEmail(ctx context.Context, id string, email string, updates func(user *models.User) ([]firestore.Update, error)) error {
+	return repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		user, err := repository.getUser(tx, id)
+		if err != nil {
+			return err
+		}
+
+		userUpdates, err := updates(user)
+		if err != nil {
+			return err
+		}
+
+		// The reservation only moves when the mailbox changes.
+		if repository.emails.key(email) == repository.emails.key(user.Email) {
+			return tx.Update(repository.collection.Doc(id), userUpdates)
+		}
+
+		if err := repository.emails.checkAvailable(tx, email, id); err != nil {
+			return err
+		}
+
+		previous, err := repository.emails.getReservation(tx, user.Email)
+		if err != nil {
+			return err
+		}
+
+		if err := repository.emails.release(tx, user.Email, previous, id); err != nil {
+			return err
+		}
+		if err := repository.emails.reserve(tx, email, id, time.Now()); err != nil {
+			return err
+		}
+
+		return tx.Update(repository.collection.Doc(id), userUpdates)
+	})
+}
+
+func (repository *userRepositoryImpl) UpdateEmail(ctx context.Context, id string, email string) error {
+	return repository.replaceEmail(ctx, id, email, func(_ *models.User) ([]firestore.Update, error) {
+		// A new address is not verified until proven otherwise.
+		return []firestore.Update{
+			{Path: "email", Value: email},
+			{Path: "email_verified", Value: false},
+			{Path: "email_verified_at", Value: nil},
+		}, nil
+	})
+}
+
+func (repository *userRepositoryImpl) SetPendingEmail(ctx context.Context, id string, email string) error {
+	// The pending email is only reserved once verified, but there is no point in sending a link for a taken address.
+	return repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if _, err := repository.getUser(tx, id); err != nil {
+			return err
+		}
+
+		if err := repository.emails.checkAvailable(tx, email, id); err != nil {
+			return err
+		}
+
+		return tx.Update(repository.collection.Doc(id), []firestore.Update{{Path: "pending_email", Value: email}})
+	})
+}
+
+func (repository *userRepositoryImpl) VerifyEmail(ctx context.Context, id string, email string, now time.Time) error {
+	return repository.replaceEmail(ctx, id, email, func(user *models.User) ([]firestore.Update, error) {
+		updates := []firestore.Update{
+			{Path: "email_verified", Value: true},
+			{Path: "email_verified_at", Value: now},
+		}
+
+		switch {
+		case email == user.Email:
+		case email != "" && email == user.PendingEmail:
+			// The address may have been taken since it was requested, which replaceEmail checks.
+			updates = append(updates, firestore.Update{Path: "email", Value: email}, firestore.Update{Path: "pending_email", Value: ""})
+		default:
+			return nil, ErrEmailMismatch
+		}
+
+		return updates, nil
+	})
+}
+
+func (repository *userRepositoryImpl) UpdatePassword(ctx context.Context, id string, password string) error {
+	passwordHashed, err := repository.hasher.Hash(password)
+	if err != nil {
 		return err
 	}
 
-	// Verify user exists.
-	_, err = repository.collection.Doc(id).Get(ctx)
+	// Update fails if the document does not exist, so a user can't be created by accident.
+	_, err = repository.collection.Doc(id).Update(ctx, []firestore.Update{{Path: "password", Value: passwordHashed}})
 	if err != nil {
 		return lo.Ternary(status.Code(err) == codes.NotFound, ErrUserNotFound, err)
 	}
 
-	_, err = repository.collection.Doc(id).
-		Set(ctx, map[string]interface{}{"email": email}, firestore.Merge(firestore.FieldPath{"email"}))
+	return nil
+}
+
+// diffUser returns the updates turning before into after, for the stored fields that changed.
+func diffUser(before *models.User, after *models.User) []firestore.Update {
+	var output []firestore.Update
+
+	beforeValue, afterValue := reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem()
+	for i := 0; i < beforeValue.NumField(); i++ {
+		path, _, _ := strings.Cut(beforeValue.Type().Field(i).Tag.Get("firestore"), ",")
+		if path == "" || path == "-" {
+			continue
+		}
+
+		if reflect.DeepEqual(beforeValue.Field(i).Interface(), afterValue.Field(i).Interface()) {
+			continue
+		}
+
+		output = append(output, firestore.Update{Path: path, Value: afterValue.Field(i).Interface()})
+	}
+
+	return output
+}
+
+func (repository *userRepositoryImpl) Update(ctx context.Context, id string, ifUpdatedAt time.Time, fn func(user *models.User) error) (*models.User, error) {
+	err := repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		user, err := repository.getUser(tx, id)
+		if err != nil {
+			return err
+		}
+		if !ifUpdatedAt.IsZero() && !user.UpdatedAt.Equal(ifUpdatedAt) {
+			return ErrUserModified
+		}
+
+		updated := *user
+		if err := fn(&updated); err != nil {
+			return err
+		}
+
+		updates := diffUser(user, &updated)
+		if len(updates) == 0 {
+			return nil
+		}
+		for _, update := range updates {
+			if userReadOnlyFields[update.Path] {
+				return ErrReadOnlyField
+			}
+		}
+
+		// The reservation only moves when the username changes, not only its case.
+		if usernameKey(updated.Username) != usernameKey(user.Username) {
+			if err := repository.usernames.checkAvailable(tx, updated.Username, id); err != nil {
+				return err
+			}
+
+			previous, err := repository.usernames.getReservation(tx, user.Username)
+			if err != nil {
+				return err
+			}
+
+			if err := repository.usernames.release(tx, user.Username, previous, id); err != nil {
+				return err
+			}
+			if err := repository.usernames.reserve(tx, updated.Username, id, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		return tx.Update(repository.collection.Doc(id), updates)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	// Transactions don't return the time of their writes, which is the new version of the user.
+	return repository.GetUser(ctx, id)
+}
+
+func (repository *userRepositoryImpl) ListDueDeletions(ctx context.Context, now time.Time) ([]*models.User, error) {
+	// Users without a scheduled deletion have a null date, which range filters never match.
+	docs, err := repository.collection.Where("deletion_scheduled_at", "<=", now).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*models.User, 0, len(docs))
+	for _, doc := range docs {
+		user, err := parseUser(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		output = append(output, user)
+	}
+
+	return output, nil
+}
+
+func (repository *userRepositoryImpl) Delete(ctx context.Context, id string, now time.Time) error {
+	return repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		user, err := repository.getUser(tx, id)
+		if err != nil {
+			return err
+		}
+		if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(now) {
+			return ErrDeletionNotDue
+		}
+
+		email, err := repository.emails.getReservation(tx, user.Email)
+		if err != nil {
+			return err
+		}
+		username, err := repository.usernames.getReservation(tx, user.Username)
+		if err != nil {
+			return err
+		}
+
+		if err := repository.emails.release(tx, user.Email, email, id); err != nil {
+			return err
+		}
+		if err := repository.usernames.release(tx, user.Username, username, id); err != nil {
+			return err
+		}
+
+		return tx.Delete(repository.collection.Doc(id))
+	})
 }
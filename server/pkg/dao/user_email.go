@@ -0,0 +1,232 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// emailIndex reserves emails in a dedicated collection, from within transactions, so two users can never end up with
+// the same mailbox. Reservations are stored under the canonical key of the address.
+type emailIndex struct {
+	users  *firestore.CollectionRef
+	emails *firestore.CollectionRef
+	parser emailaddr.Parser
+}
+
+// key returns the canonical key of a stored email. Emails stored before they were validated may not parse, in which
+// case they are only lower-cased.
+func (index *emailIndex) key(email string) string {
+	address, err := index.parser.Parse(email)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(email))
+	}
+
+	return address.Canonical()
+}
+
+// doc returns the reservation document of an email. Slashes are escaped, as they are not allowed in IDs.
+func (index *emailIndex) doc(email string) *firestore.DocumentRef {
+	return index.docByKey(index.key(email))
+}
+
+func (index *emailIndex) docByKey(key string) *firestore.DocumentRef {
+	return index.emails.Doc(url.PathEscape(key))
+}
+
+// getReservation returns the reservation of an email, or nil if it is free. Like every read of a transaction, it must
+// be done before the writes.
+func (index *emailIndex) getReservation(tx *firestore.Transaction, email string) (*models.UserEmail, error) {
+	doc, err := tx.Get(index.doc(email))
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	output := new(models.UserEmail)
+	if err := doc.DataTo(output); err != nil {
+		return nil, errors.Join(ErrParseDocument, err)
+	}
+
+	return output, nil
+}
+
+// checkAvailable returns ErrEmailTaken if the email belongs to another user than userID.
+func (index *emailIndex) checkAvailable(tx *firestore.Transaction, email string, userID string) error {
+	reservation, err := index.getReservation(tx, email)
+	if err != nil {
+		return err
+	}
+	if reservation != nil {
+		if reservation.UserID != userID {
+			return ErrEmailTaken
+		}
+
+		return nil
+	}
+
+	// Users created before the index existed are not in it until the email migration has run.
+	docs, err := tx.Documents(index.users.Where("email", "==", email).Limit(1)).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if doc.Ref.ID != userID {
+			return ErrEmailTaken
+		}
+	}
+
+	return nil
+}
+
+// reserve writes the reservation of the email for the user. Its availability must have been checked in the same
+// transaction.
+func (index *emailIndex) reserve(tx *firestore.Transaction, email string, userID string, now time.Time) error {
+	return tx.Set(index.doc(email), &models.UserEmail{
+		Email:     email,
+		UserID:    userID,
+		CreatedAt: now,
+	})
+}
+
+// release deletes the reservation of the email, if it was read as belonging to the user.
+func (index *emailIndex) release(tx *firestore.Transaction, email string, reservation *models.UserEmail, userID string) error {
+	if reservation == nil || reservation.UserID != userID {
+		return nil
+	}
+
+	return tx.Delete(index.doc(email))
+}
+
+// MigrateUserEmails rewrites the emails of existing users in normalized form, and reserves them under their canonical
+// key, replacing the reservations made before emails were normalized. It is safe to run while the server is up, and
+// more than once. Users with an invalid email, or whose mailbox is reserved by another user, are reported and left
+// untouched. Invalid pending emails are dropped, as they could never be verified.
+func MigrateUserEmails(
+	ctx context.Context,
+	client *firestore.Client,
+	users *firestore.CollectionRef,
+	emails *firestore.CollectionRef,
+	parser emailaddr.Parser,
+) (*models.EmailMigrationReport, error) {
+	index := &emailIndex{users: users, emails: emails, parser: parser}
+	output := &models.EmailMigrationReport{Conflicts: []string{}, Invalid: []string{}}
+
+	docs, err := users.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		var result models.EmailMigrationResult
+
+		err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			var err error
+			result, err = migrateUserEmail(tx, index, doc.Ref)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		output.Add(doc.Ref.ID, result)
+	}
+
+	return output, nil
+}
+
+// migrateUserEmail normalizes and reserves the email of a single user.
+func migrateUserEmail(tx *firestore.Transaction, index *emailIndex, ref *firestore.DocumentRef) (models.EmailMigrationResult, error) {
+	var output models.EmailMigrationResult
+
+	// The user is read in the transaction, in case their email changed since the listing.
+	doc, err := tx.Get(ref)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return output, nil
+		}
+
+		return output, err
+	}
+
+	user := new(models.User)
+	if err := doc.DataTo(user); err != nil {
+		return output, errors.Join(ErrParseDocument, err)
+	}
+	if user.Email == "" {
+		return output, nil
+	}
+
+	address, err := index.parser.Parse(user.Email)
+	if err != nil {
+		output.Invalid = true
+		return output, nil
+	}
+
+	reservation, err := index.getReservation(tx, address.String())
+	if err != nil {
+		return output, err
+	}
+	if reservation != nil && reservation.UserID != ref.ID {
+		output.Conflict = true
+		return output, nil
+	}
+
+	// Reservations made before emails were normalized are only lower-cased.
+	legacyRef := index.docByKey(strings.ToLower(strings.TrimSpace(user.Email)))
+	var legacy *models.UserEmail
+	if legacyRef.ID != index.doc(address.String()).ID {
+		legacyDoc, err := tx.Get(legacyRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return output, err
+		}
+		if err == nil {
+			legacy = new(models.UserEmail)
+			if err := legacyDoc.DataTo(legacy); err != nil {
+				return output, errors.Join(ErrParseDocument, err)
+			}
+		}
+	}
+
+	var updates []firestore.Update
+	if address.String() != user.Email {
+		updates = append(updates, firestore.Update{Path: "email", Value: address.String()})
+	}
+	if user.PendingEmail != "" {
+		// Invalid pending emails parse to the zero address, and are dropped.
+		if pending, _ := index.parser.Parse(user.PendingEmail); pending.String() != user.PendingEmail {
+			updates = append(updates, firestore.Update{Path: "pending_email", Value: pending.String()})
+		}
+	}
+
+	if legacy != nil && legacy.UserID == ref.ID {
+		if err := tx.Delete(legacyRef); err != nil {
+			return output, err
+		}
+	}
+	if reservation == nil {
+		output.Reserved = true
+		if err := index.reserve(tx, address.String(), ref.ID, time.Now()); err != nil {
+			return output, err
+		}
+	}
+	if len(updates) > 0 {
+		output.Normalized = true
+		if err := tx.Update(ref, updates); err != nil {
+			return output, err
+		}
+	}
+
+	return output, nil
+}
@@ -0,0 +1,181 @@
+package dao_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runConcurrently calls fn n times in parallel, and returns the errors of every call.
+func runConcurrently(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// requireSingleWinner checks that exactly one call succeeded, and that every other call was refused with
+// ErrEmailTaken.
+func requireSingleWinner(t *testing.T, errs []error) {
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+
+		require.ErrorIs(t, err, dao.ErrEmailTaken)
+	}
+
+	require.Equal(t, 1, succeeded)
+}
+
+func TestUserCreateConcurrent(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	// Aliases of the same mailbox can't be registered twice.
+	errs := runConcurrently(5, func(i int) error {
+		email := "user@gmail.com"
+		if i%2 == 1 {
+			email = "u.ser+news@gmail.com"
+		}
+
+		_, err := repository.Create(context.Background(), email, "1234", fmt.Sprintf("user%d", i))
+		return err
+	})
+	requireSingleWinner(t, errs)
+
+	users, err := firestoreClient.Collection(UsersTestCollection).Documents(context.Background()).GetAll()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+}
+
+func TestUpdateEmailConcurrent(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	ids := make([]string, 5)
+	for i := range ids {
+		user, err := repository.Create(context.Background(), fmt.Sprintf("user%d@gmail.com", i), "1234", fmt.Sprintf("user%d", i))
+		require.NoError(t, err)
+		ids[i] = user.ID
+	}
+
+	errs := runConcurrently(len(ids), func(i int) error {
+		return repository.UpdateEmail(context.Background(), ids[i], "taken@gmail.com")
+	})
+	requireSingleWinner(t, errs)
+
+	winner := slices.Index(errs, nil)
+
+	user, err := repository.GetUser(context.Background(), ids[winner])
+	require.NoError(t, err)
+	require.Equal(t, "taken@gmail.com", user.Email)
+
+	// The winner released their previous address.
+	_, err = repository.Create(context.Background(), fmt.Sprintf("user%d@gmail.com", winner), "1234", "new")
+	require.NoError(t, err)
+}
+
+func TestMigrateUserEmails(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	users := firestoreClient.Collection(UsersTestCollection)
+	emails := firestoreClient.Collection(UserEmailsTestCollection)
+	usernames := firestoreClient.Collection(UserUsernamesTestCollection)
+	repository := dao.NewUserRepository(firestoreClient, users, emails, usernames, testEmailParser, testHasher)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	ctx := context.Background()
+
+	// Users created before emails were normalized, two of them sharing a mailbox.
+	fixtures := map[string]map[string]interface{}{
+		"01010101-0101-0101-0101-010101010101": {"email": "User1@Example.com", "pending_email": "not an email"},
+		"02020202-0202-0202-0202-020202020202": {"email": "user2@gmail.com"},
+		"03030303-0303-0303-0303-030303030303": {"email": "User.2@gmail.com"},
+		"04040404-0404-0404-0404-040404040404": {"email": "garbage"},
+	}
+	for id, fields := range fixtures {
+		fields["id"] = id
+		_, err := users.Doc(id).Set(ctx, fields)
+		require.NoError(t, err)
+	}
+
+	// Reserved before emails were normalized, under the lower-cased email.
+	_, err := emails.Doc("user1@example.com").Set(ctx, map[string]interface{}{
+		"email":   "user1@example.com",
+		"user_id": "01010101-0101-0101-0101-010101010101",
+	})
+	require.NoError(t, err)
+
+	// Already normalized and reserved.
+	_, err = repository.Create(ctx, "user5@example.com", "1234", "user5")
+	require.NoError(t, err)
+
+	report, err := dao.MigrateUserEmails(ctx, firestoreClient, users, emails, testEmailParser)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Normalized)
+	require.Equal(t, 1, report.Reserved)
+	require.Len(t, report.Conflicts, 1)
+	require.Equal(t, []string{"04040404-0404-0404-0404-040404040404"}, report.Invalid)
+
+	user, err := repository.GetUser(ctx, "01010101-0101-0101-0101-010101010101")
+	require.NoError(t, err)
+	require.Equal(t, "user1@example.com", user.Email)
+	require.Empty(t, user.PendingEmail)
+
+	// Running it again changes nothing.
+	report, err = dao.MigrateUserEmails(ctx, firestoreClient, users, emails, testEmailParser)
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Normalized)
+	require.Equal(t, 0, report.Reserved)
+	require.Len(t, report.Conflicts, 1)
+
+	// Aliases find the owner of the mailbox, and can't be registered.
+	user, err = repository.GetUserByEmail(ctx, "user.2+news@gmail.com")
+	require.NoError(t, err)
+	require.Equal(t, "02020202-0202-0202-0202-020202020202", user.ID)
+
+	_, err = repository.Create(ctx, "u.s.e.r.2+new@gmail.com", "1234", "user6")
+	require.ErrorIs(t, err, dao.ErrEmailTaken)
+}
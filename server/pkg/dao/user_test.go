@@ -4,17 +4,29 @@ import (
 	"context"
 	"technical-interview/config"
 	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
-	"golang.org/x/crypto/bcrypt"
 )
 
-const UsersTestCollection = "test-users"
+const (
+	UsersTestCollection         = "test-users"
+	UserEmailsTestCollection    = "test-user-emails"
+	UserUsernamesTestCollection = "test-user-usernames"
+)
 
 func TestUserCreate(t *testing.T) {
 	firestoreClient := config.FirestoreClient
-	repository := dao.NewUserRepository(firestoreClient.Collection(UsersTestCollection))
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
 
 	fixtures := map[string]interface{}{
 		"01010101-0101-0101-0101-010101010101": map[string]interface{}{
@@ -67,8 +79,9 @@ func TestUserCreate(t *testing.T) {
 				require.Equal(t, d.email, res.Email)
 				require.Equal(t, d.username, res.Username)
 
-				err = bcrypt.CompareHashAndPassword([]byte(res.Password), []byte(d.password))
+				ok, _, err := testHasher.Verify(d.password, res.Password)
 				require.NoError(t, err)
+				require.True(t, ok)
 			}
 		})
 	}
@@ -76,7 +89,14 @@ func TestUserCreate(t *testing.T) {
 
 func TestGetUser(t *testing.T) {
 	firestoreClient := config.FirestoreClient
-	repository := dao.NewUserRepository(firestoreClient.Collection(UsersTestCollection))
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
 
 	fixtures := map[string]interface{}{
 		"01010101-0101-0101-0101-010101010101": map[string]interface{}{
@@ -128,7 +148,14 @@ func TestGetUser(t *testing.T) {
 
 func TestUpdateEmail(t *testing.T) {
 	firestoreClient := config.FirestoreClient
-	repository := dao.NewUserRepository(firestoreClient.Collection(UsersTestCollection))
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
 
 	fixtures := map[string]interface{}{
 		"01010101-0101-0101-0101-010101010101": map[string]interface{}{
@@ -194,3 +221,370 @@ func TestUpdateEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdatePassword(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	fixtures := map[string]interface{}{
+		"01010101-0101-0101-0101-010101010101": map[string]interface{}{
+			"id":       "01010101-0101-0101-0101-010101010101",
+			"email":    "user1@gmail.com",
+			"password": "safely-hashed-password",
+			"username": "user1",
+		},
+	}
+
+	data := []struct {
+		name string
+
+		id       string
+		password string
+
+		expectErr error
+	}{
+		{
+			name:     "Success",
+			id:       "01010101-0101-0101-0101-010101010101",
+			password: "new-password",
+		},
+		{
+			name:      "UserNotFound",
+			id:        "02020202-0202-0202-0202-020202020202",
+			password:  "new-password",
+			expectErr: dao.ErrUserNotFound,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				require.NoError(t, CleanFirestore(firestoreClient))
+			}()
+
+			for id, note := range fixtures {
+				_, err := firestoreClient.Collection(UsersTestCollection).Doc(id).Set(context.Background(), note)
+				require.NoError(t, err)
+			}
+
+			err := repository.UpdatePassword(context.Background(), d.id, d.password)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				res, err := repository.GetUser(context.Background(), d.id)
+				require.NoError(t, err)
+
+				ok, _, err := testHasher.Verify(d.password, res.Password)
+				require.NoError(t, err)
+				require.True(t, ok)
+			}
+		})
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	fixtures := map[string]interface{}{
+		"01010101-0101-0101-0101-010101010101": map[string]interface{}{
+			"id":             "01010101-0101-0101-0101-010101010101",
+			"email":          "user1@gmail.com",
+			"password":       "safely-hashed-password",
+			"username":       "user1",
+			"email_verified": false,
+			"pending_email":  "user1-new@gmail.com",
+		},
+		"02020202-0202-0202-0202-020202020202": map[string]interface{}{
+			"id":             "02020202-0202-0202-0202-020202020202",
+			"email":          "user2@gmail.com",
+			"password":       "safely-hashed-password",
+			"username":       "user2",
+			"email_verified": true,
+			"pending_email":  "user1@gmail.com",
+		},
+	}
+
+	data := []struct {
+		name string
+
+		id    string
+		email string
+
+		expectErr   error
+		expectEmail string
+	}{
+		{
+			name:        "CurrentEmail",
+			id:          "01010101-0101-0101-0101-010101010101",
+			email:       "user1@gmail.com",
+			expectEmail: "user1@gmail.com",
+		},
+		{
+			name:        "PendingEmail",
+			id:          "01010101-0101-0101-0101-010101010101",
+			email:       "user1-new@gmail.com",
+			expectEmail: "user1-new@gmail.com",
+		},
+		{
+			name:      "PendingEmailTaken",
+			id:        "02020202-0202-0202-0202-020202020202",
+			email:     "user1@gmail.com",
+			expectErr: dao.ErrEmailTaken,
+		},
+		{
+			name:      "EmailMismatch",
+			id:        "01010101-0101-0101-0101-010101010101",
+			email:     "other@gmail.com",
+			expectErr: dao.ErrEmailMismatch,
+		},
+		{
+			name:      "UserNotFound",
+			id:        "03030303-0303-0303-0303-030303030303",
+			email:     "user3@gmail.com",
+			expectErr: dao.ErrUserNotFound,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				require.NoError(t, CleanFirestore(firestoreClient))
+			}()
+
+			for id, note := range fixtures {
+				_, err := firestoreClient.Collection(UsersTestCollection).Doc(id).Set(context.Background(), note)
+				require.NoError(t, err)
+			}
+
+			err := repository.VerifyEmail(context.Background(), d.id, d.email, now)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				res, err := repository.GetUser(context.Background(), d.id)
+				require.NoError(t, err)
+				require.Equal(t, d.expectEmail, res.Email)
+				require.NotEqual(t, d.email, res.PendingEmail)
+				require.True(t, res.EmailVerified)
+				require.True(t, now.Equal(*res.EmailVerifiedAt))
+			}
+		})
+	}
+}
+
+func TestSetPendingEmail(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	fixtures := map[string]interface{}{
+		"01010101-0101-0101-0101-010101010101": map[string]interface{}{
+			"id":       "01010101-0101-0101-0101-010101010101",
+			"email":    "user1@gmail.com",
+			"password": "safely-hashed-password",
+			"username": "user1",
+		},
+		"02020202-0202-0202-0202-020202020202": map[string]interface{}{
+			"id":       "02020202-0202-0202-0202-020202020202",
+			"email":    "user2@gmail.com",
+			"password": "safely-hashed-password",
+			"username": "user2",
+		},
+	}
+
+	data := []struct {
+		name string
+
+		id    string
+		email string
+
+		expectErr error
+	}{
+		{
+			name:  "Success",
+			id:    "01010101-0101-0101-0101-010101010101",
+			email: "user3@gmail.com",
+		},
+		{
+			name:      "EmailTaken",
+			id:        "01010101-0101-0101-0101-010101010101",
+			email:     "user2@gmail.com",
+			expectErr: dao.ErrEmailTaken,
+		},
+		{
+			name:      "UserNotFound",
+			id:        "03030303-0303-0303-0303-030303030303",
+			email:     "user3@gmail.com",
+			expectErr: dao.ErrUserNotFound,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			defer func() {
+				require.NoError(t, CleanFirestore(firestoreClient))
+			}()
+
+			for id, note := range fixtures {
+				_, err := firestoreClient.Collection(UsersTestCollection).Doc(id).Set(context.Background(), note)
+				require.NoError(t, err)
+			}
+
+			err := repository.SetPendingEmail(context.Background(), d.id, d.email)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				res, err := repository.GetUser(context.Background(), d.id)
+				require.NoError(t, err)
+				require.Equal(t, d.email, res.PendingEmail)
+				// The current email is kept until the new one is verified.
+				require.Equal(t, "user1@gmail.com", res.Email)
+			}
+		})
+	}
+}
+
+func TestUserUpdate(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	ctx := context.Background()
+
+	user, err := repository.Create(ctx, "user1@gmail.com", "1234", "user1")
+	require.NoError(t, err)
+	_, err = repository.Create(ctx, "user2@gmail.com", "1234", "user2")
+	require.NoError(t, err)
+
+	user, err = repository.GetUser(ctx, user.ID)
+	require.NoError(t, err)
+	version := user.UpdatedAt
+
+	updated, err := repository.Update(ctx, user.ID, version, func(user *models.User) error {
+		user.Username = "Renamed"
+		user.DisplayName = "User 1"
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Renamed", updated.Username)
+	require.Equal(t, "User 1", updated.DisplayName)
+	require.True(t, updated.UpdatedAt.After(version))
+
+	// Writes based on an outdated version are refused.
+	_, err = repository.Update(ctx, user.ID, version, func(user *models.User) error {
+		user.DisplayName = "Outdated"
+		return nil
+	})
+	require.ErrorIs(t, err, dao.ErrUserModified)
+
+	// Nothing is written when nothing changed.
+	unchanged, err := repository.Update(ctx, user.ID, updated.UpdatedAt, func(user *models.User) error {
+		user.DisplayName = "User 1"
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, updated.UpdatedAt, unchanged.UpdatedAt)
+
+	_, err = repository.Update(ctx, user.ID, time.Time{}, func(user *models.User) error {
+		user.Username = "USER2"
+		return nil
+	})
+	require.ErrorIs(t, err, dao.ErrUsernameTaken)
+
+	_, err = repository.Update(ctx, user.ID, time.Time{}, func(user *models.User) error {
+		user.Email = "other@gmail.com"
+		return nil
+	})
+	require.ErrorIs(t, err, dao.ErrReadOnlyField)
+
+	// The previous username was released, and the new one is reserved.
+	_, err = repository.Create(ctx, "user3@gmail.com", "1234", "user1")
+	require.NoError(t, err)
+	_, err = repository.Create(ctx, "user4@gmail.com", "1234", "renamed")
+	require.ErrorIs(t, err, dao.ErrUsernameTaken)
+}
+
+func TestUserDelete(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	user, err := repository.Create(ctx, "user1@gmail.com", "1234", "user1")
+	require.NoError(t, err)
+	_, err = repository.Create(ctx, "user2@gmail.com", "1234", "user2")
+	require.NoError(t, err)
+
+	// Users are only deleted once their deletion is due.
+	require.ErrorIs(t, repository.Delete(ctx, user.ID, now), dao.ErrDeletionNotDue)
+
+	_, err = repository.Update(ctx, user.ID, time.Time{}, func(user *models.User) error {
+		user.DeletionScheduledAt = &now
+		return nil
+	})
+	require.NoError(t, err)
+
+	users, err := repository.ListDueDeletions(ctx, now.Add(-time.Second))
+	require.NoError(t, err)
+	require.Empty(t, users)
+	require.ErrorIs(t, repository.Delete(ctx, user.ID, now.Add(-time.Second)), dao.ErrDeletionNotDue)
+
+	users, err = repository.ListDueDeletions(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, user.ID, users[0].ID)
+
+	require.NoError(t, repository.Delete(ctx, user.ID, now))
+
+	_, err = repository.GetUser(ctx, user.ID)
+	require.ErrorIs(t, err, dao.ErrUserNotFound)
+	require.ErrorIs(t, repository.Delete(ctx, user.ID, now), dao.ErrUserNotFound)
+
+	// The email and username of the deleted user are released, including aliases.
+	_, err = repository.Create(ctx, "User.1+new@gmail.com", "1234", "USER1")
+	require.NoError(t, err)
+}
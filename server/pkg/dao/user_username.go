@@ -0,0 +1,170 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// usernameIndex reserves usernames in a dedicated collection, from within transactions, so two users can never share
+// one, whatever its case.
+type usernameIndex struct {
+	users     *firestore.CollectionRef
+	usernames *firestore.CollectionRef
+}
+
+// usernameKey returns the form under which usernames are compared.
+func usernameKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// doc returns the reservation document of a username. The key is hashed, as usernames may not be valid IDs.
+func (index *usernameIndex) doc(username string) *firestore.DocumentRef {
+	return index.usernames.Doc(hashDocID(usernameKey(username)))
+}
+
+// getReservation returns the reservation of a username, or nil if it is free. Like every read of a transaction, it
+// must be done before the writes.
+func (index *usernameIndex) getReservation(tx *firestore.Transaction, username string) (*models.UserUsername, error) {
+	doc, err := tx.Get(index.doc(username))
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	output := new(models.UserUsername)
+	if err := doc.DataTo(output); err != nil {
+		return nil, errors.Join(ErrParseDocument, err)
+	}
+
+	return output, nil
+}
+
+// checkAvailable returns ErrUsernameTaken if the username belongs to another user than userID.
+func (index *usernameIndex) checkAvailable(tx *firestore.Transaction, username string, userID string) error {
+	reservation, err := index.getReservation(tx, username)
+	if err != nil {
+		return err
+	}
+	if reservation != nil {
+		if reservation.UserID != userID {
+			return ErrUsernameTaken
+		}
+
+		return nil
+	}
+
+	// Users created before the index existed are not in it until the username migration has run. Only their exact
+	// username can be found.
+	docs, err := tx.Documents(index.users.Where("username", "==", username).Limit(1)).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if doc.Ref.ID != userID {
+			return ErrUsernameTaken
+		}
+	}
+
+	return nil
+}
+
+// reserve writes the reservation of the username for the user. Its availability must have been checked in the same
+// transaction.
+func (index *usernameIndex) reserve(tx *firestore.Transaction, username string, userID string, now time.Time) error {
+	return tx.Set(index.doc(username), &models.UserUsername{
+		Username:  username,
+		UserID:    userID,
+		CreatedAt: now,
+	})
+}
+
+// release deletes the reservation of the username, if it was read as belonging to the user.
+func (index *usernameIndex) release(tx *firestore.Transaction, username string, reservation *models.UserUsername, userID string) error {
+	if reservation == nil || reservation.UserID != userID {
+		return nil
+	}
+
+	return tx.Delete(index.doc(username))
+}
+
+// MigrateUsernames reserves the usernames of existing users, so they are unique whatever their case. It is safe to
+// run while the server is up, and more than once. Users whose username is reserved by another user are reported and
+// left untouched. Usernames are not checked against the policy, existing ones are kept as they are.
+func MigrateUsernames(
+	ctx context.Context,
+	client *firestore.Client,
+	users *firestore.CollectionRef,
+	usernames *firestore.CollectionRef,
+) (*models.UsernameMigrationReport, error) {
+	index := &usernameIndex{users: users, usernames: usernames}
+	output := &models.UsernameMigrationReport{Conflicts: []string{}}
+
+	docs, err := users.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		var reserved, conflict bool
+
+		err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			var err error
+			reserved, conflict, err = migrateUsername(tx, index, doc.Ref)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if reserved {
+			output.Reserved++
+		}
+		if conflict {
+			output.Conflicts = append(output.Conflicts, doc.Ref.ID)
+		}
+	}
+
+	return output, nil
+}
+
+// migrateUsername reserves the username of a single user, and returns whether it did, or whether the username
+// belongs to another user.
+func migrateUsername(tx *firestore.Transaction, index *usernameIndex, ref *firestore.DocumentRef) (bool, bool, error) {
+	// The user is read in the transaction, in case their username changed since the listing.
+	doc, err := tx.Get(ref)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, false, nil
+		}
+
+		return false, false, err
+	}
+
+	user := new(models.User)
+	if err := doc.DataTo(user); err != nil {
+		return false, false, errors.Join(ErrParseDocument, err)
+	}
+	if usernameKey(user.Username) == "" {
+		return false, false, nil
+	}
+
+	reservation, err := index.getReservation(tx, user.Username)
+	if err != nil {
+		return false, false, err
+	}
+	if reservation != nil {
+		return false, reservation.UserID != ref.ID, nil
+	}
+
+	return true, false, index.reserve(tx, user.Username, ref.ID, time.Now())
+}
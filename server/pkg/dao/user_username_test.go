@@ -0,0 +1,104 @@
+package dao_test
+
+import (
+	"context"
+	"fmt"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserCreateUsernameConcurrent(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewUserRepository(
+		firestoreClient,
+		firestoreClient.Collection(UsersTestCollection),
+		firestoreClient.Collection(UserEmailsTestCollection),
+		firestoreClient.Collection(UserUsernamesTestCollection),
+		testEmailParser,
+		testHasher,
+	)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	// Usernames are unique whatever their case.
+	errs := runConcurrently(5, func(i int) error {
+		username := "john"
+		if i%2 == 1 {
+			username = "John"
+		}
+
+		_, err := repository.Create(context.Background(), fmt.Sprintf("user%d@gmail.com", i), "1234", username)
+		return err
+	})
+
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+
+		require.ErrorIs(t, err, dao.ErrUsernameTaken)
+	}
+	require.Equal(t, 1, succeeded)
+
+	user, err := repository.GetUserByUsername(context.Background(), "JOHN")
+	require.NoError(t, err)
+	require.Contains(t, []string{"john", "John"}, user.Username)
+}
+
+func TestMigrateUsernames(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	users := firestoreClient.Collection(UsersTestCollection)
+	emails := firestoreClient.Collection(UserEmailsTestCollection)
+	usernames := firestoreClient.Collection(UserUsernamesTestCollection)
+	repository := dao.NewUserRepository(firestoreClient, users, emails, usernames, testEmailParser, testHasher)
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	ctx := context.Background()
+
+	// Users created before usernames were reserved, two of them differing only by case.
+	fixtures := map[string]map[string]interface{}{
+		"01010101-0101-0101-0101-010101010101": {"email": "user1@example.com", "username": "Alice"},
+		"02020202-0202-0202-0202-020202020202": {"email": "user2@example.com", "username": "bob"},
+		"03030303-0303-0303-0303-030303030303": {"email": "user3@example.com", "username": "BOB"},
+		"04040404-0404-0404-0404-040404040404": {"email": "user4@example.com"},
+	}
+	for id, fields := range fixtures {
+		fields["id"] = id
+		_, err := users.Doc(id).Set(ctx, fields)
+		require.NoError(t, err)
+	}
+
+	// Before the migration, only the exact username is found, and taken.
+	_, err := repository.GetUserByUsername(ctx, "alice")
+	require.ErrorIs(t, err, dao.ErrUserNotFound)
+	_, err = repository.Create(ctx, "user5@example.com", "1234", "Alice")
+	require.ErrorIs(t, err, dao.ErrUsernameTaken)
+
+	report, err := dao.MigrateUsernames(ctx, firestoreClient, users, usernames)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Reserved)
+	require.Len(t, report.Conflicts, 1)
+
+	// Running it again changes nothing.
+	report, err = dao.MigrateUsernames(ctx, firestoreClient, users, usernames)
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Reserved)
+	require.Len(t, report.Conflicts, 1)
+
+	user, err := repository.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "01010101-0101-0101-0101-010101010101", user.ID)
+
+	_, err = repository.Create(ctx, "user5@example.com", "1234", "ALICE")
+	require.ErrorIs(t, err, dao.ErrUsernameTaken)
+}
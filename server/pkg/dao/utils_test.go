@@ -5,8 +5,21 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/hasher"
 )
 
+// testHasher hashes passwords with low costs, to keep tests fast.
+var testHasher, _ = hasher.NewPasswordHasher(hasher.Options{
+	Algorithm: hasher.AlgorithmArgon2id,
+	Argon2id:  hasher.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+})
+
+// testEmailParser canonicalizes gmail.com addresses, to test aliases.
+var testEmailParser = emailaddr.NewParser(emailaddr.Options{
+	Rules: []emailaddr.Rule{{Domains: []string{"gmail.com"}, IgnoreDots: true, TagSeparators: "+"}},
+})
+
 // CleanFirestore deletes all test data for the local firestore instance.
 func CleanFirestore(client *firestore.Client) error {
 	collections, err := client.Collections(context.Background()).GetAll()
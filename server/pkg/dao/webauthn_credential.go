@@ -0,0 +1,177 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"technical-interview/pkg/models"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/samber/lo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("WebAuthn credential not found")
+	ErrWebAuthnCredentialExists   = errors.New("WebAuthn credential already registered")
+	ErrSignCountRegression        = errors.New("sign count did not increase")
+)
+
+type WebAuthnCredentialRepository interface {
+	// Create registers a new credential. It fails with ErrWebAuthnCredentialExists if the ID is already registered,
+	// by any user.
+	Create(ctx context.Context, credential *models.WebAuthnCredential) error
+	Get(ctx context.Context, id string) (*models.WebAuthnCredential, error)
+	// ListByUser returns the credentials of the user, oldest first.
+	ListByUser(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error)
+	// RecordUse stores the sign count of a successful assertion. It fails with ErrSignCountRegression if the
+	// authenticator counts signatures, and the count did not increase since the last use.
+	RecordUse(ctx context.Context, id string, signCount uint32, now time.Time) error
+	// Rename and Delete fail with ErrWebAuthnCredentialNotFound if the credential does not belong to the user.
+	Rename(ctx context.Context, userID string, id string, name string) error
+	Delete(ctx context.Context, userID string, id string) error
+	// DeleteByUser deletes every credential of the user.
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
+func NewWebAuthnCredentialRepository(client *firestore.Client, collection *firestore.CollectionRef) WebAuthnCredentialRepository {
+	return &webAuthnCredentialRepositoryImpl{
+		client:     client,
+		collection: collection,
+	}
+}
+
+type webAuthnCredentialRepositoryImpl struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+}
+
+// checkSignCount implements the clone detection of the WebAuthn specification. Authenticators that don't count
+// always report 0.
+func checkSignCount(credential *models.WebAuthnCredential, signCount uint32) error {
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return ErrSignCountRegression
+	}
+
+	return nil
+}
+
+// Credential IDs are chosen by authenticators, so they are hashed into document IDs.
+func (repository *webAuthnCredentialRepositoryImpl) doc(id string) *firestore.DocumentRef {
+	return repository.collection.Doc(hashDocID(id))
+}
+
+func (repository *webAuthnCredentialRepositoryImpl) Create(ctx context.Context, credential *models.WebAuthnCredential) error {
+	_, err := repository.doc(credential.ID).Create(ctx, credential)
+	if err != nil {
+		return lo.Ternary(status.Code(err) == codes.AlreadyExists, ErrWebAuthnCredentialExists, err)
+	}
+
+	return nil
+}
+
+func (repository *webAuthnCredentialRepositoryImpl) Get(ctx context.Context, id string) (*models.WebAuthnCredential, error) {
+	doc, err := repository.doc(id).Get(ctx)
+	if err != nil {
+		return nil, lo.Ternary(status.Code(err) == codes.NotFound, ErrWebAuthnCredentialNotFound, err)
+	}
+
+	output := new(models.WebAuthnCredential)
+	if err := doc.DataTo(output); err != nil {
+		return nil, errors.Join(ErrParseDocument, err)
+	}
+
+	return output, nil
+}
+
+func (repository *webAuthnCredentialRepositoryImpl) ListByUser(ctx context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	docs, err := repository.collection.Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*models.WebAuthnCredential, len(docs))
+	for i, doc := range docs {
+		output[i] = new(models.WebAuthnCredential)
+		if err := doc.DataTo(output[i]); err != nil {
+			return nil, errors.Join(ErrParseDocument, err)
+		}
+	}
+
+	// Sorted here rather than in the query, which would require a composite index.
+	slices.SortFunc(output, func(a, b *models.WebAuthnCredential) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return output, nil
+}
+
+// update runs fn on the credential in a transaction, and saves the result. A credential of another user is reported
+// as not found, unless userID is empty.
+func (repository *webAuthnCredentialRepositoryImpl) update(ctx context.Context, userID string, id string, fn func(credential *models.WebAuthnCredential) error) error {
+	ref := repository.doc(id)
+
+	return repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return lo.Ternary(status.Code(err) == codes.NotFound, ErrWebAuthnCredentialNotFound, err)
+		}
+
+		credential := new(models.WebAuthnCredential)
+		if err := doc.DataTo(credential); err != nil {
+			return errors.Join(ErrParseDocument, err)
+		}
+
+		if userID != "" && credential.UserID != userID {
+			return ErrWebAuthnCredentialNotFound
+		}
+
+		if err := fn(credential); err != nil {
+			return err
+		}
+
+		return tx.Set(ref, credential)
+	})
+}
+
+func (repository *webAuthnCredentialRepositoryImpl) RecordUse(ctx context.Context, id string, signCount uint32, now time.Time) error {
+	return repository.update(ctx, "", id, func(credential *models.WebAuthnCredential) error {
+		if err := checkSignCount(credential, signCount); err != nil {
+			return err
+		}
+
+		credential.SignCount = signCount
+		credential.LastUsedAt = &now
+		return nil
+	})
+}
+
+func (repository *webAuthnCredentialRepositoryImpl) Rename(ctx context.Context, userID string, id string, name string) error {
+	return repository.update(ctx, userID, id, func(credential *models.WebAuthnCredential) error {
+		credential.Name = name
+		return nil
+	})
+}
+
+func (repository *webAuthnCredentialRepositoryImpl) Delete(ctx context.Context, userID string, id string) error {
+	ref := repository.doc(id)
+
+	return repository.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return lo.Ternary(status.Code(err) == codes.NotFound, ErrWebAuthnCredentialNotFound, err)
+		}
+
+		if owner, err := doc.DataAt("user_id"); err != nil || owner != userID {
+			return ErrWebAuthnCredentialNotFound
+		}
+
+		return tx.Delete(ref)
+	})
+}
+
+func (repository *webAuthnCredentialRepositoryImpl) DeleteByUser(ctx context.Context, userID string) error {
+	return deleteDocuments(ctx, repository.collection.Where("user_id", "==", userID))
+}
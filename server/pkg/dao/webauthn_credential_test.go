@@ -0,0 +1,70 @@
+package dao_test
+
+import (
+	"context"
+	"technical-interview/config"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const WebAuthnCredentialsTestCollection = "test-webauthn-credentials"
+
+func TestWebAuthnCredential(t *testing.T) {
+	firestoreClient := config.FirestoreClient
+	repository := dao.NewWebAuthnCredentialRepository(firestoreClient, firestoreClient.Collection(WebAuthnCredentialsTestCollection))
+
+	defer func() {
+		require.NoError(t, CleanFirestore(firestoreClient))
+	}()
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, id := range []string{"credential-2", "credential-1"} {
+		require.NoError(t, repository.Create(ctx, &models.WebAuthnCredential{
+			ID:        id,
+			UserID:    "user-1",
+			Name:      id,
+			PublicKey: []byte("key"),
+			SignCount: 5,
+			CreatedAt: now.Add(time.Duration(-i) * time.Hour),
+		}))
+	}
+
+	err := repository.Create(ctx, &models.WebAuthnCredential{ID: "credential-1", UserID: "user-2"})
+	require.ErrorIs(t, err, dao.ErrWebAuthnCredentialExists)
+
+	credentials, err := repository.ListByUser(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, credentials, 2)
+	require.Equal(t, "credential-1", credentials[0].ID)
+	require.Equal(t, "credential-2", credentials[1].ID)
+
+	credentials, err = repository.ListByUser(ctx, "user-2")
+	require.NoError(t, err)
+	require.Empty(t, credentials)
+
+	require.ErrorIs(t, repository.RecordUse(ctx, "credential-1", 5, now), dao.ErrSignCountRegression)
+	require.NoError(t, repository.RecordUse(ctx, "credential-1", 6, now))
+	require.ErrorIs(t, repository.RecordUse(ctx, "unknown", 6, now), dao.ErrWebAuthnCredentialNotFound)
+
+	require.ErrorIs(t, repository.Rename(ctx, "user-2", "credential-1", "Stolen"), dao.ErrWebAuthnCredentialNotFound)
+	require.NoError(t, repository.Rename(ctx, "user-1", "credential-1", "Laptop"))
+
+	credential, err := repository.Get(ctx, "credential-1")
+	require.NoError(t, err)
+	require.Equal(t, "Laptop", credential.Name)
+	require.Equal(t, uint32(6), credential.SignCount)
+	require.NotNil(t, credential.LastUsedAt)
+	require.True(t, now.Equal(*credential.LastUsedAt))
+
+	require.ErrorIs(t, repository.Delete(ctx, "user-2", "credential-1"), dao.ErrWebAuthnCredentialNotFound)
+	require.NoError(t, repository.Delete(ctx, "user-1", "credential-1"))
+
+	_, err = repository.Get(ctx, "credential-1")
+	require.ErrorIs(t, err, dao.ErrWebAuthnCredentialNotFound)
+}
@@ -0,0 +1,188 @@
+package emailaddr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Limits of RFC 5321, in bytes.
+const (
+	maxLength      = 254
+	maxLocalLength = 64
+	maxDomainLen   = 253
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid email address")
+)
+
+// atextSpecials are the characters allowed in an unquoted local part, besides letters and digits (RFC 5322 atext).
+const atextSpecials = "!#$%&'*+-/=?^_`{|}~"
+
+// Address is a validated email address, in normalized form: the local part is case-folded and the domain is in its
+// lower case ASCII form, with internationalized labels encoded in punycode. The zero value is not a valid address.
+type Address struct {
+	local     string
+	domain    string
+	canonical string
+}
+
+// String returns the normalized address, which is the form to store and to send emails to.
+func (a Address) String() string {
+	if a.IsZero() {
+		return ""
+	}
+
+	return a.local + "@" + a.domain
+}
+
+// Canonical returns the key identifying the mailbox of the address. Addresses a provider delivers to the same
+// mailbox, like with and without dots for some providers, share the same key.
+func (a Address) Canonical() string {
+	return a.canonical
+}
+
+// Local returns the part of the address before the @.
+func (a Address) Local() string {
+	return a.local
+}
+
+// Domain returns the ASCII form of the domain.
+func (a Address) Domain() string {
+	return a.domain
+}
+
+// Unicode returns the address with its domain in Unicode, to show it to users.
+func (a Address) Unicode() string {
+	domain, err := idna.Display.ToUnicode(a.domain)
+	if err != nil {
+		return a.String()
+	}
+
+	return a.local + "@" + domain
+}
+
+func (a Address) IsZero() bool {
+	return a.local == "" && a.domain == ""
+}
+
+// invalid wraps the reason an address was rejected into ErrInvalidAddress.
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidAddress, fmt.Sprintf(format, args...))
+}
+
+// isAtext returns true if the rune is allowed in an unquoted local part. Non ASCII characters are allowed, as in
+// RFC 6531.
+func isAtext(r rune) bool {
+	return r >= 'a' && r <= 'z' ||
+		r >= 'A' && r <= 'Z' ||
+		r >= '0' && r <= '9' ||
+		strings.ContainsRune(atextSpecials, r) ||
+		r >= utf8.RuneSelf
+}
+
+// checkDotAtom validates an unquoted local part: atoms of atext separated by single dots.
+func checkDotAtom(local string) error {
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return invalid("dots must separate characters")
+		}
+
+		for _, r := range atom {
+			if r == utf8.RuneError || !isAtext(r) {
+				return invalid("character %q is not allowed unquoted", r)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkQuotedString validates a quoted local part, where any printable character is allowed, and quotes and
+// backslashes must be escaped.
+func checkQuotedString(local string) error {
+	if len(local) < 2 || !strings.HasSuffix(local, `"`) {
+		return invalid("unterminated quoted local part")
+	}
+
+	content := local[1 : len(local)-1]
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		case c == '\\':
+			i++
+			if i == len(content) || content[i] < ' ' || content[i] == 0x7f {
+				return invalid("invalid escape in quoted local part")
+			}
+		case c == '"':
+			return invalid("quotes must be escaped in quoted local part")
+		case c < ' ' || c == 0x7f:
+			return invalid("control characters are not allowed")
+		}
+	}
+
+	if !utf8.ValidString(content) {
+		return invalid("invalid UTF-8")
+	}
+
+	return nil
+}
+
+// parse validates the syntax of an addr-spec (RFC 5322 3.4.1), and returns its normalized parts. Display names,
+// comments and domain literals are rejected, as they have no place in an account email.
+func parse(raw string) (string, string, error) {
+	raw = strings.TrimSpace(raw)
+
+	at := strings.LastIndexByte(raw, '@')
+	if at < 0 {
+		return "", "", invalid("missing @")
+	}
+
+	local, domain := raw[:at], raw[at+1:]
+	if local == "" {
+		return "", "", invalid("missing local part")
+	}
+	if len(local) > maxLocalLength {
+		return "", "", invalid("local part is longer than %d bytes", maxLocalLength)
+	}
+
+	if strings.HasPrefix(local, `"`) {
+		if err := checkQuotedString(local); err != nil {
+			return "", "", err
+		}
+	} else if err := checkDotAtom(local); err != nil {
+		return "", "", err
+	}
+
+	if domain == "" {
+		return "", "", invalid("missing domain")
+	}
+	if strings.HasPrefix(domain, "[") {
+		return "", "", invalid("IP addresses are not allowed as domain")
+	}
+
+	// Lookup validates the labels, maps them to lower case, and encodes internationalized ones in punycode.
+	domain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", "", errors.Join(invalid("invalid domain"), err)
+	}
+	if len(domain) > maxDomainLen {
+		return "", "", invalid("domain is longer than %d bytes", maxDomainLen)
+	}
+	// Single labels are only valid on private networks.
+	if !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", "", invalid("domain must be fully qualified")
+	}
+
+	local = strings.ToLower(local)
+	if len(local)+1+len(domain) > maxLength {
+		return "", "", invalid("address is longer than %d bytes", maxLength)
+	}
+
+	return local, domain, nil
+}
@@ -0,0 +1,82 @@
+package emailaddr
+
+import (
+	"strings"
+)
+
+// Rule describes how a provider delivers mail, so addresses reaching the same mailbox get the same canonical key.
+type Rule struct {
+	// Domains the rule applies to, in ASCII form.
+	Domains []string
+	// CanonicalDomain replaces the domain in canonical keys, for providers with several domains for the same mailbox.
+	// Empty keeps the domain.
+	CanonicalDomain string
+	// IgnoreDots is true if the provider ignores dots in the local part.
+	IgnoreDots bool
+	// TagSeparators are the characters after which the provider ignores the rest of the local part, like "+" for
+	// "user+tag@example.com". Empty disables tags.
+	TagSeparators string
+}
+
+type Options struct {
+	Rules []Rule
+}
+
+type Parser interface {
+	// Parse validates an address typed by a user, and returns it normalized. Invalid addresses return an error
+	// matching ErrInvalidAddress, which explains the issue.
+	Parse(raw string) (Address, error)
+}
+
+// NewParser creates a parser. Canonical keys apply the rule of the domain of the address, if any, and are the
+// normalized address otherwise.
+func NewParser(options Options) Parser {
+	rules := make(map[string]Rule)
+	for _, rule := range options.Rules {
+		for _, domain := range rule.Domains {
+			rules[strings.ToLower(domain)] = rule
+		}
+	}
+
+	return &parserImpl{
+		rules: rules,
+	}
+}
+
+type parserImpl struct {
+	rules map[string]Rule
+}
+
+func (p *parserImpl) Parse(raw string) (Address, error) {
+	local, domain, err := parse(raw)
+	if err != nil {
+		return Address{}, err
+	}
+
+	return Address{local: local, domain: domain, canonical: p.canonical(local, domain)}, nil
+}
+
+func (p *parserImpl) canonical(local string, domain string) string {
+	rule, ok := p.rules[domain]
+	// Quoted local parts are kept as is, as their dots and separators are not interpreted by providers.
+	if !ok || strings.HasPrefix(local, `"`) {
+		return local + "@" + domain
+	}
+
+	if rule.TagSeparators != "" {
+		// A local part made only of a tag is kept whole.
+		if i := strings.IndexAny(local, rule.TagSeparators); i > 0 {
+			local = local[:i]
+		}
+	}
+	if rule.IgnoreDots {
+		if stripped := strings.ReplaceAll(local, ".", ""); stripped != "" {
+			local = stripped
+		}
+	}
+	if rule.CanonicalDomain != "" {
+		domain = strings.ToLower(rule.CanonicalDomain)
+	}
+
+	return local + "@" + domain
+}
@@ -0,0 +1,164 @@
+package emailaddr_test
+
+import (
+	"strings"
+	"technical-interview/pkg/emailaddr"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	parser := emailaddr.NewParser(emailaddr.Options{
+		Rules: []emailaddr.Rule{
+			{Domains: []string{"gmail.com", "googlemail.com"}, CanonicalDomain: "gmail.com", IgnoreDots: true, TagSeparators: "+"},
+			{Domains: []string{"yahoo.com"}, TagSeparators: "-"},
+		},
+	})
+
+	data := []struct {
+		name string
+
+		raw string
+
+		expect          string
+		expectCanonical string
+		expectUnicode   string
+		expectErr       error
+	}{
+		{
+			name:            "Simple",
+			raw:             "user@example.com",
+			expect:          "user@example.com",
+			expectCanonical: "user@example.com",
+		},
+		{
+			name:            "CaseFolding",
+			raw:             "  Foo.Bar@Example.COM ",
+			expect:          "foo.bar@example.com",
+			expectCanonical: "foo.bar@example.com",
+		},
+		{
+			name:            "TagsKeptWithoutRule",
+			raw:             "user+news@example.com",
+			expect:          "user+news@example.com",
+			expectCanonical: "user+news@example.com",
+		},
+		{
+			name:            "ProviderDotsAndTags",
+			raw:             "F.o.o+news@GoogleMail.com",
+			expect:          "f.o.o+news@googlemail.com",
+			expectCanonical: "foo@gmail.com",
+		},
+		{
+			name:            "ProviderSeparator",
+			raw:             "john.doe-shop@yahoo.com",
+			expect:          "john.doe-shop@yahoo.com",
+			expectCanonical: "john.doe@yahoo.com",
+		},
+		{
+			name:            "OnlyTag",
+			raw:             "+tag@gmail.com",
+			expect:          "+tag@gmail.com",
+			expectCanonical: "+tag@gmail.com",
+		},
+		{
+			name:            "InternationalizedDomain",
+			raw:             "user@Bücher.example",
+			expect:          "user@xn--bcher-kva.example",
+			expectCanonical: "user@xn--bcher-kva.example",
+			expectUnicode:   "user@bücher.example",
+		},
+		{
+			name:            "PunycodeDomain",
+			raw:             "user@xn--bcher-kva.example",
+			expect:          "user@xn--bcher-kva.example",
+			expectCanonical: "user@xn--bcher-kva.example",
+			expectUnicode:   "user@bücher.example",
+		},
+		{
+			name:            "InternationalizedLocalPart",
+			raw:             "JÖRG@example.com",
+			expect:          "jörg@example.com",
+			expectCanonical: "jörg@example.com",
+		},
+		{
+			name:            "QuotedLocalPart",
+			raw:             `"john..doe@home"@example.com`,
+			expect:          `"john..doe@home"@example.com`,
+			expectCanonical: `"john..doe@home"@example.com`,
+		},
+		{
+			name:      "MissingAt",
+			raw:       "user.example.com",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "MissingLocalPart",
+			raw:       "@example.com",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "ConsecutiveDots",
+			raw:       "john..doe@example.com",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "InvalidCharacter",
+			raw:       "john doe@example.com",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "DisplayName",
+			raw:       "John <john@example.com>",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "UnterminatedQuote",
+			raw:       `"john@example.com`,
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "DomainLiteral",
+			raw:       "user@[192.168.0.1]",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "SingleLabelDomain",
+			raw:       "user@localhost",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "InvalidDomain",
+			raw:       "user@exa_mple.com",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "LocalPartTooLong",
+			raw:       strings.Repeat("a", 65) + "@example.com",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+		{
+			name:      "Garbage",
+			raw:       "not an email",
+			expectErr: emailaddr.ErrInvalidAddress,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			address, err := parser.Parse(d.raw)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				require.Equal(t, d.expect, address.String())
+				require.Equal(t, d.expectCanonical, address.Canonical())
+				if d.expectUnicode != "" {
+					require.Equal(t, d.expectUnicode, address.Unicode())
+				}
+			} else {
+				require.True(t, address.IsZero())
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+)
+
+type changePasswordForm struct {
+	CurrentPassword string `json:"currentPassword" form:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" form:"newPassword" binding:"required"`
+	// RevokeOtherSessions logs the user out of every other device.
+	RevokeOtherSessions bool `json:"revokeOtherSessions" form:"revokeOtherSessions"`
+}
+
+type ChangePasswordHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewChangePasswordHandler(service services.ChangePasswordService) ChangePasswordHandler {
+	return &changePasswordHandlerImpl{
+		service: service,
+	}
+}
+
+type changePasswordHandlerImpl struct {
+	service services.ChangePasswordService
+}
+
+func (h *changePasswordHandlerImpl) Handle(c *gin.Context) {
+	form := new(changePasswordForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.service.Exec(c, api.GetPrincipal(c), form.CurrentPassword, form.NewPassword, form.RevokeOtherSessions, clientInfo(c))
+
+	if err != nil {
+		if abortIfThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidPassword) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidEntity) {
+			abortWithInvalidEntity(c, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+)
+
+type deleteUserForm struct {
+	Password string `json:"password" form:"password" binding:"required"`
+	// Code is either a TOTP code or a recovery code. It is only required if the user has enabled a second factor.
+	Code string `json:"code" form:"code"`
+}
+
+type DeleteUserHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewDeleteUserHandler(service services.RequestUserDeletionService) DeleteUserHandler {
+	return &deleteUserHandlerImpl{
+		service: service,
+	}
+}
+
+type deleteUserHandlerImpl struct {
+	service services.RequestUserDeletionService
+}
+
+func (h *deleteUserHandlerImpl) Handle(c *gin.Context) {
+	form := new(deleteUserForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.service.Exec(c, api.GetPrincipal(c), form.Password, form.Code, clientInfo(c))
+
+	if err != nil {
+		if abortIfThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidPassword) || errors.Is(err, services.ErrInvalidMFACode) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// The account is only deleted once the grace period ends.
+	c.JSON(http.StatusAccepted, gin.H{"deletionScheduledAt": user.DeletionScheduledAt})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/services"
+)
+
+type addEmailDomainForm struct {
+	Domain string `json:"domain" form:"domain" binding:"required"`
+	List   string `json:"list" form:"list" binding:"required"`
+}
+
+type AddEmailDomainHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewAddEmailDomainHandler(service services.AddEmailDomainService) AddEmailDomainHandler {
+	return &addEmailDomainHandlerImpl{
+		service: service,
+	}
+}
+
+type addEmailDomainHandlerImpl struct {
+	service services.AddEmailDomainService
+}
+
+func (h *addEmailDomainHandlerImpl) Handle(c *gin.Context) {
+	form := new(addEmailDomainForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err := h.service.Exec(c, form.Domain, form.List)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEntity) {
+			abortWithInvalidEntity(c, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"technical-interview/pkg/api"
 	"technical-interview/pkg/dao"
 	"technical-interview/pkg/services"
 )
@@ -37,3 +38,35 @@ func (h *getUserHandlerImpl) Handle(c *gin.Context) {
 
 	c.JSON(http.StatusOK, res)
 }
+
+type GetCurrentUserHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewGetCurrentUserHandler(service services.GetCurrentUserService) GetCurrentUserHandler {
+	return &getCurrentUserHandlerImpl{
+		service: service,
+	}
+}
+
+type getCurrentUserHandlerImpl struct {
+	service services.GetCurrentUserService
+}
+
+func (h *getCurrentUserHandlerImpl) Handle(c *gin.Context) {
+	res, err := h.service.Exec(c, api.GetPrincipal(c))
+
+	if err != nil {
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// The tag is the version PATCH /user expects in If-Match.
+	c.Header("ETag", userETag(res))
+	c.JSON(http.StatusOK, res)
+}
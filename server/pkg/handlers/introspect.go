@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/services"
+	"time"
+)
+
+type introspectForm struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	// Clients may send their credentials in the body instead of the Authorization header (RFC 6749, section 2.3.1).
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type IntrospectHandler interface {
+	Handle(c *gin.Context)
+}
+
+// NewIntrospectHandler creates the RFC 7662 introspection handler. Responses may be cached for up to cacheTTL, so
+// revoked tokens can still be reported as active for that long.
+func NewIntrospectHandler(service services.IntrospectTokenService, realm string, cacheTTL time.Duration) IntrospectHandler {
+	return &introspectHandlerImpl{
+		service:  service,
+		realm:    realm,
+		cacheTTL: cacheTTL,
+	}
+}
+
+type introspectHandlerImpl struct {
+	service  services.IntrospectTokenService
+	realm    string
+	cacheTTL time.Duration
+}
+
+// Handle answers with the RFC 7662 response. The optional scope and client_id members are never set, as tokens are only
+// issued to users through login, with access to their whole account.
+func (h *introspectHandlerImpl) Handle(c *gin.Context) {
+	form := new(introspectForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, clientSecret = form.ClientID, form.ClientSecret
+	}
+
+	res, err := h.service.Exec(c, clientID, clientSecret, form.Token)
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidClient) {
+			c.Header("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", h.realm))
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// Never let a cached response outlive the token.
+	maxAge := h.cacheTTL
+	if res.Active {
+		if remaining := time.Until(time.Unix(res.Exp, 0)); remaining < maxAge {
+			maxAge = remaining
+		}
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	c.Header("Vary", "Authorization")
+	c.JSON(http.StatusOK, res)
+}
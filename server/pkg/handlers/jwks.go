@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/services"
+	"time"
+)
+
+type JWKSHandler interface {
+	Handle(c *gin.Context)
+}
+
+// NewJWKSHandler creates a handler that exposes the token verification keys. Clients may cache the response for
+// cacheTTL, so a new key must be published at least that long before it becomes active.
+func NewJWKSHandler(service services.GetJWKSService, cacheTTL time.Duration) JWKSHandler {
+	return &jwksHandlerImpl{
+		service:  service,
+		cacheTTL: cacheTTL,
+	}
+}
+
+type jwksHandlerImpl struct {
+	service  services.GetJWKSService
+	cacheTTL time.Duration
+}
+
+func (h *jwksHandlerImpl) Handle(c *gin.Context) {
+	res, err := h.service.Exec(c)
+
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.cacheTTL.Seconds())))
+	c.JSON(http.StatusOK, res)
+}
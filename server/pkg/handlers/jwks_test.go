@@ -0,0 +1,98 @@
+package handlers_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"technical-interview/pkg/handlers"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newSigningKey(t *testing.T, id string) models.SigningKey {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return models.SigningKey{ID: id, PublicKey: publicKey, PrivateKey: privateKey}
+}
+
+func TestJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	oldKey := newSigningKey(t, "old")
+	newKey := newSigningKey(t, "new")
+
+	data := []struct {
+		name string
+
+		keys     *models.KeySet
+		cacheTTL time.Duration
+
+		expectKeys         []models.SigningKey
+		expectCacheControl string
+	}{
+		{
+			name:               "ActiveKey",
+			keys:               &models.KeySet{ActiveID: "old", Keys: []models.SigningKey{oldKey}},
+			cacheTTL:           time.Hour,
+			expectKeys:         []models.SigningKey{oldKey},
+			expectCacheControl: "public, max-age=3600",
+		},
+		{
+			// Retired keys have no private part, but are published until the tokens they signed have expired.
+			name: "RetiredKey",
+			keys: &models.KeySet{
+				ActiveID: "new",
+				Keys:     []models.SigningKey{newKey, {ID: oldKey.ID, PublicKey: oldKey.PublicKey}},
+			},
+			cacheTTL:           5 * time.Minute,
+			expectKeys:         []models.SigningKey{newKey, oldKey},
+			expectCacheControl: "public, max-age=300",
+		},
+		{
+			name:               "NoCache",
+			keys:               &models.KeySet{ActiveID: "new", Keys: []models.SigningKey{newKey}},
+			expectKeys:         []models.SigningKey{newKey},
+			expectCacheControl: "public, max-age=0",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/.well-known/jwks.json", handlers.NewJWKSHandler(services.NewGetJWKSService(d.keys), d.cacheTTL).Handle)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Equal(t, d.expectCacheControl, recorder.Header().Get("Cache-Control"))
+
+			// Only the documented members are published, never the private key.
+			var res struct {
+				Keys []map[string]string `json:"keys"`
+			}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+			require.Len(t, res.Keys, len(d.expectKeys))
+
+			for i, key := range d.expectKeys {
+				require.Equal(t, map[string]string{
+					"kty": "OKP",
+					"crv": "Ed25519",
+					"kid": key.ID,
+					"x":   base64.RawURLEncoding.EncodeToString(key.PublicKey),
+					"use": "sig",
+					"alg": "EdDSA",
+				}, res.Keys[i])
+			}
+		})
+	}
+}
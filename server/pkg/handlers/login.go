@@ -4,12 +4,15 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"technical-interview/pkg/models"
 	"technical-interview/pkg/services"
 )
 
 type loginForm struct {
-	Email    string `json:"email" form:"email" binding:"required"`
-	Password string `json:"password" form:"password" binding:"required"`
+	// Identifier is the email or the username of the user. Email is kept for older clients.
+	Identifier string `json:"identifier" form:"identifier" binding:"required_without=Email"`
+	Email      string `json:"email" form:"email"`
+	Password   string `json:"password" form:"password" binding:"required"`
 }
 
 type LoginHandler interface {
@@ -34,10 +37,18 @@ func (h *loginHandlerImpl) Handle(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.service.Exec(c, form.Email, form.Password)
+	identifier := form.Identifier
+	if identifier == "" {
+		identifier = form.Email
+	}
+
+	res, err := h.service.Exec(c, identifier, form.Password, clientInfo(c))
 
 	if err != nil {
-		if errors.Is(err, services.ErrInvalidPassword) {
+		if abortIfThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidCredentials) || errors.Is(err, services.ErrEmailNotVerified) || errors.Is(err, services.ErrUserDeleted) {
 			_ = c.AbortWithError(http.StatusForbidden, err)
 			return
 		}
@@ -50,8 +61,26 @@ func (h *loginHandlerImpl) Handle(c *gin.Context) {
 		return
 	}
 
+	respondWithLoginResult(c, res)
+}
+
+// respondWithLoginResult sends the credentials of a successful login, or the challenge of the second factor.
+func respondWithLoginResult(c *gin.Context, res *models.LoginResult) {
+	// The user is only returned once the second factor is verified.
+	if res.MFAChallenge != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"mfaRequired":       true,
+			"mfaToken":          res.MFAChallenge.Token,
+			"mfaTokenExpiresAt": res.MFAChallenge.ExpiresAt,
+			"methods":           res.MFAChallenge.Methods,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": token,
+		"user":                  res.User,
+		"token":                 res.Credentials.AccessToken,
+		"refreshToken":          res.Credentials.RefreshToken,
+		"refreshTokenExpiresAt": res.Credentials.RefreshTokenExpiresAt,
 	})
 }
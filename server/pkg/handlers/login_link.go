@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/services"
+	"time"
+)
+
+const (
+	loginLinkCookie     = "login_link_nonce"
+	loginLinkCookiePath = "/user/login/link"
+)
+
+// CookieOptions are the attributes of the cookies set by the API.
+type CookieOptions struct {
+	Secure   bool
+	SameSite http.SameSite
+}
+
+type requestLoginLinkForm struct {
+	Email string `json:"email" form:"email" binding:"required"`
+}
+
+type RequestLoginLinkHandler interface {
+	Handle(c *gin.Context)
+}
+
+// NewRequestLoginLinkHandler creates the handler. The nonce cookie expires along with the link, after ttl.
+func NewRequestLoginLinkHandler(service services.RequestLoginLinkService, ttl time.Duration, cookie CookieOptions) RequestLoginLinkHandler {
+	return &requestLoginLinkHandlerImpl{
+		service: service,
+		ttl:     ttl,
+		cookie:  cookie,
+	}
+}
+
+type requestLoginLinkHandlerImpl struct {
+	service services.RequestLoginLinkService
+	ttl     time.Duration
+	cookie  CookieOptions
+}
+
+func (h *requestLoginLinkHandlerImpl) Handle(c *gin.Context) {
+	form := new(requestLoginLinkForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	nonce, err := h.service.Exec(c, form.Email)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// The link only works in the browser holding the nonce, which scripts can't read.
+	c.SetSameSite(h.cookie.SameSite)
+	c.SetCookie(loginLinkCookie, nonce, int(h.ttl.Seconds()), loginLinkCookiePath, "", h.cookie.Secure, true)
+
+	// Always accepted, whether the email exists or not.
+	c.Status(http.StatusAccepted)
+}
+
+type verifyLoginLinkForm struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+type VerifyLoginLinkHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewVerifyLoginLinkHandler(service services.VerifyLoginLinkService, cookie CookieOptions) VerifyLoginLinkHandler {
+	return &verifyLoginLinkHandlerImpl{
+		service: service,
+		cookie:  cookie,
+	}
+}
+
+type verifyLoginLinkHandlerImpl struct {
+	service services.VerifyLoginLinkService
+	cookie  CookieOptions
+}
+
+func (h *verifyLoginLinkHandlerImpl) Handle(c *gin.Context) {
+	form := new(verifyLoginLinkForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	nonce, err := c.Cookie(loginLinkCookie)
+	if err != nil {
+		_ = c.AbortWithError(http.StatusUnauthorized, errors.Join(services.ErrInvalidLoginLink, err))
+		return
+	}
+
+	res, err := h.service.Exec(c, form.Token, nonce, clientInfo(c))
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidLoginLink) || errors.Is(err, services.ErrUserDeleted) {
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// The nonce is only good for one link.
+	c.SetSameSite(h.cookie.SameSite)
+	c.SetCookie(loginLinkCookie, "", -1, loginLinkCookiePath, "", h.cookie.Secure, true)
+
+	respondWithLoginResult(c, res)
+}
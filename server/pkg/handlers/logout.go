@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/services"
+)
+
+type LogoutHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewLogoutHandler(service services.LogoutService) LogoutHandler {
+	return &logoutHandlerImpl{
+		service: service,
+	}
+}
+
+type logoutHandlerImpl struct {
+	service services.LogoutService
+}
+
+func (h *logoutHandlerImpl) Handle(c *gin.Context) {
+	err := h.service.Exec(c, api.GetPrincipal(c))
+
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type LogoutAllHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewLogoutAllHandler(service services.LogoutAllService) LogoutAllHandler {
+	return &logoutAllHandlerImpl{
+		service: service,
+	}
+}
+
+type logoutAllHandlerImpl struct {
+	service services.LogoutAllService
+}
+
+func (h *logoutAllHandlerImpl) Handle(c *gin.Context) {
+	err := h.service.Exec(c, api.GetPrincipal(c))
+
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+)
+
+type EnrollTOTPHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewEnrollTOTPHandler(service services.EnrollTOTPService) EnrollTOTPHandler {
+	return &enrollTOTPHandlerImpl{
+		service: service,
+	}
+}
+
+type enrollTOTPHandlerImpl struct {
+	service services.EnrollTOTPService
+}
+
+func (h *enrollTOTPHandlerImpl) Handle(c *gin.Context) {
+	res, err := h.service.Exec(c, api.GetPrincipal(c))
+
+	if err != nil {
+		if errors.Is(err, dao.ErrMFAAlreadyEnabled) {
+			_ = c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// The secret must not be kept by any cache.
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, res)
+}
+
+type confirmTOTPForm struct {
+	Code string `json:"code" form:"code" binding:"required"`
+}
+
+type ConfirmTOTPHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewConfirmTOTPHandler(service services.ConfirmTOTPService) ConfirmTOTPHandler {
+	return &confirmTOTPHandlerImpl{
+		service: service,
+	}
+}
+
+type confirmTOTPHandlerImpl struct {
+	service services.ConfirmTOTPService
+}
+
+func (h *confirmTOTPHandlerImpl) Handle(c *gin.Context) {
+	form := new(confirmTOTPForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	recoveryCodes, err := h.service.Exec(c, api.GetPrincipal(c), form.Code)
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, dao.ErrMFAAlreadyEnabled) || errors.Is(err, dao.ErrNoPendingTOTP) {
+			_ = c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": recoveryCodes})
+}
+
+type disableMFAForm struct {
+	Password string `json:"password" form:"password" binding:"required"`
+	// Code is either a TOTP code or a recovery code.
+	Code string `json:"code" form:"code" binding:"required"`
+}
+
+type DisableMFAHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewDisableMFAHandler(service services.DisableMFAService) DisableMFAHandler {
+	return &disableMFAHandlerImpl{
+		service: service,
+	}
+}
+
+type disableMFAHandlerImpl struct {
+	service services.DisableMFAService
+}
+
+func (h *disableMFAHandlerImpl) Handle(c *gin.Context) {
+	form := new(disableMFAForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.service.Exec(c, api.GetPrincipal(c), form.Password, form.Code, clientInfo(c))
+
+	if err != nil {
+		if abortIfThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidPassword) || errors.Is(err, services.ErrInvalidMFACode) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, dao.ErrMFANotEnabled) {
+			_ = c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type loginMFAForm struct {
+	MFAToken string `json:"mfaToken" form:"mfaToken" binding:"required"`
+	// Code is either a TOTP code or a recovery code.
+	Code string `json:"code" form:"code" binding:"required"`
+}
+
+type LoginMFAHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewLoginMFAHandler(service services.LoginMFAService) LoginMFAHandler {
+	return &loginMFAHandlerImpl{
+		service: service,
+	}
+}
+
+type loginMFAHandlerImpl struct {
+	service services.LoginMFAService
+}
+
+func (h *loginMFAHandlerImpl) Handle(c *gin.Context) {
+	form := new(loginMFAForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user, credentials, err := h.service.Exec(c, form.MFAToken, form.Code, clientInfo(c))
+
+	if err != nil {
+		if abortIfThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidMFAChallenge) {
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, dao.ErrMFANotEnabled) || errors.Is(err, services.ErrUserDeleted) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":                  user,
+		"token":                 credentials.AccessToken,
+		"refreshToken":          credentials.RefreshToken,
+		"refreshTokenExpiresAt": credentials.RefreshTokenExpiresAt,
+	})
+}
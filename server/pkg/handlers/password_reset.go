@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+)
+
+type forgotPasswordForm struct {
+	Email string `json:"email" form:"email" binding:"required"`
+}
+
+type ForgotPasswordHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewForgotPasswordHandler(service services.ForgotPasswordService) ForgotPasswordHandler {
+	return &forgotPasswordHandlerImpl{
+		service: service,
+	}
+}
+
+type forgotPasswordHandlerImpl struct {
+	service services.ForgotPasswordService
+}
+
+func (h *forgotPasswordHandlerImpl) Handle(c *gin.Context) {
+	form := new(forgotPasswordForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.Exec(c, form.Email); err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	// Always accepted, whether the email exists or not.
+	c.Status(http.StatusAccepted)
+}
+
+type resetPasswordForm struct {
+	Token    string `json:"token" form:"token" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+type ResetPasswordHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewResetPasswordHandler(service services.ResetPasswordService) ResetPasswordHandler {
+	return &resetPasswordHandlerImpl{
+		service: service,
+	}
+}
+
+type resetPasswordHandlerImpl struct {
+	service services.ResetPasswordService
+}
+
+func (h *resetPasswordHandlerImpl) Handle(c *gin.Context) {
+	form := new(resetPasswordForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.service.Exec(c, form.Token, form.Password)
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidEntity) {
+			abortWithInvalidEntity(c, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/services"
+)
+
+type refreshTokenForm struct {
+	RefreshToken string `json:"refreshToken" form:"refreshToken" binding:"required"`
+}
+
+type RefreshTokenHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewRefreshTokenHandler(service services.RefreshTokenService) RefreshTokenHandler {
+	return &refreshTokenHandlerImpl{
+		service: service,
+	}
+}
+
+type refreshTokenHandlerImpl struct {
+	service services.RefreshTokenService
+}
+
+func (h *refreshTokenHandlerImpl) Handle(c *gin.Context) {
+	form := new(refreshTokenForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	credentials, err := h.service.Exec(c, form.RefreshToken)
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, credentials)
+}
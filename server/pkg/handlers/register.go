@@ -36,20 +36,26 @@ func (h *registerHandlerImpl) Handle(c *gin.Context) {
 		return
 	}
 
-	user, token, err := h.service.Exec(c, form.Email, form.Password, form.Username)
+	user, credentials, err := h.service.Exec(c, form.Email, form.Password, form.Username, clientInfo(c))
 
 	if err != nil {
-		if errors.Is(err, dao.ErrEmailTaken) {
+		if errors.Is(err, dao.ErrEmailTaken) || errors.Is(err, dao.ErrUsernameTaken) {
 			_ = c.AbortWithError(http.StatusConflict, err)
 			return
 		}
+		if errors.Is(err, services.ErrInvalidEntity) {
+			abortWithInvalidEntity(c, err)
+			return
+		}
 
 		_ = c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"user":  user,
-		"token": token,
+		"user":                  user,
+		"token":                 credentials.AccessToken,
+		"refreshToken":          credentials.RefreshToken,
+		"refreshTokenExpiresAt": credentials.RefreshTokenExpiresAt,
 	})
 }
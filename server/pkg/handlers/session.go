@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+)
+
+type ListSessionsHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewListSessionsHandler(service services.ListSessionsService) ListSessionsHandler {
+	return &listSessionsHandlerImpl{
+		service: service,
+	}
+}
+
+type listSessionsHandlerImpl struct {
+	service services.ListSessionsService
+}
+
+func (h *listSessionsHandlerImpl) Handle(c *gin.Context) {
+	res, err := h.service.Exec(c, api.GetPrincipal(c))
+
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": res})
+}
+
+type RevokeSessionHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewRevokeSessionHandler(service services.RevokeSessionService) RevokeSessionHandler {
+	return &revokeSessionHandlerImpl{
+		service: service,
+	}
+}
+
+type revokeSessionHandlerImpl struct {
+	service services.RevokeSessionService
+}
+
+func (h *revokeSessionHandlerImpl) Handle(c *gin.Context) {
+	err := h.service.Exec(c, api.GetPrincipal(c), c.Param("id"))
+
+	if err != nil {
+		if errors.Is(err, dao.ErrSessionNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
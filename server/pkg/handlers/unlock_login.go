@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/services"
+)
+
+type unlockLoginForm struct {
+	Email string `json:"email" form:"email" binding:"required_without=IP"`
+	IP    string `json:"ip" form:"ip" binding:"omitempty,ip"`
+}
+
+type UnlockLoginHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewUnlockLoginHandler(service services.LoginProtectionService) UnlockLoginHandler {
+	return &unlockLoginHandlerImpl{
+		service: service,
+	}
+}
+
+type unlockLoginHandlerImpl struct {
+	service services.LoginProtectionService
+}
+
+func (h *unlockLoginHandlerImpl) Handle(c *gin.Context) {
+	form := new(unlockLoginForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.service.Unlock(c, form.Email, form.IP); err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
 	"technical-interview/pkg/services"
 )
 
@@ -33,11 +35,19 @@ func (h *updateEmailHandlerImpl) Handle(c *gin.Context) {
 		return
 	}
 
-	err := h.service.UpdateEmail(c, c.GetHeader("Authorization"), form.Email)
+	err := h.service.UpdateEmail(c, api.GetPrincipal(c), form.Email)
 
 	if err != nil {
-		if errors.Is(err, services.ErrInvalidCredentials) {
-			_ = c.AbortWithError(http.StatusForbidden, err)
+		if errors.Is(err, dao.ErrEmailTaken) {
+			_ = c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidEntity) {
+			abortWithInvalidEntity(c, err)
 			return
 		}
 
@@ -45,5 +55,6 @@ func (h *updateEmailHandlerImpl) Handle(c *gin.Context) {
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	// The change is only applied once the new address is verified.
+	c.Status(http.StatusAccepted)
 }
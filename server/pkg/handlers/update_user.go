@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+)
+
+var (
+	ErrMissingIfMatch = errors.New("missing If-Match header")
+)
+
+type UpdateUserHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewUpdateUserHandler(service services.UpdateUserService) UpdateUserHandler {
+	return &updateUserHandlerImpl{
+		service: service,
+	}
+}
+
+type updateUserHandlerImpl struct {
+	service services.UpdateUserService
+}
+
+// Handle applies a JSON Merge Patch to the profile of the user. The If-Match header is required, with the ETag returned
+// by GET /user/me or the previous update, so clients can't overwrite changes they haven't seen.
+func (h *updateUserHandlerImpl) Handle(c *gin.Context) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		_ = c.AbortWithError(http.StatusPreconditionRequired, ErrMissingIfMatch)
+		return
+	}
+
+	ifUpdatedAt, ok := parseIfMatch(ifMatch)
+	if !ok {
+		_ = c.AbortWithError(http.StatusPreconditionFailed, dao.ErrUserModified)
+		return
+	}
+
+	patch := make(map[string]json.RawMessage)
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := h.service.Exec(c, api.GetPrincipal(c), patch, ifUpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, dao.ErrUserModified) {
+			_ = c.AbortWithError(http.StatusPreconditionFailed, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUsernameTaken) {
+			_ = c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidEntity) {
+			abortWithInvalidEntity(c, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("ETag", userETag(user))
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/services"
+)
+
+type usernameAvailableForm struct {
+	Username string `form:"username" binding:"required"`
+}
+
+type UsernameAvailableHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewUsernameAvailableHandler(service services.UsernameAvailableService) UsernameAvailableHandler {
+	return &usernameAvailableHandlerImpl{
+		service: service,
+	}
+}
+
+type usernameAvailableHandlerImpl struct {
+	service services.UsernameAvailableService
+}
+
+func (h *usernameAvailableHandlerImpl) Handle(c *gin.Context) {
+	form := new(usernameAvailableForm)
+
+	if err := c.ShouldBindQuery(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	available, err := h.service.Exec(c, form.Username)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEntity) {
+			abortWithInvalidEntity(c, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"available": available})
+}
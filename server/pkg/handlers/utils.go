@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"time"
+)
+
+// abortWithInvalidEntity answers with a 422. When the error lists violations, they are sent to the client so it can
+// explain what to fix.
+func abortWithInvalidEntity(c *gin.Context, err error) {
+	_ = c.Error(err)
+
+	var validationErr *services.ValidationError
+	if !errors.As(err, &validationErr) {
+		c.AbortWithStatus(http.StatusUnprocessableEntity)
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+		"error":   services.ErrInvalidEntity.Error(),
+		"details": validationErr.Violations,
+	})
+}
+
+// abortIfThrottled answers with a 429 and a Retry-After header if the error is a ThrottleError, and returns whether it
+// did.
+func abortIfThrottled(c *gin.Context, err error) bool {
+	var throttleErr *services.ThrottleError
+	if !errors.As(err, &throttleErr) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttleErr.RetryAfter.Seconds()))))
+	_ = c.AbortWithError(http.StatusTooManyRequests, err)
+	return true
+}
+
+// clientInfo describes the client of the request, to record it on the sessions it opens.
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+// userETag returns the entity tag of the user, derived from the update time of its document, so it changes on every
+// write.
+func userETag(user *models.User) string {
+	return `"` + strconv.FormatInt(user.UpdatedAt.UnixNano(), 36) + `"`
+}
+
+// parseIfMatch returns the version of the user required by an If-Match header, or false if the header can't match
+// any version. "*" matches every version, and returns a zero time. Weak tags never match, as If-Match uses the strong
+// comparison.
+func parseIfMatch(header string) (time.Time, bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return time.Time{}, true
+	}
+
+	tag, ok := strings.CutPrefix(header, `"`)
+	if !ok {
+		return time.Time{}, false
+	}
+	tag, ok = strings.CutSuffix(tag, `"`)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	nanos, err := strconv.ParseInt(tag, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, nanos), true
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+)
+
+type verifyEmailForm struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+type VerifyEmailHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewVerifyEmailHandler(service services.VerifyEmailService) VerifyEmailHandler {
+	return &verifyEmailHandlerImpl{
+		service: service,
+	}
+}
+
+type verifyEmailHandlerImpl struct {
+	service services.VerifyEmailService
+}
+
+func (h *verifyEmailHandlerImpl) Handle(c *gin.Context) {
+	form := new(verifyEmailForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.service.Exec(c, form.Token)
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, dao.ErrEmailTaken) {
+			_ = c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
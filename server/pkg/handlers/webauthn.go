@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"technical-interview/pkg/api"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/services"
+	"technical-interview/pkg/webauthn"
+)
+
+type BeginWebAuthnRegistrationHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewBeginWebAuthnRegistrationHandler(service services.BeginWebAuthnRegistrationService) BeginWebAuthnRegistrationHandler {
+	return &beginWebAuthnRegistrationHandlerImpl{
+		service: service,
+	}
+}
+
+type beginWebAuthnRegistrationHandlerImpl struct {
+	service services.BeginWebAuthnRegistrationService
+}
+
+func (h *beginWebAuthnRegistrationHandlerImpl) Handle(c *gin.Context) {
+	res, err := h.service.Exec(c, api.GetPrincipal(c))
+
+	if err != nil {
+		if errors.Is(err, dao.ErrUserNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+type finishWebAuthnRegistrationForm struct {
+	// Name helps the user tell their authenticators apart. Defaults to "Passkey".
+	Name       string                          `json:"name" binding:"max=64"`
+	Credential webauthn.RegistrationCredential `json:"credential" binding:"required"`
+}
+
+type FinishWebAuthnRegistrationHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewFinishWebAuthnRegistrationHandler(service services.FinishWebAuthnRegistrationService) FinishWebAuthnRegistrationHandler {
+	return &finishWebAuthnRegistrationHandlerImpl{
+		service: service,
+	}
+}
+
+type finishWebAuthnRegistrationHandlerImpl struct {
+	service services.FinishWebAuthnRegistrationService
+}
+
+func (h *finishWebAuthnRegistrationHandlerImpl) Handle(c *gin.Context) {
+	form := new(finishWebAuthnRegistrationForm)
+
+	if err := c.ShouldBindJSON(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := h.service.Exec(c, api.GetPrincipal(c), form.Name, &form.Credential)
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWebAuthnChallenge) || errors.Is(err, services.ErrInvalidWebAuthnCredential) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+		if errors.Is(err, dao.ErrWebAuthnCredentialExists) {
+			_ = c.AbortWithError(http.StatusConflict, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, res)
+}
+
+type ListWebAuthnCredentialsHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewListWebAuthnCredentialsHandler(service services.ListWebAuthnCredentialsService) ListWebAuthnCredentialsHandler {
+	return &listWebAuthnCredentialsHandlerImpl{
+		service: service,
+	}
+}
+
+type listWebAuthnCredentialsHandlerImpl struct {
+	service services.ListWebAuthnCredentialsService
+}
+
+func (h *listWebAuthnCredentialsHandlerImpl) Handle(c *gin.Context) {
+	res, err := h.service.Exec(c, api.GetPrincipal(c))
+
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": res})
+}
+
+type renameWebAuthnCredentialForm struct {
+	Name string `json:"name" form:"name" binding:"required,max=64"`
+}
+
+type RenameWebAuthnCredentialHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewRenameWebAuthnCredentialHandler(service services.RenameWebAuthnCredentialService) RenameWebAuthnCredentialHandler {
+	return &renameWebAuthnCredentialHandlerImpl{
+		service: service,
+	}
+}
+
+type renameWebAuthnCredentialHandlerImpl struct {
+	service services.RenameWebAuthnCredentialService
+}
+
+func (h *renameWebAuthnCredentialHandlerImpl) Handle(c *gin.Context) {
+	form := new(renameWebAuthnCredentialForm)
+
+	if err := c.ShouldBind(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	err := h.service.Exec(c, api.GetPrincipal(c), c.Param("id"), form.Name)
+
+	if err != nil {
+		if errors.Is(err, dao.ErrWebAuthnCredentialNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type DeleteWebAuthnCredentialHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewDeleteWebAuthnCredentialHandler(service services.DeleteWebAuthnCredentialService) DeleteWebAuthnCredentialHandler {
+	return &deleteWebAuthnCredentialHandlerImpl{
+		service: service,
+	}
+}
+
+type deleteWebAuthnCredentialHandlerImpl struct {
+	service services.DeleteWebAuthnCredentialService
+}
+
+func (h *deleteWebAuthnCredentialHandlerImpl) Handle(c *gin.Context) {
+	err := h.service.Exec(c, api.GetPrincipal(c), c.Param("id"))
+
+	if err != nil {
+		if errors.Is(err, dao.ErrWebAuthnCredentialNotFound) {
+			_ = c.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type BeginWebAuthnLoginHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewBeginWebAuthnLoginHandler(service services.BeginWebAuthnLoginService) BeginWebAuthnLoginHandler {
+	return &beginWebAuthnLoginHandlerImpl{
+		service: service,
+	}
+}
+
+type beginWebAuthnLoginHandlerImpl struct {
+	service services.BeginWebAuthnLoginService
+}
+
+func (h *beginWebAuthnLoginHandlerImpl) Handle(c *gin.Context) {
+	res, err := h.service.Exec(c)
+
+	if err != nil {
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+type finishWebAuthnLoginForm struct {
+	Credential webauthn.AssertionCredential `json:"credential" binding:"required"`
+}
+
+type FinishWebAuthnLoginHandler interface {
+	Handle(c *gin.Context)
+}
+
+func NewFinishWebAuthnLoginHandler(service services.FinishWebAuthnLoginService) FinishWebAuthnLoginHandler {
+	return &finishWebAuthnLoginHandlerImpl{
+		service: service,
+	}
+}
+
+type finishWebAuthnLoginHandlerImpl struct {
+	service services.FinishWebAuthnLoginService
+}
+
+func (h *finishWebAuthnLoginHandlerImpl) Handle(c *gin.Context) {
+	form := new(finishWebAuthnLoginForm)
+
+	if err := c.ShouldBindJSON(form); err != nil {
+		_ = c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	user, credentials, err := h.service.Exec(c, &form.Credential, clientInfo(c))
+
+	if err != nil {
+		if abortIfThrottled(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidWebAuthnChallenge) {
+			_ = c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidWebAuthnCredential) || errors.Is(err, services.ErrEmailNotVerified) || errors.Is(err, services.ErrUserDeleted) {
+			_ = c.AbortWithError(http.StatusForbidden, err)
+			return
+		}
+
+		_ = c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user":                  user,
+		"token":                 credentials.AccessToken,
+		"refreshToken":          credentials.RefreshToken,
+		"refreshTokenExpiresAt": credentials.RefreshTokenExpiresAt,
+	})
+}
@@ -0,0 +1,95 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+type argon2idHash struct {
+	params   Argon2idParams
+	pepperID string
+	salt     []byte
+	key      []byte
+}
+
+// hashArgon2id returns the PHC string of the password, for example:
+// $argon2id$v=19$m=65536,t=3,p=2,keyid=2024$c2FsdA$aGFzaA
+func hashArgon2id(password string, params Argon2idParams, pepper string, pepperID string) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(applyPepper(password, pepper), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	encodedParams := fmt.Sprintf("m=%d,t=%d,p=%d", params.Memory, params.Iterations, params.Parallelism)
+	if pepper != "" {
+		encodedParams += ",keyid=" + pepperID
+	}
+
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, encodedParams, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func decodeArgon2id(hash string) (*argon2idHash, error) {
+	// The string starts with a separator, so the first part is empty.
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, ErrUnsupportedHash
+	}
+
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return nil, fmt.Errorf("%w: unsupported argon2 version %s", ErrUnsupportedHash, parts[2])
+	}
+
+	output := new(argon2idHash)
+
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, ErrUnsupportedHash
+		}
+
+		if name == "keyid" {
+			output.pepperID = value
+			continue
+		}
+
+		number, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedHash, err)
+		}
+
+		switch name {
+		case "m":
+			output.params.Memory = uint32(number)
+		case "t":
+			output.params.Iterations = uint32(number)
+		case "p":
+			if number > 255 {
+				return nil, ErrUnsupportedHash
+			}
+			output.params.Parallelism = uint8(number)
+		default:
+			return nil, fmt.Errorf("%w: unknown parameter %s", ErrUnsupportedHash, name)
+		}
+	}
+
+	if output.params.Memory == 0 || output.params.Iterations == 0 || output.params.Parallelism == 0 {
+		return nil, ErrUnsupportedHash
+	}
+
+	var err error
+
+	if output.salt, err = b64.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedHash, err)
+	}
+	if output.key, err = b64.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedHash, err)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,182 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+var (
+	ErrUnsupportedHash = errors.New("unsupported password hash")
+	ErrUnknownPepper   = errors.New("password hash uses an unknown pepper")
+	ErrInvalidOptions  = errors.New("invalid password hasher options")
+)
+
+// Argon2idParams are the cost parameters of argon2id. See RFC 9106 for recommended values.
+type Argon2idParams struct {
+	// Memory is the memory cost, in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Options configures how new passwords are hashed. Hashes created with other options can still be verified.
+type Options struct {
+	// Algorithm used to hash new passwords, either argon2id or bcrypt.
+	Algorithm  string
+	Argon2id   Argon2idParams
+	BcryptCost int
+	// Pepper is a server-side secret mixed into argon2id hashes, so a leaked database is not enough to crack them.
+	// It is stored outside the database, and referenced in hashes by PepperID. Optional.
+	Pepper   string
+	PepperID string
+}
+
+// PasswordHasher hashes passwords into PHC strings, which embed the algorithm and its parameters.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify checks the password against the hash. needsRehash is true when the hash was created with outdated
+	// options, and the password should be hashed again while it is known.
+	Verify(password string, hash string) (ok bool, needsRehash bool, err error)
+}
+
+func NewPasswordHasher(options Options) (PasswordHasher, error) {
+	switch options.Algorithm {
+	case AlgorithmArgon2id:
+		params := options.Argon2id
+		if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 || params.SaltLength < 8 || params.KeyLength < 16 {
+			return nil, fmt.Errorf("%w: argon2id parameters are too weak", ErrInvalidOptions)
+		}
+	case AlgorithmBcrypt:
+		if options.BcryptCost < bcrypt.MinCost || options.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("%w: bcrypt cost must be between %d and %d", ErrInvalidOptions, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		// bcrypt hashes have no room to reference a pepper.
+		if options.Pepper != "" {
+			return nil, fmt.Errorf("%w: pepper is only supported with argon2id", ErrInvalidOptions)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidOptions, options.Algorithm)
+	}
+
+	if options.Pepper == "" {
+		options.PepperID = ""
+	} else if options.PepperID == "" {
+		return nil, fmt.Errorf("%w: pepper requires an id", ErrInvalidOptions)
+	}
+
+	return &passwordHasherImpl{options: options}, nil
+}
+
+type passwordHasherImpl struct {
+	options Options
+}
+
+func (h *passwordHasherImpl) Hash(password string) (string, error) {
+	if h.options.Algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.options.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+
+		return string(hash), nil
+	}
+
+	return hashArgon2id(password, h.options.Argon2id, h.options.Pepper, h.options.PepperID)
+}
+
+func (h *passwordHasherImpl) Verify(password string, hash string) (bool, bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return h.verifyArgon2id(password, hash)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return h.verifyBcrypt(password, hash)
+	default:
+		return false, false, ErrUnsupportedHash
+	}
+}
+
+func (h *passwordHasherImpl) verifyBcrypt(password string, hash string) (bool, bool, error) {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+
+		return false, false, err
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, err
+	}
+
+	needsRehash := h.options.Algorithm != AlgorithmBcrypt || cost < h.options.BcryptCost
+	return true, needsRehash, nil
+}
+
+func (h *passwordHasherImpl) verifyArgon2id(password string, hash string) (bool, bool, error) {
+	decoded, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, false, err
+	}
+
+	pepper := ""
+	if decoded.pepperID != "" {
+		if decoded.pepperID != h.options.PepperID {
+			return false, false, ErrUnknownPepper
+		}
+
+		pepper = h.options.Pepper
+	}
+
+	key := argon2.IDKey(
+		applyPepper(password, pepper),
+		decoded.salt,
+		decoded.params.Iterations,
+		decoded.params.Memory,
+		decoded.params.Parallelism,
+		uint32(len(decoded.key)),
+	)
+	if subtle.ConstantTimeCompare(key, decoded.key) != 1 {
+		return false, false, nil
+	}
+
+	current := h.options.Argon2id
+	needsRehash := h.options.Algorithm != AlgorithmArgon2id ||
+		decoded.pepperID != h.options.PepperID ||
+		decoded.params.Memory < current.Memory ||
+		decoded.params.Iterations < current.Iterations ||
+		decoded.params.Parallelism < current.Parallelism ||
+		uint32(len(decoded.salt)) < current.SaltLength ||
+		uint32(len(decoded.key)) < current.KeyLength
+
+	return true, needsRehash, nil
+}
+
+// applyPepper mixes the pepper into the password with HMAC-SHA256. Without a pepper, the password is used as-is.
+func applyPepper(password string, pepper string) []byte {
+	if pepper == "" {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// b64 is the encoding of salts and keys in PHC strings.
+var b64 = base64.RawStdEncoding
@@ -0,0 +1,149 @@
+package hasher_test
+
+import (
+	"strings"
+	"technical-interview/pkg/hasher"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var argon2idParams = hasher.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newHasher(t *testing.T, options hasher.Options) hasher.PasswordHasher {
+	passwordHasher, err := hasher.NewPasswordHasher(options)
+	require.NoError(t, err)
+	return passwordHasher
+}
+
+func TestPasswordHasher(t *testing.T) {
+	current := newHasher(t, hasher.Options{Algorithm: hasher.AlgorithmArgon2id, Argon2id: argon2idParams})
+
+	weakerParams := argon2idParams
+	weakerParams.Memory = 512
+	weakerParams.Iterations = 1
+
+	peppered := newHasher(t, hasher.Options{
+		Algorithm: hasher.AlgorithmArgon2id,
+		Argon2id:  argon2idParams,
+		Pepper:    "pepper",
+		PepperID:  "1",
+	})
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	data := []struct {
+		name string
+
+		hasher hasher.PasswordHasher
+		// hash is generated by source when not set.
+		hash   string
+		source hasher.PasswordHasher
+
+		password string
+
+		expectOK          bool
+		expectNeedsRehash bool
+		expectErr         error
+	}{
+		{
+			name:     "Success",
+			hasher:   current,
+			source:   current,
+			password: "password",
+			expectOK: true,
+		},
+		{
+			name:     "WrongPassword",
+			hasher:   current,
+			source:   current,
+			password: "other",
+		},
+		{
+			name:              "OutdatedParams",
+			hasher:            current,
+			source:            newHasher(t, hasher.Options{Algorithm: hasher.AlgorithmArgon2id, Argon2id: weakerParams}),
+			password:          "password",
+			expectOK:          true,
+			expectNeedsRehash: true,
+		},
+		{
+			name:              "Bcrypt",
+			hasher:            current,
+			hash:              string(bcryptHash),
+			password:          "password",
+			expectOK:          true,
+			expectNeedsRehash: true,
+		},
+		{
+			name:     "BcryptWrongPassword",
+			hasher:   current,
+			hash:     string(bcryptHash),
+			password: "other",
+		},
+		{
+			name:     "Peppered",
+			hasher:   peppered,
+			source:   peppered,
+			password: "password",
+			expectOK: true,
+		},
+		{
+			name:              "PepperAdded",
+			hasher:            peppered,
+			source:            current,
+			password:          "password",
+			expectOK:          true,
+			expectNeedsRehash: true,
+		},
+		{
+			name:      "UnknownPepper",
+			hasher:    current,
+			source:    peppered,
+			password:  "password",
+			expectErr: hasher.ErrUnknownPepper,
+		},
+		{
+			name:      "Unsupported",
+			hasher:    current,
+			hash:      "plaintext",
+			password:  "plaintext",
+			expectErr: hasher.ErrUnsupportedHash,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			hash := d.hash
+			if hash == "" {
+				var err error
+				hash, err = d.source.Hash("password")
+				require.NoError(t, err)
+			}
+
+			ok, needsRehash, err := d.hasher.Verify(d.password, hash)
+			require.ErrorIs(t, err, d.expectErr)
+			require.Equal(t, d.expectOK, ok)
+			require.Equal(t, d.expectNeedsRehash, needsRehash)
+		})
+	}
+}
+
+func TestPasswordHasherFormat(t *testing.T) {
+	passwordHasher := newHasher(t, hasher.Options{
+		Algorithm: hasher.AlgorithmArgon2id,
+		Argon2id:  argon2idParams,
+		Pepper:    "pepper",
+		PepperID:  "2024",
+	})
+
+	hash, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1,keyid=2024$"), hash)
+
+	other, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+	require.NotEqual(t, hash, other, "salts should be random")
+}
@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"technical-interview/pkg/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NewFileMailer creates a mailer that writes every email as a JSON file in dir, so tests and developers can read
+// them.
+func NewFileMailer(dir string) Mailer {
+	return &fileMailerImpl{
+		dir: dir,
+	}
+}
+
+type fileMailerImpl struct {
+	dir string
+}
+
+func (m *fileMailerImpl) Send(_ context.Context, mail *models.Mail) error {
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(mail, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Prefix with the date, so files are listed in the order they were sent.
+	name := fmt.Sprintf("%s-%s.json", time.Now().UTC().Format("20060102T150405.000000000"), uuid.New())
+
+	return os.WriteFile(filepath.Join(m.dir, name), content, 0o600)
+}
@@ -0,0 +1,30 @@
+package mail
+
+import (
+	"context"
+	"technical-interview/pkg/models"
+
+	"github.com/rs/zerolog"
+)
+
+// NewLogMailer creates a mailer that writes emails to the logs instead of sending them. Only use it for local
+// development, as emails contain secrets such as reset links.
+func NewLogMailer(logger zerolog.Logger) Mailer {
+	return &logMailerImpl{
+		logger: logger,
+	}
+}
+
+type logMailerImpl struct {
+	logger zerolog.Logger
+}
+
+func (m *logMailerImpl) Send(_ context.Context, mail *models.Mail) error {
+	m.logger.Info().
+		Str("to", mail.To).
+		Str("subject", mail.Subject).
+		Str("body", mail.Body).
+		Msg("mail sent")
+
+	return nil
+}
@@ -0,0 +1,11 @@
+package mail
+
+import (
+	"context"
+	"technical-interview/pkg/models"
+)
+
+// Mailer sends emails to users.
+type Mailer interface {
+	Send(ctx context.Context, mail *models.Mail) error
+}
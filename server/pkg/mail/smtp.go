@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"technical-interview/pkg/models"
+)
+
+// NewSMTPMailer creates a mailer that sends emails through an SMTP server, with PLAIN authentication.
+func NewSMTPMailer(host string, port int, username string, password string, from string) Mailer {
+	return &smtpMailerImpl{
+		addr: net.JoinHostPort(host, fmt.Sprint(port)),
+		auth: smtp.PlainAuth("", username, password, host),
+		from: from,
+	}
+}
+
+type smtpMailerImpl struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func (m *smtpMailerImpl) Send(_ context.Context, mail *models.Mail) error {
+	// Headers must not contain line breaks, or they could be used to inject other headers.
+	if strings.ContainsAny(mail.To+mail.Subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+
+	message := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + mail.To,
+		"Subject: " + mail.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		mail.Body,
+	}, "\r\n")
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{mail.To}, []byte(message))
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// ActionTokenPurpose restricts what a single-use token can be exchanged for.
+type ActionTokenPurpose string
+
+const (
+	ActionTokenPasswordReset     ActionTokenPurpose = "password_reset"
+	ActionTokenEmailVerification ActionTokenPurpose = "email_verification"
+	ActionTokenMFAChallenge      ActionTokenPurpose = "mfa_challenge"
+	ActionTokenLoginLink         ActionTokenPurpose = "login_link"
+	// WebAuthn challenges are stored as action tokens, until the ceremony they were issued for completes.
+	ActionTokenWebAuthnRegistration ActionTokenPurpose = "webauthn_registration"
+	ActionTokenWebAuthnLogin        ActionTokenPurpose = "webauthn_login"
+)
+
+// ActionToken is a single-use, time-limited token sent to a user by email. Only its hash is stored.
+type ActionToken struct {
+	// ID is the hash of the token.
+	ID      string             `json:"-" firestore:"id"`
+	Purpose ActionTokenPurpose `json:"purpose" firestore:"purpose"`
+	UserID  string             `json:"userID" firestore:"user_id"`
+	// Email is the address the token was sent to, when the token proves ownership of it.
+	Email     string     `json:"email,omitempty" firestore:"email"`
+	CreatedAt time.Time  `json:"createdAt" firestore:"created_at"`
+	ExpiresAt time.Time  `json:"expiresAt" firestore:"expires_at"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" firestore:"used_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Lists an email domain can be added to.
+const (
+	EmailDomainAllow = "allow"
+	EmailDomainDeny  = "deny"
+)
+
+// EmailDomain is an email domain allowed or denied by an administrator. It applies to subdomains as well.
+type EmailDomain struct {
+	// Domain is in ASCII form, with internationalized labels in punycode.
+	Domain string `json:"domain" firestore:"domain"`
+	// List is either EmailDomainAllow or EmailDomainDeny.
+	List      string    `json:"list" firestore:"list"`
+	CreatedAt time.Time `json:"createdAt" firestore:"created_at"`
+}
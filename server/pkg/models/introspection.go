@@ -0,0 +1,26 @@
+package models
+
+// OAuthClient is a service allowed to call the OAuth endpoints of the API.
+type OAuthClient struct {
+	ID string
+	// SecretHash is the hex encoded SHA-256 hash of the client secret.
+	SecretHash string
+}
+
+// IntrospectionResponse is the response of the OAuth 2.0 token introspection endpoint (RFC 7662). Inactive tokens
+// only have the Active field set.
+type IntrospectionResponse struct {
+	Active bool `json:"active"`
+	// Scope is the space separated list of scopes of the token.
+	Scope string `json:"scope,omitempty"`
+	// ClientID is the client the token was issued to. Tokens issued to users through login have none.
+	ClientID  string   `json:"client_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       Audience `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+}
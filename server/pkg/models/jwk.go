@@ -0,0 +1,22 @@
+package models
+
+// JWK is a public JSON Web Key, as defined in RFC 7517. Only OKP keys (RFC 8037) are supported.
+type JWK struct {
+	// Kty is the key type. Ed25519 keys use OKP (Octet Key Pair).
+	Kty string `json:"kty"`
+	// Crv is the curve of the key.
+	Crv string `json:"crv"`
+	// X is the base64url encoded public key.
+	X string `json:"x"`
+	// Kid matches the kid header of the tokens signed with this key.
+	Kid string `json:"kid"`
+	// Alg is the algorithm used with this key.
+	Alg string `json:"alg"`
+	// Use is the intended use of the key.
+	Use string `json:"use"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
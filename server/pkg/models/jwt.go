@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+const (
+	// JWTAlgorithm is the JOSE algorithm name for ed25519 signatures (RFC 8037).
+	JWTAlgorithm = "EdDSA"
+	JWTType      = "JWT"
+)
+
+// JWTHeader is the JOSE header of an RFC 7519 token.
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// JWTClaims holds the registered claims of an RFC 7519 token. Dates are NumericDate values, in seconds since epoch.
+type JWTClaims struct {
+	// Sub (subject) is the ID of the user who owns the token.
+	Sub string `json:"sub"`
+	// Iat (issued at) is the date the token was created.
+	Iat int64 `json:"iat"`
+	// Exp (expiration) is the date after which the token must be rejected.
+	Exp int64 `json:"exp"`
+	// Nbf (not before) is the date before which the token must be rejected.
+	Nbf int64 `json:"nbf"`
+	// Jti (JWT ID) is a unique identifier for the token.
+	Jti string `json:"jti"`
+	// Iss (issuer) identifies the service that issued the token.
+	Iss string `json:"iss,omitempty"`
+	// Aud (audience) identifies the recipients of the token.
+	Aud Audience `json:"aud,omitempty"`
+	// Sid is the ID of the session the token was issued for.
+	Sid string `json:"sid,omitempty"`
+}
+
+// Audience is the aud claim, which RFC 7519 allows to be either a single string or an array of strings.
+type Audience []string
+
+func (aud Audience) MarshalJSON() ([]byte, error) {
+	if len(aud) == 1 {
+		return json.Marshal(aud[0])
+	}
+
+	return json.Marshal([]string(aud))
+}
+
+func (aud *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = Audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+
+	*aud = multiple
+	return nil
+}
+
+// Contains returns true if the given recipient is part of the audience.
+func (aud Audience) Contains(recipient string) bool {
+	for _, value := range aud {
+		if value == recipient {
+			return true
+		}
+	}
+
+	return false
+}
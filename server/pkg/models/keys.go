@@ -0,0 +1,43 @@
+package models
+
+import (
+	"crypto/ed25519"
+)
+
+// SigningKey is an ed25519 key pair used to sign and verify user tokens.
+type SigningKey struct {
+	// ID (kid) identifies the key in the token header, so the right key can be picked for verification.
+	ID string
+	// PublicKey is used to verify token signatures.
+	PublicKey ed25519.PublicKey
+	// PrivateKey is used to sign new tokens. It is nil for keys that are only published for verification.
+	PrivateKey ed25519.PrivateKey
+}
+
+// KeySet holds every key that is still published for verification, and designates the one used for signing.
+type KeySet struct {
+	// ActiveID is the ID of the key used to sign new tokens.
+	ActiveID string
+	Keys     []SigningKey
+}
+
+// Active returns the key used to sign new tokens, or nil if none is configured.
+func (set *KeySet) Active() *SigningKey {
+	key := set.Find(set.ActiveID)
+	if key == nil || key.PrivateKey == nil {
+		return nil
+	}
+
+	return key
+}
+
+// Find returns the published key with the given ID, or nil if it does not exist.
+func (set *KeySet) Find(id string) *SigningKey {
+	for i := range set.Keys {
+		if set.Keys[i].ID == id {
+			return &set.Keys[i]
+		}
+	}
+
+	return nil
+}
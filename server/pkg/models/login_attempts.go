@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempts counts the consecutive failed logins of an account or a client IP.
+type LoginAttempts struct {
+	Failures      int       `json:"failures" firestore:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt" firestore:"last_failure_at"`
+	// ExpiresAt is the date after which the counter is forgotten.
+	ExpiresAt time.Time `json:"expiresAt" firestore:"expires_at"`
+}
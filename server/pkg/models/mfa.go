@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+)
+
+// MFA holds the second factors of a user. It is stored apart from the user, so secrets can't leak with it.
+type MFA struct {
+	UserID string `firestore:"user_id"`
+	// TOTPSecret is the base32 secret shared with the authenticator app of the user, once enrolment is confirmed.
+	TOTPSecret string `firestore:"totp_secret"`
+	// TOTPPendingSecret is set during enrolment, until the user proves their app generates valid codes.
+	TOTPPendingSecret string     `firestore:"totp_pending_secret"`
+	TOTPEnabledAt     *time.Time `firestore:"totp_enabled_at"`
+	// TOTPLastCounter is the time step of the last code accepted, so a code can't be used twice.
+	TOTPLastCounter int64 `firestore:"totp_last_counter"`
+	// RecoveryCodeHashes are the hashes of the one-time codes that can replace a TOTP code, if the app is lost.
+	RecoveryCodeHashes []string `firestore:"recovery_code_hashes"`
+}
+
+// TOTPEnabled returns true once the user has confirmed their TOTP enrolment.
+func (mfa *MFA) TOTPEnabled() bool {
+	return mfa.TOTPSecret != ""
+}
+
+// TOTPEnrollment is sent to the user to set up their authenticator app.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth URI of the secret, to display as a QR code.
+	URI string `json:"uri"`
+}
+
+// MFAChallenge is returned by a login that needs a second factor. The token is exchanged for credentials along with
+// a valid code.
+type MFAChallenge struct {
+	Token     string    `json:"mfaToken"`
+	ExpiresAt time.Time `json:"mfaTokenExpiresAt"`
+	// Methods are the second factors the user can answer with.
+	Methods []string `json:"methods"`
+}
+
+// LoginResult is the outcome of a successful first authentication step.
+type LoginResult struct {
+	User *User
+	// Credentials are set once the user is fully authenticated.
+	Credentials *Credentials
+	// MFAChallenge is set instead of Credentials when the user must confirm their login with a second factor.
+	MFAChallenge *MFAChallenge
+}
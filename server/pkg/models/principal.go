@@ -0,0 +1,9 @@
+package models
+
+// Principal is the authenticated user of a request.
+type Principal struct {
+	// UserID is the ID of the authenticated user.
+	UserID string
+	// Token is the verified token the user authenticated with.
+	Token *UserToken
+}
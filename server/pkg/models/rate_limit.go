@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// RateLimitStatus is the outcome of counting a request against a rate limit.
+type RateLimitStatus struct {
+	Allowed bool
+	Limit   int
+	// Remaining is the number of requests still allowed in the current window.
+	Remaining int
+	// ResetAt is the end of the current window.
+	ResetAt time.Time
+	// RetryAt is the date after which a denied request would be allowed.
+	RetryAt time.Time
+}
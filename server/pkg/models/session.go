@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// Session groups every refresh token issued from a single authentication. Refresh tokens are rotated on each use,
+// so the session is the family of all of them.
+type Session struct {
+	ID     string `json:"id" firestore:"id"`
+	UserID string `json:"userID" firestore:"user_id"`
+	// RefreshTokenHash is the hash of the only refresh token of the session that can still be used.
+	RefreshTokenHash string `json:"-" firestore:"refresh_token_hash"`
+	// RetiredTokenHashes are the hashes of the refresh tokens that were already exchanged. Presenting one of them
+	// again means the token was stolen, and revokes the session. Only the most recent ones are kept.
+	RetiredTokenHashes []string   `json:"-" firestore:"retired_token_hashes"`
+	CreatedAt          time.Time  `json:"createdAt" firestore:"created_at"`
+	RefreshedAt        time.Time  `json:"refreshedAt" firestore:"refreshed_at"`
+	ExpiresAt          time.Time  `json:"expiresAt" firestore:"expires_at"`
+	RevokedAt          *time.Time `json:"revokedAt,omitempty" firestore:"revoked_at"`
+	// LastSeenAt is the last time a token of the session was used. It is only updated once in a while, to spare
+	// writes.
+	LastSeenAt time.Time `json:"lastSeenAt" firestore:"last_seen_at"`
+	// DeviceLabel is a readable description of the client that opened the session, like "Chrome on macOS".
+	DeviceLabel string `json:"deviceLabel" firestore:"device_label"`
+	UserAgent   string `json:"userAgent" firestore:"user_agent"`
+	// IP is the address of the client that opened the session.
+	IP string `json:"ip" firestore:"ip"`
+	// Current is set when listing sessions, on the session of the token the list was requested with.
+	Current bool `json:"current" firestore:"-"`
+}
+
+// ClientInfo describes the client a request comes from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Credentials are issued to a user after a successful authentication.
+type Credentials struct {
+	// AccessToken authenticates the requests of the user.
+	AccessToken *TokenIntrospection `json:"token"`
+	// RefreshToken is an opaque token, used to obtain new credentials once the access token expires.
+	RefreshToken string `json:"refreshToken"`
+	// RefreshTokenExpiresAt is the date after which the refresh token can no longer be used.
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+}
@@ -5,17 +5,40 @@ import (
 	"time"
 )
 
+// TokenStatusReason is a machine-readable explanation of a token introspection result.
+type TokenStatusReason string
+
+const (
+	TokenReasonValid            TokenStatusReason = "valid"
+	TokenReasonMissing          TokenStatusReason = "missing"
+	TokenReasonMalformed        TokenStatusReason = "malformed"
+	TokenReasonUnknownKey       TokenStatusReason = "unknown_key"
+	TokenReasonInvalidSignature TokenStatusReason = "invalid_signature"
+	TokenReasonInvalidClaims    TokenStatusReason = "invalid_claims"
+	TokenReasonNotIssued        TokenStatusReason = "not_issued"
+	TokenReasonExpired          TokenStatusReason = "expired"
+	TokenReasonRevoked          TokenStatusReason = "revoked"
+)
+
 // TokenIntrospection is the result of a token introspection.
 type TokenIntrospection struct {
 	// OK is true if the token is valid.
 	OK bool `json:"ok"`
+	// Reason explains why the token is valid or not.
+	Reason TokenStatusReason `json:"reason"`
 	// Expired is true if the token is past expiration date.
 	Expired bool `json:"expired"`
 	// NotIssued is true if the token has an issuedAt date in the future.
 	NotIssued bool `json:"notIssued"`
 	// Malformed is true if the token is not a valid JWT.
 	Malformed bool `json:"malformed"`
-	// Token contains the decoded token, if decoding was successful.
+	// InvalidSignature is true if the token was not signed by one of our keys. It usually means the token was forged
+	// or tampered with.
+	InvalidSignature bool `json:"invalidSignature"`
+	// Revoked is true if the token was invalidated before its expiration date, for example on logout.
+	Revoked bool `json:"revoked"`
+	// Token contains the decoded token, if decoding was successful. It is never set if the signature could not be
+	// verified.
 	Token *UserToken `json:"token,omitempty"`
 	// TokenRaw is the original token sent in the headers.
 	TokenRaw string `json:"tokenRaw,omitempty"`
@@ -28,15 +51,22 @@ type UserTokenHeader struct {
 	EXP time.Time `json:"exp"`
 	// ID is a unique identifier for this token, that guarantees a unique encoded string.
 	ID uuid.UUID `json:"id"`
+	// KID is the identifier of the key used to sign the token.
+	KID string `json:"kid"`
 }
 
 type UserTokenPayload struct {
 	// ID of the user who owns this token.
 	ID string `json:"id"`
+	// SessionID is the ID of the session the token was issued for.
+	SessionID string `json:"sid,omitempty"`
 }
 
 // UserToken represents the token issued to a user, for authentication.
 type UserToken struct {
 	Header  UserTokenHeader  `json:"header"`
 	Payload UserTokenPayload `json:"payload"`
+	// Issuer and Audience are the iss and aud claims of RFC 7519 tokens. Tokens in the original format have neither.
+	Issuer   string   `json:"issuer,omitempty"`
+	Audience Audience `json:"audience,omitempty"`
 }
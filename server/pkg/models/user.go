@@ -1,8 +1,112 @@
 package models
 
+import (
+	"time"
+)
+
 type User struct {
 	ID       string `json:"id" firestore:"id"`
 	Email    string `json:"email" firestore:"email"`
 	Username string `json:"username" firestore:"username"`
 	Password string `json:"-" firestore:"password"`
+	// EmailVerified is true once the user has proven they own Email.
+	EmailVerified   bool       `json:"emailVerified" firestore:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" firestore:"email_verified_at"`
+	// PendingEmail is the address the user asked to switch to. It replaces Email once verified.
+	PendingEmail string `json:"pendingEmail,omitempty" firestore:"pending_email"`
+	DisplayName  string `json:"displayName,omitempty" firestore:"display_name"`
+	// Locale is a BCP 47 language tag, like "en-US".
+	Locale string `json:"locale,omitempty" firestore:"locale"`
+	// Timezone is an IANA time zone name, like "Europe/Paris".
+	Timezone  string `json:"timezone,omitempty" firestore:"timezone"`
+	AvatarURL string `json:"avatarURL,omitempty" firestore:"avatar_url"`
+	// DeletionScheduledAt is set when the user asked for their account to be deleted. The account and everything it
+	// owns are deleted once it is reached, unless the user logs in before.
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty" firestore:"deletion_scheduled_at"`
+	// UpdatedAt is the last update time of the document, read from Firestore. It identifies the version of the
+	// user, for optimistic concurrency.
+	UpdatedAt time.Time `json:"-" firestore:"-"`
+}
+
+// PublicUser is the part of a user that anyone knowing their email can read. Fields about the account itself, like a
+// pending email or a scheduled deletion, are only shown to the user.
+type PublicUser struct {
+	ID          string `json:"id"`
+	Email       string `json:"email"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName,omitempty"`
+	AvatarURL   string `json:"avatarURL,omitempty"`
+}
+
+// Public returns the public profile of the user.
+func (u *User) Public() *PublicUser {
+	return &PublicUser{
+		ID:          u.ID,
+		Email:       u.Email,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
+	}
+}
+
+// UserEmail reserves an email address for a user. It is stored under the normalized address, so two users can never
+// hold the same one.
+type UserEmail struct {
+	Email     string    `json:"email" firestore:"email"`
+	UserID    string    `json:"userID" firestore:"user_id"`
+	CreatedAt time.Time `json:"createdAt" firestore:"created_at"`
+}
+
+// UserUsername reserves a username for a user. It is stored under the lower case username, so two users can never hold
+// the same one, whatever its case.
+type UserUsername struct {
+	Username  string    `json:"username" firestore:"username"`
+	UserID    string    `json:"userID" firestore:"user_id"`
+	CreatedAt time.Time `json:"createdAt" firestore:"created_at"`
+}
+
+// EmailMigrationResult is the outcome of the migration of the email of a single user.
+type EmailMigrationResult struct {
+	// Normalized is true if the email or pending email of the user was rewritten.
+	Normalized bool
+	// Reserved is true if the email was not reserved under its canonical key yet.
+	Reserved bool
+	// Conflict is true if the mailbox of the user is reserved by another user.
+	Conflict bool
+	// Invalid is true if the email of the user is not a valid address.
+	Invalid bool
+}
+
+// EmailMigrationReport sums up the normalization and reservation of the emails of existing users.
+type EmailMigrationReport struct {
+	Normalized int `json:"normalized"`
+	Reserved   int `json:"reserved"`
+	// Conflicts lists the IDs of the users whose mailbox is reserved by another user. They must be fixed by hand.
+	Conflicts []string `json:"conflicts"`
+	// Invalid lists the IDs of the users whose email is not a valid address. They must be fixed by hand.
+	Invalid []string `json:"invalid"`
+}
+
+// Add counts the result of the migration of a user.
+func (r *EmailMigrationReport) Add(userID string, result EmailMigrationResult) {
+	switch {
+	case result.Invalid:
+		r.Invalid = append(r.Invalid, userID)
+	case result.Conflict:
+		r.Conflicts = append(r.Conflicts, userID)
+	}
+	if result.Normalized {
+		r.Normalized++
+	}
+	if result.Reserved {
+		r.Reserved++
+	}
+}
+
+// UsernameMigrationReport sums up the reservation of the usernames of existing users.
+type UsernameMigrationReport struct {
+	Reserved int `json:"reserved"`
+	// Conflicts lists the IDs of the users whose username is reserved by another user, with a different case. They
+	// must be fixed by hand.
+	Conflicts []string `json:"conflicts"`
 }
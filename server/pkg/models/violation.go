@@ -0,0 +1,9 @@
+package models
+
+// Violation explains why a field of an entity was rejected.
+type Violation struct {
+	Field string `json:"field"`
+	// Code identifies the rule that was broken, so clients can show their own messages.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
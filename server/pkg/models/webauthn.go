@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user to sign in without a password.
+type WebAuthnCredential struct {
+	// ID is the base64url encoded ID chosen by the authenticator.
+	ID     string `json:"id" firestore:"id"`
+	UserID string `json:"-" firestore:"user_id"`
+	// Name is chosen by the user to tell their authenticators apart.
+	Name string `json:"name" firestore:"name"`
+	// PublicKey is the COSE encoded public key of the credential.
+	PublicKey []byte `json:"-" firestore:"public_key"`
+	Algorithm int64  `json:"-" firestore:"algorithm"`
+	// SignCount is the last counter reported by the authenticator. A counter that does not increase reveals a cloned
+	// authenticator.
+	SignCount uint32 `json:"-" firestore:"sign_count"`
+	// AAGUID identifies the model of the authenticator.
+	AAGUID     string   `json:"aaguid" firestore:"aaguid"`
+	Transports []string `json:"transports" firestore:"transports"`
+	// BackedUp is true for passkeys synced between devices.
+	BackedUp   bool       `json:"backedUp" firestore:"backed_up"`
+	CreatedAt  time.Time  `json:"createdAt" firestore:"created_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" firestore:"last_used_at"`
+}
@@ -0,0 +1,86 @@
+package otp_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"technical-interview/pkg/otp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCode(t *testing.T) {
+	// Test vectors of RFC 6238 appendix B, for SHA-1, truncated to 6 digits.
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	data := []struct {
+		name string
+
+		time int64
+
+		expect string
+	}{
+		{name: "59", time: 59, expect: "287082"},
+		{name: "1111111109", time: 1111111109, expect: "081804"},
+		{name: "1111111111", time: 1111111111, expect: "050471"},
+		{name: "1234567890", time: 1234567890, expect: "005924"},
+		{name: "2000000000", time: 2000000000, expect: "279037"},
+		{name: "20000000000", time: 20000000000, expect: "353130"},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			code, err := otp.Code(secret, otp.Counter(time.Unix(d.time, 0)))
+			require.NoError(t, err)
+			require.Equal(t, d.expect, code)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := otp.NewSecret()
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		name string
+
+		codeAt time.Time
+
+		expectOK bool
+	}{
+		{name: "Current", codeAt: now, expectOK: true},
+		{name: "PreviousStep", codeAt: now.Add(-otp.Period), expectOK: true},
+		{name: "NextStep", codeAt: now.Add(otp.Period), expectOK: true},
+		{name: "TooOld", codeAt: now.Add(-2 * otp.Period)},
+		{name: "TooEarly", codeAt: now.Add(2 * otp.Period)},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			code, err := otp.Code(secret, otp.Counter(d.codeAt))
+			require.NoError(t, err)
+
+			counter, ok, err := otp.Validate(secret, code, now, 1)
+			require.NoError(t, err)
+			require.Equal(t, d.expectOK, ok)
+
+			if ok {
+				require.Equal(t, otp.Counter(d.codeAt), counter)
+			}
+		})
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(otp.ProvisioningURI("InRich", "user@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, "/InRich:user@example.com", uri.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	require.Equal(t, "InRich", uri.Query().Get("issuer"))
+}
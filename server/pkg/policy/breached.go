@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rangePrefixLength is the number of hex characters of the SHA-1 hash used to select a range.
+const rangePrefixLength = 5
+
+// BreachedPasswords lists passwords exposed in data breaches, by SHA-1 hash. Lookups use the k-anonymity model of
+// Have I Been Pwned: only the first 5 characters of the hash are requested, and the matching is done by the caller.
+// The list can then be served by a remote service without ever sending it a password or a full hash.
+type BreachedPasswords interface {
+	// Range returns the hashes starting with prefix, without the prefix, mapped to the number of times they were
+	// seen in breaches. Suffixes are uppercase.
+	Range(ctx context.Context, prefix string) (map[string]int, error)
+}
+
+// NewFileBreachedPasswords loads a list from a file with one uppercase or lowercase SHA-1 hash per line, optionally
+// followed by a colon and a count, like the files produced by the Pwned Passwords downloader. Empty lines and lines
+// starting with # are ignored.
+func NewFileBreachedPasswords(path string) (BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ranges := make(map[string]map[string]int)
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, countText, hasCount := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, line)
+		}
+
+		count := 1
+		if hasCount {
+			if count, err = strconv.Atoi(countText); err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid count: %w", path, line, err)
+			}
+		}
+
+		prefix, suffix := hash[:rangePrefixLength], hash[rangePrefixLength:]
+		if ranges[prefix] == nil {
+			ranges[prefix] = make(map[string]int)
+		}
+		ranges[prefix][suffix] += count
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &fileBreachedPasswordsImpl{ranges: ranges}, nil
+}
+
+type fileBreachedPasswordsImpl struct {
+	ranges map[string]map[string]int
+}
+
+func (list *fileBreachedPasswordsImpl) Range(_ context.Context, prefix string) (map[string]int, error) {
+	return list.ranges[strings.ToUpper(prefix)], nil
+}
+
+// breachCount returns the number of times the password was seen in breaches.
+func breachCount(ctx context.Context, list BreachedPasswords, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := list.Range(ctx, hash[:rangePrefixLength])
+	if err != nil {
+		return 0, err
+	}
+
+	return suffixes[hash[rangePrefixLength:]], nil
+}
@@ -0,0 +1,281 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/models"
+
+	"golang.org/x/net/idna"
+)
+
+const emailField = "email"
+
+// Violation codes of the email domain policy.
+const (
+	CodeInvalidDomain    = "invalid_domain"
+	CodeDomainNotAllowed = "domain_not_allowed"
+	CodeDomainDenied     = "domain_denied"
+)
+
+// reservedTLDs can't receive mail from the internet (RFC 2606, RFC 6761, RFC 7686 and ICANN's .internal).
+var reservedTLDs = map[string]bool{
+	"arpa":      true,
+	"example":   true,
+	"internal":  true,
+	"invalid":   true,
+	"local":     true,
+	"localhost": true,
+	"onion":     true,
+	"test":      true,
+}
+
+//go:embed disposable.txt
+var disposableDomainsFile string
+
+// disposableDomains are the throwaway mail services denied by default.
+var disposableDomains = mustReadDomains("disposable.txt", strings.NewReader(disposableDomainsFile))
+
+type EmailDomainOptions struct {
+	// AllowlistOnly rejects every domain that is not allowed, for invite-only deployments.
+	AllowlistOnly bool
+	// Allowed domains are accepted, even if they are denied by a list.
+	Allowed []string
+	// DenylistFile is an optional file of domains to deny, in addition to the bundled list of disposable mail
+	// services. It is read again on Reload.
+	DenylistFile string
+}
+
+// EmailDomainEntries returns the domains allowed or denied by administrators, which take precedence over the lists
+// of the options.
+type EmailDomainEntries interface {
+	// Find returns the entries of the given domains. Domains without an entry are skipped.
+	Find(ctx context.Context, domains []string) ([]*models.EmailDomain, error)
+}
+
+type EmailDomainPolicy interface {
+	// Check returns the rules the domain of the address breaks, or nothing if it is acceptable. Entries apply to
+	// subdomains, and the most specific one wins.
+	Check(ctx context.Context, address emailaddr.Address) ([]models.Violation, error)
+	// Reload reads the denylist file again. The previous list is kept if the file can't be read.
+	Reload() error
+}
+
+// NewEmailDomainPolicy creates an email domain policy, reading the denylist file if any. Entries are ignored if nil.
+func NewEmailDomainPolicy(options EmailDomainOptions, entries EmailDomainEntries) (EmailDomainPolicy, error) {
+	allowed := make(map[string]bool, len(options.Allowed))
+	for _, domain := range options.Allowed {
+		normalized, err := NormalizeDomain(domain)
+		if err != nil {
+			return nil, fmt.Errorf("allowed domain %q: %w", domain, err)
+		}
+
+		allowed[normalized] = true
+	}
+
+	output := &emailDomainPolicyImpl{
+		options: options,
+		entries: entries,
+		allowed: allowed,
+	}
+	if err := output.Reload(); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+type emailDomainPolicyImpl struct {
+	options EmailDomainOptions
+	entries EmailDomainEntries
+	allowed map[string]bool
+
+	mu     sync.RWMutex
+	denied map[string]bool
+}
+
+// NormalizeDomain validates a domain, and returns its lower case ASCII form, with internationalized labels in
+// punycode.
+func NormalizeDomain(domain string) (string, error) {
+	return idna.Lookup.ToASCII(strings.TrimSpace(domain))
+}
+
+// readDomains reads a list of domains, one per line. Empty lines and lines starting with # are ignored.
+func readDomains(name string, reader io.Reader) (map[string]bool, error) {
+	output := make(map[string]bool)
+
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		domain, err := NormalizeDomain(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid domain: %w", name, line, err)
+		}
+
+		output[domain] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func mustReadDomains(name string, reader io.Reader) map[string]bool {
+	output, err := readDomains(name, reader)
+	if err != nil {
+		panic(err)
+	}
+
+	return output
+}
+
+func (p *emailDomainPolicyImpl) Reload() error {
+	denied := make(map[string]bool, len(disposableDomains))
+	for domain := range disposableDomains {
+		denied[domain] = true
+	}
+
+	if p.options.DenylistFile != "" {
+		file, err := os.Open(p.options.DenylistFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		fileDomains, err := readDomains(p.options.DenylistFile, file)
+		if err != nil {
+			return err
+		}
+
+		for domain := range fileDomains {
+			denied[domain] = true
+		}
+	}
+
+	p.mu.Lock()
+	p.denied = denied
+	p.mu.Unlock()
+
+	return nil
+}
+
+// checkMailDomain returns why the domain can't receive mail from the internet, or an empty string if it looks like it
+// can. Only the syntax is checked, without any DNS query.
+func checkMailDomain(domain string) string {
+	labels := strings.Split(domain, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 {
+			return "labels must be 1 to 63 characters long"
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "labels must not start or end with a hyphen"
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return "labels must only contain letters, digits and hyphens"
+			}
+		}
+	}
+
+	// Top level domains are never numeric, which also rules out IP addresses.
+	tld := labels[len(labels)-1]
+	if len(tld) < 2 || strings.Trim(tld, "0123456789") == "" {
+		return "top level domain is invalid"
+	}
+	if reservedTLDs[tld] {
+		return "top level domain is reserved"
+	}
+
+	return ""
+}
+
+// parentDomains returns the domain followed by its parents, up to the top level domain.
+func parentDomains(domain string) []string {
+	output := []string{domain}
+	for {
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			return output
+		}
+
+		output = append(output, parent)
+		domain = parent
+	}
+}
+
+func (p *emailDomainPolicyImpl) Check(ctx context.Context, address emailaddr.Address) ([]models.Violation, error) {
+	domain := address.Domain()
+
+	if reason := checkMailDomain(domain); reason != "" {
+		return []models.Violation{{
+			Field:   emailField,
+			Code:    CodeInvalidDomain,
+			Message: fmt.Sprintf("email domain can't receive mail: %s", reason),
+		}}, nil
+	}
+
+	domains := parentDomains(domain)
+
+	lists := make(map[string]string)
+	if p.entries != nil {
+		entries, err := p.entries.Find(ctx, domains)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			lists[entry.Domain] = entry.List
+		}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, candidate := range domains {
+		list, ok := lists[candidate]
+		if !ok {
+			switch {
+			case p.allowed[candidate]:
+				list = models.EmailDomainAllow
+			case p.denied[candidate]:
+				list = models.EmailDomainDeny
+			}
+		}
+
+		switch list {
+		case models.EmailDomainAllow:
+			return nil, nil
+		case models.EmailDomainDeny:
+			return []models.Violation{{
+				Field:   emailField,
+				Code:    CodeDomainDenied,
+				Message: "emails from this domain are not accepted, use another address",
+			}}, nil
+		}
+	}
+
+	if p.options.AllowlistOnly {
+		return []models.Violation{{
+			Field:   emailField,
+			Code:    CodeDomainNotAllowed,
+			Message: "registration is restricted to invited domains",
+		}}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,154 @@
+package policy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type emailDomainEntriesMock struct {
+	entries []*models.EmailDomain
+}
+
+func (mock *emailDomainEntriesMock) Find(_ context.Context, domains []string) ([]*models.EmailDomain, error) {
+	return lo.Filter(mock.entries, func(entry *models.EmailDomain, _ int) bool {
+		return slices.Contains(domains, entry.Domain)
+	}), nil
+}
+
+func writeDenylist(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func checkEmailDomain(t *testing.T, domainPolicy policy.EmailDomainPolicy, email string) []string {
+	address, err := emailaddr.NewParser(emailaddr.Options{}).Parse(email)
+	require.NoError(t, err)
+
+	violations, err := domainPolicy.Check(context.Background(), address)
+	require.NoError(t, err)
+
+	var output []string
+	for _, violation := range violations {
+		output = append(output, violation.Code)
+	}
+
+	return output
+}
+
+func TestEmailDomainPolicy(t *testing.T) {
+	entries := &emailDomainEntriesMock{entries: []*models.EmailDomain{
+		{Domain: "spam.example.com", List: models.EmailDomainDeny},
+		{Domain: "ok.spam.example.com", List: models.EmailDomainAllow},
+		{Domain: "yopmail.fr", List: models.EmailDomainAllow},
+	}}
+
+	domainPolicy, err := policy.NewEmailDomainPolicy(policy.EmailDomainOptions{
+		DenylistFile: writeDenylist(t, "# Local list\n\nBlocked.Example.org\n"),
+	}, entries)
+	require.NoError(t, err)
+
+	data := []struct {
+		name string
+
+		email string
+
+		expectCodes []string
+	}{
+		{
+			name:  "Success",
+			email: "user@example.com",
+		},
+		{
+			name:        "Disposable",
+			email:       "user@mailinator.com",
+			expectCodes: []string{policy.CodeDomainDenied},
+		},
+		{
+			name:        "DisposableSubdomain",
+			email:       "user@eu.mailinator.com",
+			expectCodes: []string{policy.CodeDomainDenied},
+		},
+		{
+			name:        "DenylistFile",
+			email:       "user@blocked.example.org",
+			expectCodes: []string{policy.CodeDomainDenied},
+		},
+		{
+			name:        "DeniedEntry",
+			email:       "user@mx.spam.example.com",
+			expectCodes: []string{policy.CodeDomainDenied},
+		},
+		{
+			name:  "AllowedEntryOfDeniedParent",
+			email: "user@ok.spam.example.com",
+		},
+		{
+			name:  "AllowedEntryOverridesList",
+			email: "user@yopmail.fr",
+		},
+		{
+			name:        "ReservedTLD",
+			email:       "user@server.local",
+			expectCodes: []string{policy.CodeInvalidDomain},
+		},
+		{
+			name:        "NumericTLD",
+			email:       "user@192.168.0.1",
+			expectCodes: []string{policy.CodeInvalidDomain},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			require.Equal(t, d.expectCodes, checkEmailDomain(t, domainPolicy, d.email))
+		})
+	}
+}
+
+func TestEmailDomainPolicyAllowlistOnly(t *testing.T) {
+	entries := &emailDomainEntriesMock{entries: []*models.EmailDomain{
+		{Domain: "partner.com", List: models.EmailDomainAllow},
+	}}
+
+	domainPolicy, err := policy.NewEmailDomainPolicy(policy.EmailDomainOptions{
+		AllowlistOnly: true,
+		Allowed:       []string{"Example.com"},
+	}, entries)
+	require.NoError(t, err)
+
+	require.Empty(t, checkEmailDomain(t, domainPolicy, "user@example.com"))
+	require.Empty(t, checkEmailDomain(t, domainPolicy, "user@team.example.com"))
+	require.Empty(t, checkEmailDomain(t, domainPolicy, "user@partner.com"))
+	require.Equal(t, []string{policy.CodeDomainNotAllowed}, checkEmailDomain(t, domainPolicy, "user@gmail.com"))
+}
+
+func TestEmailDomainPolicyReload(t *testing.T) {
+	path := writeDenylist(t, "first.com\n")
+
+	domainPolicy, err := policy.NewEmailDomainPolicy(policy.EmailDomainOptions{DenylistFile: path}, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{policy.CodeDomainDenied}, checkEmailDomain(t, domainPolicy, "user@first.com"))
+
+	require.NoError(t, os.WriteFile(path, []byte("second.com\n"), 0o600))
+	require.NoError(t, domainPolicy.Reload())
+
+	require.Empty(t, checkEmailDomain(t, domainPolicy, "user@first.com"))
+	require.Equal(t, []string{policy.CodeDomainDenied}, checkEmailDomain(t, domainPolicy, "user@second.com"))
+
+	// Invalid files keep the previous list.
+	require.NoError(t, os.WriteFile(path, []byte("not a domain!\n"), 0o600))
+	require.Error(t, domainPolicy.Reload())
+	require.Equal(t, []string{policy.CodeDomainDenied}, checkEmailDomain(t, domainPolicy, "user@second.com"))
+}
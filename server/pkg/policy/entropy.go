@@ -0,0 +1,301 @@
+package policy
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+//go:embed common.txt
+var commonWordsFile string
+
+// commonWords maps frequent password components to their rank, most frequent first.
+var commonWords = loadWords(commonWordsFile)
+
+const (
+	minDictionaryMatch = 3
+	minRepeatMatch     = 3
+	minSequenceMatch   = 3
+	minKeyboardMatch   = 4
+)
+
+// keyboardRows is the US QWERTY layout, without shift. Each row is shifted right by half a key compared to the one
+// above it.
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+var leetSubstitutions = strings.NewReplacer("4", "a", "@", "a", "8", "b", "3", "e", "6", "g", "1", "i", "!", "i", "0", "o", "5", "s", "$", "s", "7", "t", "2", "z")
+
+// match is a part of the password, runes [i, j), that follows a guessable pattern.
+type match struct {
+	i, j    int
+	guesses float64
+}
+
+func loadWords(file string) map[string]int {
+	output := make(map[string]int)
+
+	for _, line := range strings.Split(file, "\n") {
+		word := strings.ToLower(strings.TrimSpace(line))
+		if word == "" {
+			continue
+		}
+		if _, ok := output[word]; !ok {
+			output[word] = len(output) + 1
+		}
+	}
+
+	return output
+}
+
+// estimateEntropy returns an estimate, in bits, of the number of guesses an attacker needs to find the password. Like
+// zxcvbn, the password is split into the sequence of patterns that is the easiest to guess: dictionary words
+// (including the user inputs), repeated characters, sequences, keyboard walks and years. Characters that follow no
+// pattern must be brute-forced.
+func estimateEntropy(password string, userInputs []string) float64 {
+	runes := []rune(password)
+
+	dictionary := commonWords
+	if len(userInputs) > 0 {
+		dictionary = make(map[string]int, len(commonWords)+len(userInputs))
+		for word, rank := range commonWords {
+			dictionary[word] = rank
+		}
+		// User inputs are the first thing a targeted attack tries.
+		for _, input := range userInputs {
+			if input = strings.ToLower(input); len([]rune(input)) >= minDictionaryMatch {
+				dictionary[input] = 1
+			}
+		}
+	}
+
+	matches := dictionaryMatches(runes, dictionary)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	endingAt := make([][]match, len(runes)+1)
+	for _, m := range matches {
+		endingAt[m.j] = append(endingAt[m.j], m)
+	}
+
+	// best[j] is the lowest entropy of runes [0, j).
+	best := make([]float64, len(runes)+1)
+	for j := 1; j <= len(runes); j++ {
+		best[j] = best[j-1] + math.Log2(cardinality(runes[j-1]))
+
+		for _, m := range endingAt[j] {
+			best[j] = math.Min(best[j], best[m.i]+math.Log2(m.guesses))
+		}
+	}
+
+	return best[len(runes)]
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r < unicode.MaxASCII:
+		return 33
+	default:
+		return 100
+	}
+}
+
+func dictionaryMatches(runes []rune, dictionary map[string]int) []match {
+	var output []match
+
+	for i := range runes {
+		for j := i + minDictionaryMatch; j <= len(runes); j++ {
+			token := string(runes[i:j])
+			lower := strings.ToLower(token)
+
+			guesses := 0.0
+			if rank, ok := dictionary[lower]; ok {
+				guesses = float64(rank)
+			} else if rank, ok := dictionary[leetSubstitutions.Replace(lower)]; ok {
+				// Attackers try common substitutions as well, they only double the work.
+				guesses = float64(rank) * 2
+			}
+
+			if guesses > 0 {
+				output = append(output, match{i: i, j: j, guesses: guesses * uppercaseVariations(token)})
+			}
+		}
+	}
+
+	return output
+}
+
+// uppercaseVariations is the number of ways the word could have been capitalized, given how it is.
+func uppercaseVariations(word string) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	if upper == 0 {
+		return 1
+	}
+
+	first := []rune(word)[0]
+	if lower == 0 || (upper == 1 && unicode.IsUpper(first)) {
+		return 2
+	}
+
+	variations := 0.0
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+
+	return variations
+}
+
+func binomial(n, k int) float64 {
+	output := 1.0
+	for i := 1; i <= k; i++ {
+		output = output * float64(n-k+i) / float64(i)
+	}
+
+	return output
+}
+
+func repeatMatches(runes []rune) []match {
+	var output []match
+
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+
+		if j-i >= minRepeatMatch {
+			output = append(output, match{i: i, j: j, guesses: cardinality(runes[i]) * float64(j-i)})
+		}
+
+		i = j
+	}
+
+	return output
+}
+
+// sequenceMatches finds runs like abc, 9876 or ace, where each character is at the same distance from the previous.
+func sequenceMatches(runes []rune) []match {
+	var output []match
+
+	for i := 0; i < len(runes)-1; {
+		delta := runes[i+1] - runes[i]
+		j := i + 2
+		for j < len(runes) && runes[j]-runes[j-1] == delta {
+			j++
+		}
+
+		if j-i >= minSequenceMatch && delta != 0 && delta >= -5 && delta <= 5 {
+			start := runes[i]
+
+			guesses := 26.0
+			switch {
+			case strings.ContainsRune("aAzZ019", start):
+				guesses = 4
+			case unicode.IsDigit(start):
+				guesses = 10
+			}
+			if delta < 0 {
+				guesses *= 2
+			}
+			if delta != 1 && delta != -1 {
+				guesses *= 5
+			}
+
+			output = append(output, match{i: i, j: j, guesses: guesses * float64(j-i)})
+			i = j - 1
+			continue
+		}
+
+		i++
+	}
+
+	return output
+}
+
+func keyPosition(r rune) (int, int, bool) {
+	for row, keys := range keyboardRows {
+		if col := strings.IndexRune(keys, unicode.ToLower(r)); col >= 0 {
+			return row, col, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func adjacentKeys(a, b rune) bool {
+	rowA, colA, okA := keyPosition(a)
+	rowB, colB, okB := keyPosition(b)
+	if !okA || !okB {
+		return false
+	}
+
+	switch rowB - rowA {
+	case 0:
+		return colB-colA == 1 || colB-colA == -1
+	case -1:
+		return colB == colA || colB == colA+1
+	case 1:
+		return colB == colA || colB == colA-1
+	default:
+		return false
+	}
+}
+
+// keyboardMatches finds walks over adjacent keys, like qwerty or 1qaz.
+func keyboardMatches(runes []rune) []match {
+	var output []match
+
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && adjacentKeys(runes[j-1], runes[j]) {
+			j++
+		}
+
+		if j-i >= minKeyboardMatch {
+			// Any starting key, then one of about 3 likely directions for every following key.
+			output = append(output, match{i: i, j: j, guesses: 47 * math.Pow(3, float64(j-i-1))})
+		}
+
+		i = j
+	}
+
+	return output
+}
+
+// yearMatches finds recent years, often birth years or the current year.
+func yearMatches(runes []rune) []match {
+	var output []match
+
+	for i := 0; i+4 <= len(runes); i++ {
+		token := string(runes[i : i+4])
+		if (strings.HasPrefix(token, "19") || strings.HasPrefix(token, "20")) && isDigits(token) {
+			output = append(output, match{i: i, j: i + 4, guesses: 200})
+		}
+	}
+
+	return output
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
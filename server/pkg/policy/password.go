@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"technical-interview/pkg/models"
+	"unicode/utf8"
+)
+
+const passwordField = "password"
+
+// Violation codes of the password policy.
+const (
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeTooWeak          = "too_weak"
+	CodeContainsUserInfo = "contains_user_info"
+	CodeBreached         = "breached"
+)
+
+// minUserInputLength prevents short usernames from banning common substrings.
+const minUserInputLength = 3
+
+type PasswordOptions struct {
+	// MinLength and MaxLength are counted in characters. MaxLength bounds the cost of hashing. Zero disables them.
+	MinLength int
+	MaxLength int
+	// MinEntropy is the minimum estimated entropy, in bits. Zero disables the check.
+	MinEntropy float64
+}
+
+type PasswordPolicy interface {
+	// Check returns the rules the password breaks, or nothing if it is acceptable. The email and username of the user
+	// are used to reject passwords derived from them.
+	Check(ctx context.Context, password string, email string, username string) ([]models.Violation, error)
+}
+
+// NewPasswordPolicy creates a password policy. Passwords found in breached are rejected, unless it is nil.
+func NewPasswordPolicy(options PasswordOptions, breached BreachedPasswords) PasswordPolicy {
+	return &passwordPolicyImpl{
+		options:  options,
+		breached: breached,
+	}
+}
+
+type passwordPolicyImpl struct {
+	options  PasswordOptions
+	breached BreachedPasswords
+}
+
+func (p *passwordPolicyImpl) Check(ctx context.Context, password string, email string, username string) ([]models.Violation, error) {
+	var output []models.Violation
+
+	length := utf8.RuneCountInString(password)
+	if p.options.MinLength > 0 && length < p.options.MinLength {
+		output = append(output, models.Violation{
+			Field:   passwordField,
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.options.MinLength),
+		})
+	}
+	if p.options.MaxLength > 0 && length > p.options.MaxLength {
+		output = append(output, models.Violation{
+			Field:   passwordField,
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("password must be at most %d characters long", p.options.MaxLength),
+		})
+	}
+
+	userInputs := userInputs(email, username)
+	lower := strings.ToLower(password)
+	for _, input := range userInputs {
+		if strings.Contains(lower, input) {
+			output = append(output, models.Violation{
+				Field:   passwordField,
+				Code:    CodeContainsUserInfo,
+				Message: "password must not contain your email or username",
+			})
+			break
+		}
+	}
+
+	if p.options.MinEntropy > 0 && estimateEntropy(password, userInputs) < p.options.MinEntropy {
+		output = append(output, models.Violation{
+			Field:   passwordField,
+			Code:    CodeTooWeak,
+			Message: "password is too easy to guess, avoid common words, sequences and keyboard patterns",
+		})
+	}
+
+	if p.breached != nil {
+		count, err := breachCount(ctx, p.breached, password)
+		if err != nil {
+			return nil, err
+		}
+
+		if count > 0 {
+			output = append(output, models.Violation{
+				Field:   passwordField,
+				Code:    CodeBreached,
+				Message: "password appeared in a data breach, choose another one",
+			})
+		}
+	}
+
+	return output, nil
+}
+
+// userInputs returns the lowercase identifiers of the user, that an attacker would try first.
+func userInputs(email string, username string) []string {
+	var output []string
+
+	add := func(input string) {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if utf8.RuneCountInString(input) >= minUserInputLength {
+			output = append(output, input)
+		}
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	add(local)
+	add(username)
+
+	return output
+}
@@ -0,0 +1,129 @@
+package policy_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBreachedPasswords(t *testing.T, passwords ...string) string {
+	lines := []string{"# Test list"}
+	for _, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":3")
+	}
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+
+	return path
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	breached, err := policy.NewFileBreachedPasswords(writeBreachedPasswords(t, "kX9#mPq2vL!t"))
+	require.NoError(t, err)
+
+	passwordPolicy := policy.NewPasswordPolicy(policy.PasswordOptions{MinLength: 10, MaxLength: 64, MinEntropy: 40}, breached)
+
+	data := []struct {
+		name string
+
+		password string
+
+		expectCodes []string
+	}{
+		{
+			name:     "Success",
+			password: "vL7#qTz9!mWp",
+		},
+		{
+			name:     "Passphrase",
+			password: "correct horse battery staple",
+		},
+		{
+			name:        "TooShort",
+			password:    "1234",
+			expectCodes: []string{policy.CodeTooShort, policy.CodeTooWeak},
+		},
+		{
+			name:        "TooLong",
+			password:    strings.Repeat("vL7#qTz9!mWp", 6),
+			expectCodes: []string{policy.CodeTooLong},
+		},
+		{
+			name:        "CommonWords",
+			password:    "Password2024!",
+			expectCodes: []string{policy.CodeTooWeak},
+		},
+		{
+			name:        "KeyboardWalk",
+			password:    "qwertyuiop12",
+			expectCodes: []string{policy.CodeTooWeak},
+		},
+		{
+			name:        "Leet",
+			password:    "P@ssw0rd1234",
+			expectCodes: []string{policy.CodeTooWeak},
+		},
+		{
+			name:        "Username",
+			password:    "Johnsmith#1990",
+			expectCodes: []string{policy.CodeContainsUserInfo, policy.CodeTooWeak},
+		},
+		{
+			name:        "EmailLocalPart",
+			password:    "x9#John.Doe!q",
+			expectCodes: []string{policy.CodeContainsUserInfo, policy.CodeTooWeak},
+		},
+		{
+			name:        "Breached",
+			password:    "kX9#mPq2vL!t",
+			expectCodes: []string{policy.CodeBreached},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			violations, err := passwordPolicy.Check(context.Background(), d.password, "john.doe@example.com", "johnsmith")
+			require.NoError(t, err)
+
+			codes := lo.Map(violations, func(violation models.Violation, _ int) string {
+				require.Equal(t, "password", violation.Field)
+				require.NotEmpty(t, violation.Message)
+				return violation.Code
+			})
+			require.ElementsMatch(t, d.expectCodes, codes)
+		})
+	}
+}
+
+func TestFileBreachedPasswords(t *testing.T) {
+	path := writeBreachedPasswords(t, "password")
+
+	list, err := policy.NewFileBreachedPasswords(path)
+	require.NoError(t, err)
+
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	res, err := list.Range(context.Background(), "5baa6")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"1E4C9B93F3F0682250B6CF8331B7EE68FD8": 3}, res)
+
+	res, err = list.Range(context.Background(), "00000")
+	require.NoError(t, err)
+	require.Empty(t, res)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.txt")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a hash\n"), 0o600))
+
+	_, err = policy.NewFileBreachedPasswords(invalid)
+	require.Error(t, err)
+}
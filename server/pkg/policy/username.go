@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"technical-interview/pkg/models"
+	"unicode/utf8"
+)
+
+const usernameField = "username"
+
+// Violation codes of the username policy, in addition to CodeTooShort and CodeTooLong.
+const (
+	CodeInvalidCharacters = "invalid_characters"
+	CodeReserved          = "reserved"
+)
+
+type UsernameOptions struct {
+	// MinLength and MaxLength are counted in characters. Zero disables them.
+	MinLength int
+	MaxLength int
+	// Charset is the content of a regular expression character class, like "a-z0-9_.-", matched against the lower
+	// case username. Empty allows every character. The @ is always rejected, so usernames can't be mistaken for
+	// emails.
+	Charset string
+	// Reserved usernames can't be registered, whatever their case.
+	Reserved []string
+}
+
+type UsernamePolicy interface {
+	// Check returns the rules the username breaks, or nothing if it is acceptable.
+	Check(username string) []models.Violation
+}
+
+// NewUsernamePolicy creates a username policy. It returns an error if the charset is not a valid character class.
+func NewUsernamePolicy(options UsernameOptions) (UsernamePolicy, error) {
+	var charset *regexp.Regexp
+	if options.Charset != "" {
+		var err error
+		if charset, err = regexp.Compile("^[" + options.Charset + "]+$"); err != nil {
+			return nil, fmt.Errorf("invalid username charset: %w", err)
+		}
+	}
+
+	reserved := make(map[string]bool, len(options.Reserved))
+	for _, username := range options.Reserved {
+		reserved[usernameKey(username)] = true
+	}
+
+	return &usernamePolicyImpl{
+		options:  options,
+		charset:  charset,
+		reserved: reserved,
+	}, nil
+}
+
+type usernamePolicyImpl struct {
+	options  UsernameOptions
+	charset  *regexp.Regexp
+	reserved map[string]bool
+}
+
+// usernameKey returns the form under which usernames are compared, so they are unique whatever their case.
+func usernameKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func (p *usernamePolicyImpl) Check(username string) []models.Violation {
+	var output []models.Violation
+
+	key := usernameKey(username)
+
+	length := utf8.RuneCountInString(key)
+	if p.options.MinLength > 0 && length < p.options.MinLength {
+		output = append(output, models.Violation{
+			Field:   usernameField,
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("username must be at least %d characters long", p.options.MinLength),
+		})
+	}
+	if p.options.MaxLength > 0 && length > p.options.MaxLength {
+		output = append(output, models.Violation{
+			Field:   usernameField,
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("username must be at most %d characters long", p.options.MaxLength),
+		})
+	}
+
+	if strings.Contains(key, "@") || (p.charset != nil && key != "" && !p.charset.MatchString(key)) {
+		output = append(output, models.Violation{
+			Field:   usernameField,
+			Code:    CodeInvalidCharacters,
+			Message: "username contains characters that are not allowed",
+		})
+	}
+
+	if p.reserved[key] {
+		output = append(output, models.Violation{
+			Field:   usernameField,
+			Code:    CodeReserved,
+			Message: "username is reserved, choose another one",
+		})
+	}
+
+	return output
+}
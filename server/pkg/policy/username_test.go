@@ -0,0 +1,77 @@
+package policy_test
+
+import (
+	"strings"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsernamePolicy(t *testing.T) {
+	usernamePolicy, err := policy.NewUsernamePolicy(policy.UsernameOptions{
+		MinLength: 3,
+		MaxLength: 20,
+		Charset:   "a-z0-9_.-",
+		Reserved:  []string{"Admin", "root"},
+	})
+	require.NoError(t, err)
+
+	data := []struct {
+		name string
+
+		username string
+
+		expectCodes []string
+	}{
+		{
+			name:     "Success",
+			username: "john_doe.42",
+		},
+		{
+			name:     "MixedCase",
+			username: "John-Doe",
+		},
+		{
+			name:        "TooShort",
+			username:    "jd",
+			expectCodes: []string{policy.CodeTooShort},
+		},
+		{
+			name:        "TooLong",
+			username:    strings.Repeat("a", 21),
+			expectCodes: []string{policy.CodeTooLong},
+		},
+		{
+			name:        "InvalidCharacters",
+			username:    "john doe",
+			expectCodes: []string{policy.CodeInvalidCharacters},
+		},
+		{
+			name:        "Email",
+			username:    "john@example.com",
+			expectCodes: []string{policy.CodeInvalidCharacters},
+		},
+		{
+			name:        "Reserved",
+			username:    "ADMIN",
+			expectCodes: []string{policy.CodeReserved},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			codes := lo.Map(usernamePolicy.Check(d.username), func(violation models.Violation, _ int) string {
+				return violation.Code
+			})
+			require.ElementsMatch(t, d.expectCodes, codes)
+		})
+	}
+}
+
+func TestUsernamePolicyInvalidCharset(t *testing.T) {
+	_, err := policy.NewUsernamePolicy(policy.UsernameOptions{Charset: "z-a"})
+	require.Error(t, err)
+}
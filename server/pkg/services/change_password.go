@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/hasher"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"time"
+)
+
+type ChangePasswordService interface {
+	// Exec replaces the password of the principal, after checking the current one. If revokeOtherSessions is true,
+	// every session of the user but the current one is revoked. Wrong passwords are throttled like failed logins.
+	Exec(ctx context.Context, principal *models.Principal, currentPassword string, newPassword string, revokeOtherSessions bool, client models.ClientInfo) error
+}
+
+func NewChangePasswordService(
+	repository dao.UserRepository,
+	passwordHasher hasher.PasswordHasher,
+	passwordPolicy policy.PasswordPolicy,
+	sessions dao.SessionRepository,
+	protection LoginProtectionService,
+) ChangePasswordService {
+	return &changePasswordServiceImpl{
+		repository:     repository,
+		hasher:         passwordHasher,
+		passwordPolicy: passwordPolicy,
+		sessions:       sessions,
+		protection:     protection,
+	}
+}
+
+type changePasswordServiceImpl struct {
+	repository     dao.UserRepository
+	hasher         hasher.PasswordHasher
+	passwordPolicy policy.PasswordPolicy
+	sessions       dao.SessionRepository
+	protection     LoginProtectionService
+}
+
+func (s *changePasswordServiceImpl) Exec(ctx context.Context, principal *models.Principal, currentPassword string, newPassword string, revokeOtherSessions bool, client models.ClientInfo) error {
+	if newPassword == "" {
+		return errors.Join(ErrInvalidEntity, ErrMissingPassword)
+	}
+
+	user, err := s.repository.GetUser(ctx, principal.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmPassword(ctx, s.protection, s.hasher, user, currentPassword, client); err != nil {
+		return err
+	}
+
+	if err := s.protection.RecordSuccess(ctx, user.Email); err != nil {
+		return err
+	}
+
+	// Checked after the current password, so the policy can't be probed without it.
+	if err := checkPassword(ctx, s.passwordPolicy, newPassword, user.Email, user.Username); err != nil {
+		return err
+	}
+
+	if err := s.repository.UpdatePassword(ctx, user.ID, newPassword); err != nil {
+		return err
+	}
+
+	if revokeOtherSessions {
+		// Revoking a session revokes the access tokens issued for it as well.
+		if err := s.sessions.RevokeUserSessions(ctx, user.ID, principal.Token.Payload.SessionID, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"technical-interview/pkg/services"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	passwordPolicy := policy.NewPasswordPolicy(policy.PasswordOptions{MinLength: 8}, nil)
+	principal := &models.Principal{UserID: "user-1", Token: &models.UserToken{Payload: models.UserTokenPayload{ID: "user-1", SessionID: "session-1"}}}
+
+	data := []struct {
+		name string
+
+		currentPassword     string
+		newPassword         string
+		revokeOtherSessions bool
+
+		expectErr        error
+		expectViolations []string
+		expectPassword   string
+		// expectRevoked lists the sessions revoked by the change.
+		expectRevoked []string
+	}{
+		{
+			name:            "Success",
+			currentPassword: "password",
+			newPassword:     "new password",
+			expectPassword:  "new password",
+		},
+		{
+			// The session of the request is kept, so the user stays logged in.
+			name:                "RevokeOtherSessions",
+			currentPassword:     "password",
+			newPassword:         "new password",
+			revokeOtherSessions: true,
+			expectPassword:      "new password",
+			expectRevoked:       []string{"session-2"},
+		},
+		{
+			name:                "PolicyViolation",
+			currentPassword:     "password",
+			newPassword:         "short",
+			revokeOtherSessions: true,
+			expectErr:           services.ErrInvalidEntity,
+			expectViolations:    []string{"password:" + policy.CodeTooShort},
+			expectPassword:      "password",
+		},
+		{
+			name:                "WrongPassword",
+			currentPassword:     "wrong",
+			newPassword:         "new password",
+			revokeOtherSessions: true,
+			expectErr:           services.ErrInvalidPassword,
+			expectPassword:      "password",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: passwordHashed})
+			sessions := newSessionRepositoryMock(
+				&models.Session{ID: "session-1", UserID: "user-1"},
+				&models.Session{ID: "session-2", UserID: "user-1"},
+			)
+			protection := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{})
+
+			service := services.NewChangePasswordService(users, passwordHasher, passwordPolicy, sessions, protection)
+
+			err := service.Exec(ctx, principal, d.currentPassword, d.newPassword, d.revokeOtherSessions, models.ClientInfo{IP: "10.0.0.1"})
+			require.ErrorIs(t, err, d.expectErr)
+			if d.expectViolations != nil {
+				require.Equal(t, d.expectViolations, violationCodes(t, err))
+			}
+
+			ok, _, err := passwordHasher.Verify(d.expectPassword, users.users["user-1"].Password)
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			var revoked []string
+			for _, id := range []string{"session-1", "session-2"} {
+				if sessions.sessions[id].RevokedAt != nil {
+					revoked = append(revoked, id)
+				}
+			}
+			require.Equal(t, d.expectRevoked, revoked)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/hasher"
+	"technical-interview/pkg/mail"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+var (
+	ErrUserDeleted = errors.New("user is being deleted")
+)
+
+// cancelDeletion cancels the scheduled deletion of the user, as logging in during the grace period means they want to
+// keep their account. It fails with ErrUserDeleted once the deletion is due, so the account can't be used while it is
+// being purged.
+func cancelDeletion(ctx context.Context, users dao.UserRepository, userID string, now time.Time) error {
+	user, err := users.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.DeletionScheduledAt == nil {
+		return nil
+	}
+
+	// Checked again within the update, in case the deletion was cancelled or became due in the meantime.
+	_, err = users.Update(ctx, userID, time.Time{}, func(user *models.User) error {
+		if user.DeletionScheduledAt == nil {
+			return nil
+		}
+		if !now.Before(*user.DeletionScheduledAt) {
+			return ErrUserDeleted
+		}
+
+		user.DeletionScheduledAt = nil
+		return nil
+	})
+
+	return err
+}
+
+type RequestUserDeletionService interface {
+	// Exec schedules the deletion of the principal, and logs them out of every device. The password, and a second
+	// factor if the user has one, are required, so a stolen session is not enough. The user can cancel the deletion
+	// by logging in during the grace period. Wrong passwords are throttled like failed logins.
+	Exec(ctx context.Context, principal *models.Principal, password string, code string, client models.ClientInfo) (*models.User, error)
+}
+
+// NewRequestUserDeletionService creates the service. Accounts are deleted gracePeriod after the request.
+func NewRequestUserDeletionService(
+	users dao.UserRepository,
+	passwordHasher hasher.PasswordHasher,
+	mfa dao.MFARepository,
+	logoutAll LogoutAllService,
+	protection LoginProtectionService,
+	mailer mail.Mailer,
+	tasks TaskRunner,
+	gracePeriod time.Duration,
+	skew int,
+) RequestUserDeletionService {
+	return &requestUserDeletionServiceImpl{
+		users:       users,
+		hasher:      passwordHasher,
+		mfa:         mfa,
+		logoutAll:   logoutAll,
+		protection:  protection,
+		mailer:      mailer,
+		tasks:       tasks,
+		gracePeriod: gracePeriod,
+		skew:        skew,
+	}
+}
+
+type requestUserDeletionServiceImpl struct {
+	users       dao.UserRepository
+	hasher      hasher.PasswordHasher
+	mfa         dao.MFARepository
+	logoutAll   LogoutAllService
+	protection  LoginProtectionService
+	mailer      mail.Mailer
+	tasks       TaskRunner
+	gracePeriod time.Duration
+	skew        int
+}
+
+func (s *requestUserDeletionServiceImpl) Exec(ctx context.Context, principal *models.Principal, password string, code string, client models.ClientInfo) (*models.User, error) {
+	now := time.Now()
+
+	user, err := s.users.GetUser(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := confirmPassword(ctx, s.protection, s.hasher, user, password, client); err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.mfa.Get(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa.TOTPEnabled() {
+		if err := verifySecondFactor(ctx, s.mfa, mfa, code, s.skew, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.protection.RecordSuccess(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	deletionScheduledAt := now.Add(s.gracePeriod)
+
+	user, err = s.users.Update(ctx, user.ID, time.Time{}, func(user *models.User) error {
+		user.DeletionScheduledAt = &deletionScheduledAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Logging in cancels the deletion, so every session must end.
+	if err := s.logoutAll.Exec(ctx, principal); err != nil {
+		return nil, err
+	}
+
+	// Let the owner know how to keep their account, in case they did not make the request. The deletion is scheduled
+	// and the user logged out already, so a failed delivery must not fail the request.
+	s.tasks.Go("send_deletion_notice", func(ctx context.Context) error {
+		return s.sendDeletionNotice(ctx, user, deletionScheduledAt)
+	})
+
+	return user, nil
+}
+
+// sendDeletionNotice emails the user the date their account will be deleted, and how to keep it.
+func (s *requestUserDeletionServiceImpl) sendDeletionNotice(ctx context.Context, user *models.User, deletionScheduledAt time.Time) error {
+	return s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nYour account and all its data will be permanently deleted on %s. To keep your account, "+
+				"log in before that date.\n\nIf you did not make this request, log in and change your password "+
+				"immediately.\n",
+			user.Username, deletionScheduledAt.UTC().Format(time.RFC1123),
+		),
+	})
+}
+
+type PurgeDeletedUsersService interface {
+	// Exec deletes the users whose deletion is due at now, with their sessions, tokens, second factors and login
+	// counters. Their email and username can be registered again afterward. It returns the number of deleted users.
+	// A failure on a user doesn't stop the others, the user is retried on the next run.
+	Exec(ctx context.Context, now time.Time) (int, error)
+}
+
+func NewPurgeDeletedUsersService(
+	users dao.UserRepository,
+	sessions dao.SessionRepository,
+	revocations dao.RevocationRepository,
+	tokens dao.ActionTokenRepository,
+	mfa dao.MFARepository,
+	webAuthnCredentials dao.WebAuthnCredentialRepository,
+	loginProtection LoginProtectionService,
+) PurgeDeletedUsersService {
+	return &purgeDeletedUsersServiceImpl{
+		users:               users,
+		sessions:            sessions,
+		revocations:         revocations,
+		tokens:              tokens,
+		mfa:                 mfa,
+		webAuthnCredentials: webAuthnCredentials,
+		loginProtection:     loginProtection,
+	}
+}
+
+type purgeDeletedUsersServiceImpl struct {
+	users               dao.UserRepository
+	sessions            dao.SessionRepository
+	revocations         dao.RevocationRepository
+	tokens              dao.ActionTokenRepository
+	mfa                 dao.MFARepository
+	webAuthnCredentials dao.WebAuthnCredentialRepository
+	loginProtection     LoginProtectionService
+}
+
+func (s *purgeDeletedUsersServiceImpl) Exec(ctx context.Context, now time.Time) (int, error) {
+	users, err := s.users.ListDueDeletions(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	var errs []error
+
+	for _, user := range users {
+		if err := s.purge(ctx, user, now); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete user %s: %w", user.ID, err))
+			continue
+		}
+
+		deleted++
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// purge deletes the records of the user, then the user itself. Logging in is refused once the deletion is due, so no
+// record can be created in the meantime. The user is deleted last, so it is listed again if a step fails.
+func (s *purgeDeletedUsersServiceImpl) purge(ctx context.Context, user *models.User, now time.Time) error {
+	steps := []func(ctx context.Context, userID string) error{
+		s.sessions.DeleteByUser,
+		s.tokens.DeleteByUser,
+		s.mfa.Disable,
+		s.webAuthnCredentials.DeleteByUser,
+		// The grace period outlasts access tokens, so they have all expired.
+		s.revocations.DeleteByUser,
+	}
+
+	for _, step := range steps {
+		if err := step(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.loginProtection.RecordSuccess(ctx, user.Email); err != nil {
+		return err
+	}
+
+	return s.users.Delete(ctx, user.ID, now)
+}
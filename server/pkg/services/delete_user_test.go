@@ -0,0 +1,193 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/otp"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserDeletion(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Username: "john", Password: passwordHashed})
+	sessions := newSessionRepositoryMock()
+	revocations := newRevocationRepositoryMock()
+	tokens := newActionTokenRepositoryMock()
+	mfa := newMFARepositoryMock()
+	credentials := newWebAuthnCredentialRepositoryMock()
+	mailer := new(mailerMock)
+	principal := &models.Principal{UserID: "user-1"}
+
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	issueSession := services.NewIssueSessionService(
+		sessions,
+		users,
+		services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}),
+		time.Hour,
+	)
+	protection := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{
+		AccountFreeAttempts: 10,
+		IPFreeAttempts:      10,
+		BaseDelay:           time.Minute,
+		MaxDelay:            time.Hour,
+		FailureWindow:       time.Hour,
+	})
+	login := services.NewLoginService(
+		users, testEmailParser, passwordHasher, protection, services.NewMFAChallengeService(mfa, tokens, time.Minute), issueSession, false,
+	)
+	request := services.NewRequestUserDeletionService(
+		users,
+		passwordHasher,
+		mfa,
+		services.NewLogoutAllService(revocations, sessions, time.Hour),
+		protection,
+		mailer,
+		new(taskRunnerMock),
+		24*time.Hour,
+		1,
+	)
+	purge := services.NewPurgeDeletedUsersService(users, sessions, revocations, tokens, mfa, credentials, protection)
+
+	// The password is required, so a stolen session is not enough.
+	_, err = request.Exec(ctx, principal, "wrong", "", models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidPassword)
+
+	session, err := issueSession.IssueSession(ctx, "user-1", models.ClientInfo{}, time.Now())
+	require.NoError(t, err)
+
+	requestedAt := time.Now()
+	user, err := request.Exec(ctx, principal, "password", "", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.NotNil(t, user.DeletionScheduledAt)
+	require.WithinDuration(t, requestedAt.Add(24*time.Hour), *user.DeletionScheduledAt, time.Minute)
+	require.NotNil(t, sessions.sessions[session.AccessToken.Token.Payload.SessionID].RevokedAt)
+	require.Len(t, mailer.sent, 1)
+	require.Equal(t, "user@example.com", mailer.sent[0].To)
+
+	// Nothing is deleted during the grace period.
+	deleted, err := purge.Exec(ctx, requestedAt)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	// Logging in cancels the deletion.
+	_, err = login.Exec(ctx, "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Nil(t, users.users["user-1"].DeletionScheduledAt)
+
+	deleted, err = purge.Exec(ctx, requestedAt.Add(48*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	// Once the grace period is over, the user can no longer log in, and is deleted with everything they own.
+	_, err = request.Exec(ctx, principal, "password", "", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	_, err = tokens.Create(ctx, "token-1", models.ActionTokenPasswordReset, "user-1", "user@example.com", requestedAt, requestedAt.Add(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, credentials.Create(ctx, &models.WebAuthnCredential{ID: "credential-1", UserID: "user-1"}))
+	require.NoError(t, mfa.SetPendingTOTP(ctx, "user-1", "secret"))
+
+	// Logins are timed with the clock, so the grace period is ended by hand.
+	users.users["user-1"].DeletionScheduledAt = &requestedAt
+
+	_, err = login.Exec(ctx, "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrUserDeleted)
+
+	deleted, err = purge.Exec(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, deleted)
+
+	require.Empty(t, users.users)
+	require.Empty(t, sessions.sessions)
+	require.Empty(t, tokens.tokens)
+	require.Empty(t, credentials.credentials)
+	require.Empty(t, mfa.mfa)
+	require.Empty(t, revocations.users)
+
+	// The email and username can be registered again.
+	_, err = users.Create(ctx, "user@example.com", "password", "john")
+	require.NoError(t, err)
+}
+
+func TestUserDeletionMFA(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: passwordHashed})
+	mfa := newMFARepositoryMock()
+	principal := &models.Principal{UserID: "user-1"}
+
+	enrollment, err := services.NewEnrollTOTPService(users, mfa, "Test").Exec(ctx, principal)
+	require.NoError(t, err)
+
+	now := time.Now()
+	_, err = services.NewConfirmTOTPService(mfa, 1).Exec(ctx, principal, totpCode(t, enrollment.Secret, otp.Counter(now)))
+	require.NoError(t, err)
+
+	request := services.NewRequestUserDeletionService(
+		users,
+		passwordHasher,
+		mfa,
+		services.NewLogoutAllService(newRevocationRepositoryMock(), newSessionRepositoryMock(), time.Hour),
+		services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{}),
+		new(mailerMock),
+		new(taskRunnerMock),
+		24*time.Hour,
+		1,
+	)
+
+	// Users with a second factor must provide it too.
+	_, err = request.Exec(ctx, principal, "password", "", models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidMFACode)
+	require.Nil(t, users.users["user-1"].DeletionScheduledAt)
+
+	user, err := request.Exec(ctx, principal, "password", totpCode(t, enrollment.Secret, otp.Counter(now)+1), models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.NotNil(t, user.DeletionScheduledAt)
+}
+
+func TestUserDeletionMailFailure(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: passwordHashed})
+	sessions := newSessionRepositoryMock(&models.Session{ID: "session-1", UserID: "user-1"})
+	tasks := new(taskRunnerMock)
+
+	request := services.NewRequestUserDeletionService(
+		users,
+		passwordHasher,
+		newMFARepositoryMock(),
+		services.NewLogoutAllService(newRevocationRepositoryMock(), sessions, time.Hour),
+		services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{}),
+		&mailerMock{err: errors.New("smtp unavailable")},
+		tasks,
+		24*time.Hour,
+		1,
+	)
+
+	// The deletion is scheduled and the user logged out, so the request succeeds even if the notice can't be sent.
+	user, err := request.Exec(ctx, &models.Principal{UserID: "user-1"}, "password", "", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.NotNil(t, user.DeletionScheduledAt)
+	require.NotNil(t, sessions.sessions["session-1"].RevokedAt)
+	require.Len(t, tasks.failures, 1)
+}
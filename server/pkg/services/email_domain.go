@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"time"
+)
+
+type AddEmailDomainService interface {
+	// Exec allows or denies an email domain and its subdomains, replacing any previous entry of the domain. It is
+	// meant for administrators, and only affects new registrations and email changes.
+	Exec(ctx context.Context, domain string, list string) (*models.EmailDomain, error)
+}
+
+func NewAddEmailDomainService(repository dao.EmailDomainRepository) AddEmailDomainService {
+	return &addEmailDomainServiceImpl{
+		repository: repository,
+	}
+}
+
+type addEmailDomainServiceImpl struct {
+	repository dao.EmailDomainRepository
+}
+
+func (s *addEmailDomainServiceImpl) Exec(ctx context.Context, domain string, list string) (*models.EmailDomain, error) {
+	var violations []models.Violation
+
+	normalized, err := policy.NormalizeDomain(domain)
+	if err != nil {
+		violations = append(violations, models.Violation{Field: "domain", Code: codeInvalid, Message: err.Error()})
+	}
+	if list != models.EmailDomainAllow && list != models.EmailDomainDeny {
+		violations = append(violations, models.Violation{
+			Field:   "list",
+			Code:    codeInvalid,
+			Message: "list must be " + models.EmailDomainAllow + " or " + models.EmailDomainDeny,
+		})
+	}
+	if len(violations) > 0 {
+		return nil, &ValidationError{Violations: violations}
+	}
+
+	entry := &models.EmailDomain{Domain: normalized, List: list, CreatedAt: time.Now()}
+	if err := s.repository.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
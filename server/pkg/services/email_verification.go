@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/mail"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid email verification token")
+)
+
+type SendVerificationEmailService interface {
+	// Exec sends a verification link to the given address of the user, which is either their current or their
+	// pending email.
+	Exec(ctx context.Context, user *models.User, email string) error
+}
+
+// NewSendVerificationEmailService creates a service that emails verification links. Links point to verifyURL, with
+// the token in the token query parameter, and expire after tokenTTL.
+func NewSendVerificationEmailService(tokens dao.ActionTokenRepository, mailer mail.Mailer, verifyURL string, tokenTTL time.Duration) SendVerificationEmailService {
+	return &sendVerificationEmailServiceImpl{
+		tokens:    tokens,
+		mailer:    mailer,
+		verifyURL: verifyURL,
+		tokenTTL:  tokenTTL,
+	}
+}
+
+type sendVerificationEmailServiceImpl struct {
+	tokens    dao.ActionTokenRepository
+	mailer    mail.Mailer
+	verifyURL string
+	tokenTTL  time.Duration
+}
+
+func (s *sendVerificationEmailServiceImpl) Exec(ctx context.Context, user *models.User, email string) error {
+	now := time.Now()
+
+	token, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.tokens.Create(ctx, hashSecret(token), models.ActionTokenEmailVerification, user.ID, email, now, now.Add(s.tokenTTL)); err != nil {
+		return err
+	}
+
+	link, err := buildLink(s.verifyURL, token)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &models.Mail{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nUse the link below to confirm this address belongs to you. It expires in %s.\n\n%s\n\n"+
+				"If you did not create an account or change your email, you can ignore this email.\n",
+			user.Username, s.tokenTTL, link,
+		),
+	})
+}
+
+type VerifyEmailService interface {
+	// Exec marks the address the token was sent to as verified.
+	Exec(ctx context.Context, token string) error
+}
+
+func NewVerifyEmailService(users dao.UserRepository, tokens dao.ActionTokenRepository) VerifyEmailService {
+	return &verifyEmailServiceImpl{
+		users:  users,
+		tokens: tokens,
+	}
+}
+
+type verifyEmailServiceImpl struct {
+	users  dao.UserRepository
+	tokens dao.ActionTokenRepository
+}
+
+func (s *verifyEmailServiceImpl) Exec(ctx context.Context, token string) error {
+	now := time.Now()
+
+	actionToken, err := s.tokens.Consume(ctx, hashSecret(token), models.ActionTokenEmailVerification, now)
+	if err != nil {
+		if errors.Is(err, dao.ErrActionTokenNotFound) ||
+			errors.Is(err, dao.ErrActionTokenExpired) ||
+			errors.Is(err, dao.ErrActionTokenUsed) {
+			return errors.Join(ErrInvalidVerificationToken, err)
+		}
+
+		return err
+	}
+
+	if err := s.users.VerifyEmail(ctx, actionToken.UserID, actionToken.Email, now); err != nil {
+		// The user changed their email again since the link was sent.
+		if errors.Is(err, dao.ErrEmailMismatch) {
+			return errors.Join(ErrInvalidVerificationToken, err)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+
+	data := []struct {
+		name string
+
+		user *models.User
+		// tokenEmail is the address the link was sent to.
+		tokenEmail string
+		expiresIn  time.Duration
+		used       bool
+
+		expectErr          error
+		expectEmail        string
+		expectPendingEmail string
+		expectVerified     bool
+	}{
+		{
+			name:           "CurrentEmail",
+			user:           &models.User{ID: "user-1", Email: "user@example.com"},
+			tokenEmail:     "user@example.com",
+			expiresIn:      time.Hour,
+			expectEmail:    "user@example.com",
+			expectVerified: true,
+		},
+		{
+			name:           "PendingEmail",
+			user:           &models.User{ID: "user-1", Email: "user@example.com", EmailVerified: true, PendingEmail: "new@example.com"},
+			tokenEmail:     "new@example.com",
+			expiresIn:      time.Hour,
+			expectEmail:    "new@example.com",
+			expectVerified: true,
+		},
+		{
+			// The user changed their pending email again after the link was sent.
+			name:               "PendingEmailChanged",
+			user:               &models.User{ID: "user-1", Email: "user@example.com", PendingEmail: "other@example.com"},
+			tokenEmail:         "new@example.com",
+			expiresIn:          time.Hour,
+			expectErr:          dao.ErrEmailMismatch,
+			expectEmail:        "user@example.com",
+			expectPendingEmail: "other@example.com",
+		},
+		{
+			name:        "UsedToken",
+			user:        &models.User{ID: "user-1", Email: "user@example.com"},
+			tokenEmail:  "user@example.com",
+			expiresIn:   time.Hour,
+			used:        true,
+			expectErr:   dao.ErrActionTokenUsed,
+			expectEmail: "user@example.com",
+		},
+		{
+			name:        "ExpiredToken",
+			user:        &models.User{ID: "user-1", Email: "user@example.com"},
+			tokenEmail:  "user@example.com",
+			expiresIn:   -time.Minute,
+			expectErr:   dao.ErrActionTokenExpired,
+			expectEmail: "user@example.com",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(nil, d.user)
+			tokens := newActionTokenRepositoryMock()
+
+			now := time.Now()
+			token, err := tokens.Create(ctx, hashToken("token"), models.ActionTokenEmailVerification, "user-1", d.tokenEmail, now, now.Add(d.expiresIn))
+			require.NoError(t, err)
+			if d.used {
+				token.UsedAt = &now
+			}
+
+			err = services.NewVerifyEmailService(users, tokens).Exec(ctx, "token")
+			if d.expectErr != nil {
+				require.ErrorIs(t, err, services.ErrInvalidVerificationToken)
+				require.ErrorIs(t, err, d.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			user := users.users["user-1"]
+			require.Equal(t, d.expectEmail, user.Email)
+			require.Equal(t, d.expectPendingEmail, user.PendingEmail)
+			require.Equal(t, d.expectVerified, user.EmailVerified)
+		})
+	}
+}
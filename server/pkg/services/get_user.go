@@ -3,28 +3,51 @@ package services
 import (
 	"context"
 	"technical-interview/pkg/dao"
+	"technical-interview/pkg/emailaddr"
 	"technical-interview/pkg/models"
 )
 
 type GetUserService interface {
-	Exec(ctx context.Context, email string) (*models.User, error)
+	// Exec returns the public profile of the owner of the email.
+	Exec(ctx context.Context, email string) (*models.PublicUser, error)
 }
 
-func NewGetUserService(repository dao.UserRepository) GetUserService {
+func NewGetUserService(repository dao.UserRepository, emailParser emailaddr.Parser) GetUserService {
 	return &getUserServiceImpl{
-		repository: repository,
+		repository:  repository,
+		emailParser: emailParser,
 	}
 }
 
 type getUserServiceImpl struct {
-	repository dao.UserRepository
+	repository  dao.UserRepository
+	emailParser emailaddr.Parser
 }
 
-func (s *getUserServiceImpl) Exec(ctx context.Context, email string) (*models.User, error) {
-	user, err := s.repository.GetUserByEmail(ctx, email)
+func (s *getUserServiceImpl) Exec(ctx context.Context, email string) (*models.PublicUser, error) {
+	user, err := s.repository.GetUserByEmail(ctx, normalizeEmail(s.emailParser, email))
 	if err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	return user.Public(), nil
+}
+
+type GetCurrentUserService interface {
+	// Exec returns the whole user of the principal, including the fields only they can see.
+	Exec(ctx context.Context, principal *models.Principal) (*models.User, error)
+}
+
+func NewGetCurrentUserService(repository dao.UserRepository) GetCurrentUserService {
+	return &getCurrentUserServiceImpl{
+		repository: repository,
+	}
+}
+
+type getCurrentUserServiceImpl struct {
+	repository dao.UserRepository
+}
+
+func (s *getCurrentUserServiceImpl) Exec(ctx context.Context, principal *models.Principal) (*models.User, error) {
+	return s.repository.GetUser(ctx, principal.UserID)
 }
@@ -0,0 +1,34 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUser(t *testing.T) {
+	deletionScheduledAt := time.Now().Add(time.Hour)
+	users := newUserRepositoryMock(nil, &models.User{
+		ID:                  "user-1",
+		Email:               "user@example.com",
+		Username:            "john",
+		DisplayName:         "John",
+		PendingEmail:        "new@example.com",
+		DeletionScheduledAt: &deletionScheduledAt,
+	})
+
+	// Anyone knowing the email only gets the public profile.
+	public, err := services.NewGetUserService(users, testEmailParser).Exec(context.Background(), "User@Example.com")
+	require.NoError(t, err)
+	require.Equal(t, &models.PublicUser{ID: "user-1", Email: "user@example.com", Username: "john", DisplayName: "John"}, public)
+
+	// The user sees their whole account.
+	user, err := services.NewGetCurrentUserService(users).Exec(context.Background(), &models.Principal{UserID: "user-1"})
+	require.NoError(t, err)
+	require.Equal(t, "new@example.com", user.PendingEmail)
+	require.NotNil(t, user.DeletionScheduledAt)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+var (
+	ErrInvalidClient = errors.New("invalid client")
+)
+
+type IntrospectTokenService interface {
+	// Exec authenticates the client, and returns the RFC 7662 introspection result of the token.
+	Exec(ctx context.Context, clientID string, clientSecret string, token string) (*models.IntrospectionResponse, error)
+}
+
+func NewIntrospectTokenService(getTokenStatus GetTokenStatusService, clients []models.OAuthClient) IntrospectTokenService {
+	return &introspectTokenServiceImpl{
+		getTokenStatus: getTokenStatus,
+		clients:        clients,
+	}
+}
+
+type introspectTokenServiceImpl struct {
+	getTokenStatus GetTokenStatusService
+	clients        []models.OAuthClient
+}
+
+// AuthenticateClient returns ErrInvalidClient unless the secret matches the client with the given ID.
+func AuthenticateClient(clients []models.OAuthClient, clientID string, clientSecret string) error {
+	if clientID == "" || clientSecret == "" {
+		return ErrInvalidClient
+	}
+
+	secretHash := sha256.Sum256([]byte(clientSecret))
+	encodedSecretHash := hex.EncodeToString(secretHash[:])
+
+	for _, client := range clients {
+		if client.ID != clientID {
+			continue
+		}
+
+		if subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(encodedSecretHash)) == 1 {
+			return nil
+		}
+	}
+
+	return ErrInvalidClient
+}
+
+func (s *introspectTokenServiceImpl) Exec(ctx context.Context, clientID string, clientSecret string, token string) (*models.IntrospectionResponse, error) {
+	if err := AuthenticateClient(s.clients, clientID, clientSecret); err != nil {
+		return nil, err
+	}
+
+	status, err := s.getTokenStatus.GetTokenStatus(ctx, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 7662 forbids giving any detail about inactive tokens.
+	if !status.OK {
+		return &models.IntrospectionResponse{Active: false}, nil
+	}
+
+	// Tokens in the original format were issued without iss and aud, so both are only reported for JWTs.
+	return &models.IntrospectionResponse{
+		Active:    true,
+		TokenType: "Bearer",
+		Exp:       status.Token.Header.EXP.Unix(),
+		Iat:       status.Token.Header.IAT.Unix(),
+		Nbf:       status.Token.Header.IAT.Unix(),
+		Sub:       status.Token.Payload.ID,
+		Aud:       status.Token.Audience,
+		Iss:       status.Token.Issuer,
+		Jti:       status.Token.Header.ID.String(),
+	}, nil
+}
@@ -0,0 +1,110 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntrospectToken(t *testing.T) {
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	jwtOptions := services.JWTOptions{Issuer: "issuer", Audience: "gateway"}
+
+	secretHash := sha256.Sum256([]byte("secret"))
+	clients := []models.OAuthClient{{ID: "gateway", SecretHash: hex.EncodeToString(secretHash[:])}}
+
+	token, err := services.NewGenerateTokenService(time.Hour, keys, jwtOptions).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), time.Now())
+	require.NoError(t, err)
+
+	jwtOptions.Enabled = true
+	jwt, err := services.NewGenerateTokenService(time.Hour, keys, jwtOptions).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), time.Now())
+	require.NoError(t, err)
+
+	service := services.NewIntrospectTokenService(
+		services.NewGetTokenStatusService(keys, jwtOptions, newRevocationRepositoryMock(), newSessionRepositoryMock(), time.Minute),
+		clients,
+	)
+
+	data := []struct {
+		name string
+
+		clientID     string
+		clientSecret string
+		token        string
+
+		expect    *models.IntrospectionResponse
+		expectErr error
+	}{
+		{
+			name:         "Active",
+			clientID:     "gateway",
+			clientSecret: "secret",
+			token:        token.TokenRaw,
+			expect: &models.IntrospectionResponse{
+				Active:    true,
+				TokenType: "Bearer",
+				Exp:       token.Token.Header.EXP.Unix(),
+				Iat:       token.Token.Header.IAT.Unix(),
+				Nbf:       token.Token.Header.IAT.Unix(),
+				Sub:       "user-1",
+				Jti:       token.Token.Header.ID.String(),
+			},
+		},
+		{
+			name:         "ActiveJWT",
+			clientID:     "gateway",
+			clientSecret: "secret",
+			token:        jwt.TokenRaw,
+			expect: &models.IntrospectionResponse{
+				Active:    true,
+				TokenType: "Bearer",
+				Exp:       jwt.Token.Header.EXP.Unix(),
+				Iat:       jwt.Token.Header.IAT.Unix(),
+				Nbf:       jwt.Token.Header.IAT.Unix(),
+				Sub:       "user-1",
+				Aud:       models.Audience{"gateway"},
+				Iss:       "issuer",
+				Jti:       jwt.Token.Header.ID.String(),
+			},
+		},
+		{
+			name:         "Inactive",
+			clientID:     "gateway",
+			clientSecret: "secret",
+			token:        "garbage",
+			expect:       &models.IntrospectionResponse{Active: false},
+		},
+		{
+			name:         "InvalidSecret",
+			clientID:     "gateway",
+			clientSecret: "wrong",
+			token:        token.TokenRaw,
+			expectErr:    services.ErrInvalidClient,
+		},
+		{
+			name:         "UnknownClient",
+			clientID:     "other",
+			clientSecret: "secret",
+			token:        token.TokenRaw,
+			expectErr:    services.ErrInvalidClient,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			res, err := service.Exec(context.Background(), d.clientID, d.clientSecret, d.token)
+			require.ErrorIs(t, err, d.expectErr)
+			require.Equal(t, d.expect, res)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"technical-interview/pkg/models"
+)
+
+type GetJWKSService interface {
+	// Exec returns the public part of every key that can be used to verify tokens.
+	Exec(ctx context.Context) (*models.JWKS, error)
+}
+
+func NewGetJWKSService(keys *models.KeySet) GetJWKSService {
+	return &getJWKSServiceImpl{
+		keys: keys,
+	}
+}
+
+type getJWKSServiceImpl struct {
+	keys *models.KeySet
+}
+
+func (s *getJWKSServiceImpl) Exec(_ context.Context) (*models.JWKS, error) {
+	output := &models.JWKS{Keys: make([]models.JWK, 0, len(s.keys.Keys))}
+
+	for _, key := range s.keys.Keys {
+		output.Keys = append(output.Keys, models.JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.PublicKey),
+			Kid: key.ID,
+			Alg: "EdDSA",
+			Use: "sig",
+		})
+	}
+
+	return output, nil
+}
@@ -3,53 +3,180 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync"
 	"technical-interview/pkg/dao"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/hasher"
 	"technical-interview/pkg/models"
 	"time"
-
-	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrInvalidPassword = errors.New("invalid password")
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrEmailNotVerified = errors.New("email not verified")
 )
 
 type LoginService interface {
-	Exec(ctx context.Context, email string, password string) (*models.User, *models.TokenIntrospection, error)
+	// Exec authenticates the user, identified by their email or their username. Unknown users and wrong passwords
+	// both return ErrInvalidCredentials, in the same time, so logins can't be used to discover accounts. The IP of
+	// the client is used for throttling. Users with two-factor authentication get a challenge instead of credentials.
+	Exec(ctx context.Context, identifier string, password string, client models.ClientInfo) (*models.LoginResult, error)
 }
 
-func NewLoginService(repository dao.UserRepository, generateToken GenerateTokenService) LoginService {
+// NewLoginService creates the login service. If requireVerifiedEmail is true, users can't log in until they verify
+// their email. Passwords hashed with outdated options are hashed again on login.
+func NewLoginService(
+	repository dao.UserRepository,
+	emailParser emailaddr.Parser,
+	passwordHasher hasher.PasswordHasher,
+	protection LoginProtectionService,
+	mfaChallenge MFAChallengeService,
+	issueSession IssueSessionService,
+	requireVerifiedEmail bool,
+) LoginService {
 	return &loginServiceImpl{
-		repository:    repository,
-		generateToken: generateToken,
+		repository:           repository,
+		emailParser:          emailParser,
+		hasher:               passwordHasher,
+		protection:           protection,
+		mfaChallenge:         mfaChallenge,
+		issueSession:         issueSession,
+		requireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
 type loginServiceImpl struct {
-	repository    dao.UserRepository
-	generateToken GenerateTokenService
+	repository           dao.UserRepository
+	emailParser          emailaddr.Parser
+	hasher               hasher.PasswordHasher
+	protection           LoginProtectionService
+	mfaChallenge         MFAChallengeService
+	issueSession         IssueSessionService
+	requireVerifiedEmail bool
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+	dummyHashErr  error
 }
 
-func (s *loginServiceImpl) Exec(ctx context.Context, email string, password string) (*models.User, *models.TokenIntrospection, error) {
-	user, err := s.repository.GetUserByEmail(ctx, email)
+// getDummyHash returns a hash to verify when the user does not exist, so the response takes as long as with a wrong
+// password.
+func (s *loginServiceImpl) getDummyHash() (string, error) {
+	s.dummyHashOnce.Do(func() {
+		secret, err := newSecret()
+		if err != nil {
+			s.dummyHashErr = err
+			return
+		}
+
+		s.dummyHash, s.dummyHashErr = s.hasher.Hash(secret)
+	})
+
+	return s.dummyHash, s.dummyHashErr
+}
+
+// findUser returns the user designated by the identifier, or nil if there is none. Usernames can't contain an @, so
+// identifiers with one are emails.
+func (s *loginServiceImpl) findUser(ctx context.Context, identifier string) (*models.User, error) {
+	var user *models.User
+	var err error
+
+	if strings.Contains(identifier, "@") {
+		user, err = s.repository.GetUserByEmail(ctx, normalizeEmail(s.emailParser, identifier))
+	} else {
+		user, err = s.repository.GetUserByUsername(ctx, identifier)
+	}
 	if err != nil {
-		return nil, nil, err
+		if errors.Is(err, dao.ErrUserNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	return user, nil
+}
+
+// checkPassword returns ErrInvalidCredentials if the user is nil or the password is wrong.
+func (s *loginServiceImpl) checkPassword(ctx context.Context, user *models.User, password string) error {
+	if user == nil {
+		dummyHash, err := s.getDummyHash()
+		if err != nil {
+			return err
+		}
+
+		_, _, _ = s.hasher.Verify(password, dummyHash)
+		return ErrInvalidCredentials
+	}
+
+	ok, needsRehash, err := s.hasher.Verify(password, user.Password)
 	if err != nil {
-		if err == bcrypt.ErrMismatchedHashAndPassword {
-			return nil, nil, ErrInvalidPassword
+		return err
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	// The password is only known at this point, so this is the only chance to upgrade its hash.
+	if needsRehash {
+		if err := s.repository.UpdatePassword(ctx, user.ID, password); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+func (s *loginServiceImpl) Exec(ctx context.Context, identifier string, password string, client models.ClientInfo) (*models.LoginResult, error) {
+	user, err := s.findUser(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failures are counted by email, whichever identifier is used, so both don't give attackers twice the attempts.
+	account := normalizeEmail(s.emailParser, identifier)
+	if user != nil {
+		account = user.Email
+	}
+
+	if err := s.protection.Check(ctx, account, client.IP, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPassword(ctx, user, password); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			if err := s.protection.RecordFailure(ctx, account, client.IP, time.Now()); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, err
+	}
+
+	// Only checked once the password is known to be right, so it doesn't reveal anything to attackers.
+	if s.requireVerifiedEmail && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	challenge, err := s.mfaChallenge.Challenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// The failure counter is kept until the second factor is verified as well.
+	if challenge != nil {
+		return &models.LoginResult{MFAChallenge: challenge}, nil
+	}
 
-		return nil, nil, err
+	if err := s.protection.RecordSuccess(ctx, account); err != nil {
+		return nil, err
 	}
 
-	token, err := s.generateToken.GenerateToken(models.UserTokenPayload{ID: user.ID}, uuid.New(), time.Now())
+	credentials, err := s.issueSession.IssueSession(ctx, user.ID, client, time.Now())
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return user, token, nil
+	return &models.LoginResult{User: user, Credentials: credentials}, nil
 }
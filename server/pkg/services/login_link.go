@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/mail"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+var (
+	ErrInvalidLoginLink = errors.New("invalid or expired login link, or opened in another browser")
+)
+
+// loginLinkHash returns the hash a login link is stored under. It covers the nonce kept by the browser that asked for
+// the link, so the link alone can't be exchanged.
+func loginLinkHash(token string, nonce string) string {
+	return hashSecret(token + "." + nonce)
+}
+
+type RequestLoginLinkService interface {
+	// Exec emails a login link to the email, if it belongs to a user, and returns the nonce the browser must present
+	// along with the link. The result is the same whether the user exists or not, so the service can't be used to
+	// discover accounts.
+	Exec(ctx context.Context, email string) (string, error)
+}
+
+// NewRequestLoginLinkService creates a service that emails login links. Links point to loginURL, with the token in
+// the token query parameter, and expire after tokenTTL. Links are created and sent with tasks, so known and unknown
+// emails take the same time to answer.
+func NewRequestLoginLinkService(
+	users dao.UserRepository,
+	emailParser emailaddr.Parser,
+	tokens dao.ActionTokenRepository,
+	mailer mail.Mailer,
+	tasks TaskRunner,
+	loginURL string,
+	tokenTTL time.Duration,
+) RequestLoginLinkService {
+	return &requestLoginLinkServiceImpl{
+		users:       users,
+		emailParser: emailParser,
+		tokens:      tokens,
+		mailer:      mailer,
+		tasks:       tasks,
+		loginURL:    loginURL,
+		tokenTTL:    tokenTTL,
+	}
+}
+
+type requestLoginLinkServiceImpl struct {
+	users       dao.UserRepository
+	emailParser emailaddr.Parser
+	tokens      dao.ActionTokenRepository
+	mailer      mail.Mailer
+	tasks       TaskRunner
+	loginURL    string
+	tokenTTL    time.Duration
+}
+
+func (s *requestLoginLinkServiceImpl) Exec(ctx context.Context, email string) (string, error) {
+	nonce, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.users.GetUserByEmail(ctx, normalizeEmail(s.emailParser, email))
+	if err != nil {
+		if errors.Is(err, dao.ErrUserNotFound) {
+			return nonce, nil
+		}
+
+		return "", err
+	}
+
+	s.tasks.Go("send_login_link", func(ctx context.Context) error {
+		return s.sendLoginLink(ctx, user, nonce, time.Now())
+	})
+
+	return nonce, nil
+}
+
+// sendLoginLink creates a login token for the user, bound to the nonce, and emails them the link.
+func (s *requestLoginLinkServiceImpl) sendLoginLink(ctx context.Context, user *models.User, nonce string, now time.Time) error {
+	token, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.tokens.Create(ctx, loginLinkHash(token, nonce), models.ActionTokenLoginLink, user.ID, user.Email, now, now.Add(s.tokenTTL))
+	if err != nil {
+		return err
+	}
+
+	link, err := buildLink(s.loginURL, token)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nUse the link below to log in. It expires in %s, can only be used once, and only works in the "+
+				"browser you asked for it from.\n\n%s\n\nIf you did not ask to log in, you can ignore this email.\n",
+			user.Username, s.tokenTTL, link,
+		),
+	})
+}
+
+type VerifyLoginLinkService interface {
+	// Exec exchanges a login link for credentials, like a login with a password. The nonce is the one returned when
+	// the link was requested. Users with two-factor authentication get a challenge instead of credentials.
+	Exec(ctx context.Context, token string, nonce string, client models.ClientInfo) (*models.LoginResult, error)
+}
+
+func NewVerifyLoginLinkService(
+	users dao.UserRepository,
+	tokens dao.ActionTokenRepository,
+	mfaChallenge MFAChallengeService,
+	issueSession IssueSessionService,
+) VerifyLoginLinkService {
+	return &verifyLoginLinkServiceImpl{
+		users:        users,
+		tokens:       tokens,
+		mfaChallenge: mfaChallenge,
+		issueSession: issueSession,
+	}
+}
+
+type verifyLoginLinkServiceImpl struct {
+	users        dao.UserRepository
+	tokens       dao.ActionTokenRepository
+	mfaChallenge MFAChallengeService
+	issueSession IssueSessionService
+}
+
+func (s *verifyLoginLinkServiceImpl) Exec(ctx context.Context, token string, nonce string, client models.ClientInfo) (*models.LoginResult, error) {
+	now := time.Now()
+
+	actionToken, err := s.tokens.Consume(ctx, loginLinkHash(token, nonce), models.ActionTokenLoginLink, now)
+	if err != nil {
+		if errors.Is(err, dao.ErrActionTokenNotFound) ||
+			errors.Is(err, dao.ErrActionTokenExpired) ||
+			errors.Is(err, dao.ErrActionTokenUsed) {
+			return nil, errors.Join(ErrInvalidLoginLink, err)
+		}
+
+		return nil, err
+	}
+
+	user, err := s.users.GetUser(ctx, actionToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user changed their email since the link was sent.
+	if user.Email != actionToken.Email {
+		return nil, ErrInvalidLoginLink
+	}
+
+	// Opening the link proves the user owns their address.
+	if !user.EmailVerified {
+		if err := s.users.VerifyEmail(ctx, user.ID, user.Email, now); err != nil {
+			return nil, err
+		}
+
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &now
+	}
+
+	// The link replaces the password, not the second factor.
+	challenge, err := s.mfaChallenge.Challenge(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge != nil {
+		return &models.LoginResult{MFAChallenge: challenge}, nil
+	}
+
+	credentials, err := s.issueSession.IssueSession(ctx, user.ID, client, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResult{User: user, Credentials: credentials}, nil
+}
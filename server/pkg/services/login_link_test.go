@@ -0,0 +1,112 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/otp"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// sentToken returns the token of the link in the mail.
+func sentToken(t *testing.T, mail *models.Mail) string {
+	link, err := url.Parse(linkPattern.FindString(mail.Body))
+	require.NoError(t, err)
+
+	return link.Query().Get("token")
+}
+
+func TestLoginLink(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	users := newUserRepositoryMock(
+		passwordHasher,
+		&models.User{ID: "user-1", Email: "user@example.com"},
+		&models.User{ID: "user-2", Email: "mfa@example.com", EmailVerified: true},
+	)
+	tokens := newActionTokenRepositoryMock()
+	mfa := newMFARepositoryMock()
+	mailer := new(mailerMock)
+
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	issueSession := services.NewIssueSessionService(
+		newSessionRepositoryMock(),
+		users,
+		services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}),
+		time.Hour,
+	)
+
+	tasks := new(taskRunnerMock)
+	request := services.NewRequestLoginLinkService(users, testEmailParser, tokens, mailer, tasks, "https://example.com/login/link", time.Minute)
+	verify := services.NewVerifyLoginLinkService(users, tokens, services.NewMFAChallengeService(mfa, tokens, time.Minute), issueSession)
+
+	// Unknown emails get a nonce too, so they can't be told apart.
+	nonce, err := request.Exec(ctx, "unknown@example.com")
+	require.NoError(t, err)
+	require.NotEmpty(t, nonce)
+	require.Empty(t, mailer.sent)
+
+	nonce, err = request.Exec(ctx, "user@example.com")
+	require.NoError(t, err)
+	require.Len(t, mailer.sent, 1)
+	require.Equal(t, "user@example.com", mailer.sent[0].To)
+
+	token := sentToken(t, mailer.sent[0])
+	require.NotEmpty(t, token)
+
+	// The link alone, or with the nonce of another browser, is not enough.
+	otherNonce, err := request.Exec(ctx, "unknown@example.com")
+	require.NoError(t, err)
+
+	_, err = verify.Exec(ctx, token, otherNonce, models.ClientInfo{})
+	require.ErrorIs(t, err, services.ErrInvalidLoginLink)
+	_, err = verify.Exec(ctx, token, "", models.ClientInfo{})
+	require.ErrorIs(t, err, services.ErrInvalidLoginLink)
+
+	res, err := verify.Exec(ctx, token, nonce, models.ClientInfo{})
+	require.NoError(t, err)
+	require.Equal(t, "user-1", res.User.ID)
+	require.NotEmpty(t, res.Credentials.AccessToken)
+	require.Nil(t, res.MFAChallenge)
+
+	// Opening the link verified the email.
+	user, err := users.GetUser(ctx, "user-1")
+	require.NoError(t, err)
+	require.True(t, user.EmailVerified)
+
+	// Links are single use.
+	_, err = verify.Exec(ctx, token, nonce, models.ClientInfo{})
+	require.ErrorIs(t, err, services.ErrInvalidLoginLink)
+
+	// The link replaces the password, not the second factor.
+	secret, err := otp.NewSecret()
+	require.NoError(t, err)
+	require.NoError(t, mfa.SetPendingTOTP(ctx, "user-2", secret))
+	require.NoError(t, mfa.EnableTOTP(ctx, "user-2", 0, nil, time.Now()))
+
+	nonce, err = request.Exec(ctx, "mfa@example.com")
+	require.NoError(t, err)
+
+	res, err = verify.Exec(ctx, sentToken(t, mailer.sent[1]), nonce, models.ClientInfo{})
+	require.NoError(t, err)
+	require.Nil(t, res.Credentials)
+	require.NotNil(t, res.MFAChallenge)
+
+	// A failed delivery is logged, and answered like an unknown email.
+	mailer.err = errors.New("smtp unavailable")
+
+	nonce, err = request.Exec(ctx, "user@example.com")
+	require.NoError(t, err)
+	require.NotEmpty(t, nonce)
+	require.Len(t, tasks.failures, 1)
+}
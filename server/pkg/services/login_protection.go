@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/hasher"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+	ErrAccountLocked   = errors.New("account temporarily locked")
+)
+
+// ThrottleError rejects a login before the password is checked. It matches ErrTooManyAttempts or ErrAccountLocked.
+type ThrottleError struct {
+	Err error
+	// RetryAfter is the delay before the next attempt is allowed.
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return e.Err
+}
+
+type LoginProtectionOptions struct {
+	// AccountFreeAttempts and IPFreeAttempts are the failures allowed before logins are delayed. IPs get more, as
+	// many users can share one behind a NAT.
+	AccountFreeAttempts int
+	IPFreeAttempts      int
+	// BaseDelay is the delay after the first failure past the free attempts. It doubles with every failure, up to
+	// MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold is the number of failures that locks an account for LockoutDuration. Zero disables lockouts.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// FailureWindow is how long counters are kept after the last failure.
+	FailureWindow time.Duration
+}
+
+type LoginProtectionService interface {
+	// Check returns a ThrottleError if the account or the IP must wait before trying again.
+	Check(ctx context.Context, email string, ip string, now time.Time) error
+	RecordFailure(ctx context.Context, email string, ip string, now time.Time) error
+	// RecordSuccess resets the counter of the account. The IP counter is kept, so an attacker can't reset it with
+	// an account of their own.
+	RecordSuccess(ctx context.Context, email string) error
+	// Unlock resets the counters of the account and the IP, if set. It is meant for administrators.
+	Unlock(ctx context.Context, email string, ip string) error
+}
+
+// NewLoginProtectionService creates the service. Accounts are counted by the canonical key of their email, computed
+// by emailParser, so aliases of an address share the same counter.
+func NewLoginProtectionService(
+	repository dao.LoginAttemptRepository,
+	emailParser emailaddr.Parser,
+	options LoginProtectionOptions,
+) LoginProtectionService {
+	return &loginProtectionServiceImpl{
+		repository:  repository,
+		emailParser: emailParser,
+		options:     options,
+	}
+}
+
+type loginProtectionServiceImpl struct {
+	repository  dao.LoginAttemptRepository
+	emailParser emailaddr.Parser
+	options     LoginProtectionOptions
+}
+
+// Accounts are counted by email, whether they exist or not, so counters don't reveal which emails are registered.
+func (s *loginProtectionServiceImpl) accountAttemptsKey(email string) string {
+	if address, err := s.emailParser.Parse(email); err == nil {
+		return "account:" + address.Canonical()
+	}
+
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptsKey(ip string) string {
+	return "ip:" + ip
+}
+
+// delay returns how long to wait after the given number of consecutive failures.
+func (s *loginProtectionServiceImpl) delay(failures int, freeAttempts int) time.Duration {
+	if failures < freeAttempts || s.options.BaseDelay <= 0 {
+		return 0
+	}
+
+	output := s.options.BaseDelay
+	for i := freeAttempts; i < failures && output < s.options.MaxDelay; i++ {
+		output *= 2
+	}
+
+	return min(output, s.options.MaxDelay)
+}
+
+func (s *loginProtectionServiceImpl) Check(ctx context.Context, email string, ip string, now time.Time) error {
+	account, err := s.repository.Get(ctx, s.accountAttemptsKey(email), now)
+	if err != nil {
+		return err
+	}
+
+	if s.options.LockoutThreshold > 0 && account.Failures >= s.options.LockoutThreshold {
+		if lockedUntil := account.LastFailureAt.Add(s.options.LockoutDuration); lockedUntil.After(now) {
+			return &ThrottleError{Err: ErrAccountLocked, RetryAfter: lockedUntil.Sub(now)}
+		}
+	}
+
+	retryAt := account.LastFailureAt.Add(s.delay(account.Failures, s.options.AccountFreeAttempts))
+
+	if ip != "" {
+		client, err := s.repository.Get(ctx, ipAttemptsKey(ip), now)
+		if err != nil {
+			return err
+		}
+
+		if clientRetryAt := client.LastFailureAt.Add(s.delay(client.Failures, s.options.IPFreeAttempts)); clientRetryAt.After(retryAt) {
+			retryAt = clientRetryAt
+		}
+	}
+
+	if retryAt.After(now) {
+		return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: retryAt.Sub(now)}
+	}
+
+	return nil
+}
+
+func (s *loginProtectionServiceImpl) RecordFailure(ctx context.Context, email string, ip string, now time.Time) error {
+	if _, err := s.repository.RecordFailure(ctx, s.accountAttemptsKey(email), now, s.options.FailureWindow); err != nil {
+		return err
+	}
+
+	if ip != "" {
+		if _, err := s.repository.RecordFailure(ctx, ipAttemptsKey(ip), now, s.options.FailureWindow); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *loginProtectionServiceImpl) RecordSuccess(ctx context.Context, email string) error {
+	return s.repository.Reset(ctx, s.accountAttemptsKey(email))
+}
+
+func (s *loginProtectionServiceImpl) Unlock(ctx context.Context, email string, ip string) error {
+	if email != "" {
+		if err := s.repository.Reset(ctx, s.accountAttemptsKey(email)); err != nil {
+			return err
+		}
+	}
+
+	if ip != "" {
+		if err := s.repository.Reset(ctx, ipAttemptsKey(ip)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// confirmPassword checks the password of a signed-in user before a sensitive change. Failures are counted with those
+// of the logins, so a stolen session can't be used to guess the password. The counter is left to the caller to
+// reset, once every factor is verified.
+func confirmPassword(
+	ctx context.Context,
+	protection LoginProtectionService,
+	passwordHasher hasher.PasswordHasher,
+	user *models.User,
+	password string,
+	client models.ClientInfo,
+) error {
+	if err := protection.Check(ctx, user.Email, client.IP, time.Now()); err != nil {
+		return err
+	}
+
+	ok, _, err := passwordHasher.Verify(password, user.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err := protection.RecordFailure(ctx, user.Email, client.IP, time.Now()); err != nil {
+			return err
+		}
+
+		return ErrInvalidPassword
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginProtection(t *testing.T) {
+	options := services.LoginProtectionOptions{
+		AccountFreeAttempts: 3,
+		IPFreeAttempts:      5,
+		BaseDelay:           time.Second,
+		MaxDelay:            time.Minute,
+		LockoutThreshold:    6,
+		LockoutDuration:     time.Hour,
+		FailureWindow:       24 * time.Hour,
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		name string
+
+		// failures are recorded one second apart, ending at now.
+		accountFailures int
+		ipFailures      int
+		// elapsed is the time between the last failure and the check.
+		elapsed time.Duration
+
+		expectErr        error
+		expectRetryAfter time.Duration
+	}{
+		{
+			name:            "FreeAttempts",
+			accountFailures: 2,
+		},
+		{
+			name:             "Backoff",
+			accountFailures:  3,
+			expectErr:        services.ErrTooManyAttempts,
+			expectRetryAfter: time.Second,
+		},
+		{
+			name:             "ExponentialBackoff",
+			accountFailures:  5,
+			elapsed:          time.Second,
+			expectErr:        services.ErrTooManyAttempts,
+			expectRetryAfter: 3 * time.Second,
+		},
+		{
+			name:            "BackoffElapsed",
+			accountFailures: 5,
+			elapsed:         4 * time.Second,
+		},
+		{
+			name:             "Lockout",
+			accountFailures:  6,
+			elapsed:          time.Minute,
+			expectErr:        services.ErrAccountLocked,
+			expectRetryAfter: 59 * time.Minute,
+		},
+		{
+			name:            "LockoutElapsed",
+			accountFailures: 6,
+			elapsed:         time.Hour,
+		},
+		{
+			name:            "WindowElapsed",
+			accountFailures: 6,
+			elapsed:         24 * time.Hour,
+		},
+		{
+			name:             "IPBackoff",
+			ipFailures:       7,
+			expectErr:        services.ErrTooManyAttempts,
+			expectRetryAfter: 4 * time.Second,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			service := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, options)
+
+			for i := 0; i < d.accountFailures; i++ {
+				failedAt := now.Add(time.Duration(i-d.accountFailures+1) * time.Second)
+				require.NoError(t, service.RecordFailure(context.Background(), "user@example.com", "", failedAt))
+			}
+			for i := 0; i < d.ipFailures; i++ {
+				failedAt := now.Add(time.Duration(i-d.ipFailures+1) * time.Second)
+				require.NoError(t, service.RecordFailure(context.Background(), "other@example.com", "10.0.0.1", failedAt))
+			}
+
+			err := service.Check(context.Background(), "User@Example.com", "10.0.0.1", now.Add(d.elapsed))
+			require.ErrorIs(t, err, d.expectErr)
+
+			if d.expectErr != nil {
+				var throttleErr *services.ThrottleError
+				require.ErrorAs(t, err, &throttleErr)
+				require.Equal(t, d.expectRetryAfter, throttleErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestLoginProtectionUnlock(t *testing.T) {
+	service := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{
+		LockoutThreshold: 1,
+		LockoutDuration:  time.Hour,
+		FailureWindow:    24 * time.Hour,
+	})
+
+	now := time.Now()
+
+	require.NoError(t, service.RecordFailure(context.Background(), "user@example.com", "10.0.0.1", now))
+	require.ErrorIs(t, service.Check(context.Background(), "user@example.com", "", now), services.ErrAccountLocked)
+
+	require.NoError(t, service.Unlock(context.Background(), "user@example.com", ""))
+	require.NoError(t, service.Check(context.Background(), "user@example.com", "", now))
+}
+
+func TestLoginProtectionAliases(t *testing.T) {
+	service := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{
+		LockoutThreshold: 2,
+		LockoutDuration:  time.Hour,
+		FailureWindow:    24 * time.Hour,
+	})
+
+	now := time.Now()
+
+	// Aliases of the same mailbox share a single counter.
+	require.NoError(t, service.RecordFailure(context.Background(), "user@gmail.com", "10.0.0.1", now))
+	require.NoError(t, service.RecordFailure(context.Background(), "U.ser+1@gmail.com", "10.0.0.2", now))
+	require.ErrorIs(t, service.Check(context.Background(), "user+2@gmail.com", "", now), services.ErrAccountLocked)
+}
+
+func TestLoginProtectionPasswordConfirmation(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	client := models.ClientInfo{IP: "10.0.0.1"}
+	principal := &models.Principal{UserID: "user-1"}
+
+	data := []struct {
+		name string
+
+		exec func(protection services.LoginProtectionService, users *userRepositoryMock, password string) error
+	}{
+		{
+			name: "ChangePassword",
+			exec: func(protection services.LoginProtectionService, users *userRepositoryMock, password string) error {
+				service := services.NewChangePasswordService(
+					users, passwordHasher, policy.NewPasswordPolicy(policy.PasswordOptions{}, nil), newSessionRepositoryMock(), protection,
+				)
+				return service.Exec(ctx, principal, password, "new password", false, client)
+			},
+		},
+		{
+			name: "DisableMFA",
+			exec: func(protection services.LoginProtectionService, users *userRepositoryMock, password string) error {
+				service := services.NewDisableMFAService(users, passwordHasher, newMFARepositoryMock(), protection, 1)
+				return service.Exec(ctx, principal, password, "000000", client)
+			},
+		},
+		{
+			name: "RequestUserDeletion",
+			exec: func(protection services.LoginProtectionService, users *userRepositoryMock, password string) error {
+				service := services.NewRequestUserDeletionService(
+					users,
+					passwordHasher,
+					newMFARepositoryMock(),
+					services.NewLogoutAllService(newRevocationRepositoryMock(), newSessionRepositoryMock(), time.Hour),
+					protection,
+					new(mailerMock),
+					new(taskRunnerMock),
+					24*time.Hour,
+					1,
+				)
+				_, err := service.Exec(ctx, principal, password, "", client)
+				return err
+			},
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: passwordHashed})
+			protection := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{
+				LockoutThreshold: 3,
+				LockoutDuration:  time.Hour,
+				FailureWindow:    24 * time.Hour,
+			})
+
+			require.ErrorIs(t, d.exec(protection, users, "wrong"), services.ErrInvalidPassword)
+			require.ErrorIs(t, d.exec(protection, users, "wrong"), services.ErrInvalidPassword)
+
+			// Failures are shared with the logins, so the account is locked for both.
+			require.NoError(t, protection.RecordFailure(ctx, "user@example.com", "10.0.0.2", time.Now()))
+			require.ErrorIs(t, protection.Check(ctx, "user@example.com", "", time.Now()), services.ErrAccountLocked)
+
+			// Once locked, even the right password is rejected.
+			err := d.exec(protection, users, "password")
+			require.ErrorIs(t, err, services.ErrAccountLocked)
+
+			var throttleErr *services.ThrottleError
+			require.ErrorAs(t, err, &throttleErr)
+			require.Positive(t, throttleErr.RetryAfter)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/hasher"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHasher(t *testing.T) hasher.PasswordHasher {
+	passwordHasher, err := hasher.NewPasswordHasher(hasher.Options{
+		Algorithm: hasher.AlgorithmArgon2id,
+		Argon2id:  hasher.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+	})
+	require.NoError(t, err)
+
+	return passwordHasher
+}
+
+func newLoginService(t *testing.T, users dao.UserRepository, passwordHasher hasher.PasswordHasher) services.LoginService {
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+
+	issueSession := services.NewIssueSessionService(
+		newSessionRepositoryMock(),
+		users,
+		services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}),
+		time.Hour,
+	)
+	protection := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{
+		AccountFreeAttempts: 2,
+		IPFreeAttempts:      10,
+		BaseDelay:           time.Minute,
+		MaxDelay:            time.Hour,
+		FailureWindow:       time.Hour,
+	})
+
+	mfaChallenge := services.NewMFAChallengeService(newMFARepositoryMock(), newActionTokenRepositoryMock(), time.Minute)
+
+	return services.NewLoginService(users, testEmailParser, passwordHasher, protection, mfaChallenge, issueSession, false)
+}
+
+func TestLogin(t *testing.T) {
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Username: "John", Password: passwordHashed})
+	service := newLoginService(t, users, passwordHasher)
+
+	data := []struct {
+		name string
+
+		identifier string
+		password   string
+
+		expectErr error
+	}{
+		{
+			name:       "Success",
+			identifier: "user@example.com",
+			password:   "password",
+		},
+		{
+			name:       "WrongPassword",
+			identifier: "user@example.com",
+			password:   "wrong",
+			expectErr:  services.ErrInvalidCredentials,
+		},
+		{
+			name:       "NormalizedEmail",
+			identifier: " User@Example.COM ",
+			password:   "password",
+		},
+		{
+			name:       "Username",
+			identifier: "john",
+			password:   "password",
+		},
+		{
+			name:       "UnknownUsername",
+			identifier: "jane",
+			password:   "password",
+			expectErr:  services.ErrInvalidCredentials,
+		},
+		{
+			name:       "InvalidEmail",
+			identifier: "not an email",
+			password:   "password",
+			expectErr:  services.ErrInvalidCredentials,
+		},
+		{
+			name:       "UnknownEmail",
+			identifier: "unknown@example.com",
+			password:   "password",
+			expectErr:  services.ErrInvalidCredentials,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			res, err := service.Exec(context.Background(), d.identifier, d.password, models.ClientInfo{IP: "10.0.0.1"})
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				require.Equal(t, "user-1", res.User.ID)
+				require.NotEmpty(t, res.Credentials.RefreshToken)
+				require.Nil(t, res.MFAChallenge)
+			}
+		})
+	}
+}
+
+func TestLoginThrottling(t *testing.T) {
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: passwordHashed})
+	service := newLoginService(t, users, passwordHasher)
+
+	for i := 0; i < 2; i++ {
+		_, err := service.Exec(context.Background(), "user@example.com", "wrong", models.ClientInfo{IP: "10.0.0.1"})
+		require.ErrorIs(t, err, services.ErrInvalidCredentials)
+	}
+
+	// Even the right password is rejected until the delay is over.
+	_, err = service.Exec(context.Background(), "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrTooManyAttempts)
+
+	// Unknown emails are throttled the same way.
+	for i := 0; i < 2; i++ {
+		_, err := service.Exec(context.Background(), "unknown@example.com", "wrong", models.ClientInfo{IP: "10.0.0.2"})
+		require.ErrorIs(t, err, services.ErrInvalidCredentials)
+	}
+
+	_, err = service.Exec(context.Background(), "unknown@example.com", "wrong", models.ClientInfo{IP: "10.0.0.2"})
+	require.ErrorIs(t, err, services.ErrTooManyAttempts)
+}
+
+func TestLoginThrottlingIdentifiers(t *testing.T) {
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Username: "john", Password: passwordHashed})
+	service := newLoginService(t, users, passwordHasher)
+
+	// Failures with the email and the username count for the same account.
+	_, err = service.Exec(context.Background(), "user@example.com", "wrong", models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidCredentials)
+	_, err = service.Exec(context.Background(), "john", "wrong", models.ClientInfo{IP: "10.0.0.2"})
+	require.ErrorIs(t, err, services.ErrInvalidCredentials)
+
+	_, err = service.Exec(context.Background(), "JOHN", "password", models.ClientInfo{IP: "10.0.0.3"})
+	require.ErrorIs(t, err, services.ErrTooManyAttempts)
+}
+
+func TestLoginRehash(t *testing.T) {
+	passwordHasher := newTestHasher(t)
+
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: string(legacyHash)})
+	service := newLoginService(t, users, passwordHasher)
+
+	_, err = service.Exec(context.Background(), "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	user, err := users.GetUser(context.Background(), "user-1")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(user.Password, "$argon2id$"), user.Password)
+
+	// The new hash is used from then on.
+	_, err = service.Exec(context.Background(), "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"time"
+)
+
+type LogoutService interface {
+	// Exec revokes the token the principal authenticated with, and the session it was issued for.
+	Exec(ctx context.Context, principal *models.Principal) error
+}
+
+func NewLogoutService(revocations dao.RevocationRepository, sessions dao.SessionRepository) LogoutService {
+	return &logoutServiceImpl{
+		revocations: revocations,
+		sessions:    sessions,
+	}
+}
+
+type logoutServiceImpl struct {
+	revocations dao.RevocationRepository
+	sessions    dao.SessionRepository
+}
+
+func (s *logoutServiceImpl) Exec(ctx context.Context, principal *models.Principal) error {
+	now := time.Now()
+	token := principal.Token
+	if err := s.revocations.RevokeToken(ctx, token.Header.ID.String(), token.Payload.ID, token.Header.EXP); err != nil {
+		return err
+	}
+
+	// Prevent the session from issuing new tokens.
+	if token.Payload.SessionID != "" {
+		if err := s.sessions.Revoke(ctx, token.Payload.SessionID, now); err != nil && !errors.Is(err, dao.ErrSessionNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type LogoutAllService interface {
+	// Exec revokes every token and session of the principal.
+	Exec(ctx context.Context, principal *models.Principal) error
+}
+
+// NewLogoutAllService creates a service to log a user out of every device. tokenTTL must be the lifetime of access
+// tokens, so the revocation is kept until every token it covers has expired.
+func NewLogoutAllService(revocations dao.RevocationRepository, sessions dao.SessionRepository, tokenTTL time.Duration) LogoutAllService {
+	return &logoutAllServiceImpl{
+		revocations: revocations,
+		sessions:    sessions,
+		tokenTTL:    tokenTTL,
+	}
+}
+
+type logoutAllServiceImpl struct {
+	revocations dao.RevocationRepository
+	sessions    dao.SessionRepository
+	tokenTTL    time.Duration
+}
+
+func (s *logoutAllServiceImpl) Exec(ctx context.Context, principal *models.Principal) error {
+	now := time.Now()
+	userID := principal.UserID
+
+	// Revoke sessions first, so no new token can be issued once access tokens are revoked.
+	if err := s.sessions.RevokeUserSessions(ctx, userID, "", now); err != nil {
+		return err
+	}
+
+	if err := s.revocations.RevokeUserTokens(ctx, userID, now, now.Add(s.tokenTTL)); err != nil {
+		return err
+	}
+
+	return nil
+}
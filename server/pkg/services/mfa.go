@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/hasher"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/otp"
+	"time"
+)
+
+var (
+	ErrInvalidMFACode      = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid two-factor authentication challenge")
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of base32 characters of a recovery code, so 50 bits.
+	recoveryCodeLength = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns recovery codes formatted for the user, like abcde-fghij, and their hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		raw := make([]byte, recoveryCodeLength*5/8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i] = hashSecret(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeMFACode removes the separators users may type, and lowercases recovery codes.
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// verifySecondFactor accepts a TOTP code or a recovery code, and makes sure neither can be used twice.
+func verifySecondFactor(ctx context.Context, repository dao.MFARepository, mfa *models.MFA, code string, skew int, now time.Time) error {
+	if !mfa.TOTPEnabled() {
+		return dao.ErrMFANotEnabled
+	}
+
+	code = normalizeMFACode(code)
+
+	if len(code) == otp.Digits {
+		counter, ok, err := otp.Validate(mfa.TOTPSecret, code, now, skew)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		if err := repository.UseTOTPCounter(ctx, mfa.UserID, counter); err != nil {
+			if errors.Is(err, dao.ErrTOTPCodeReused) {
+				return errors.Join(ErrInvalidMFACode, err)
+			}
+
+			return err
+		}
+
+		return nil
+	}
+
+	if err := repository.UseRecoveryCode(ctx, mfa.UserID, hashSecret(code)); err != nil {
+		if errors.Is(err, dao.ErrRecoveryCodeNotFound) {
+			return errors.Join(ErrInvalidMFACode, err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+type EnrollTOTPService interface {
+	// Exec generates a new TOTP secret for the principal. It is only used once confirmed with a valid code.
+	Exec(ctx context.Context, principal *models.Principal) (*models.TOTPEnrollment, error)
+}
+
+// NewEnrollTOTPService creates the enrolment service. The issuer is the name shown in authenticator apps.
+func NewEnrollTOTPService(users dao.UserRepository, mfa dao.MFARepository, issuer string) EnrollTOTPService {
+	return &enrollTOTPServiceImpl{
+		users:  users,
+		mfa:    mfa,
+		issuer: issuer,
+	}
+}
+
+type enrollTOTPServiceImpl struct {
+	users  dao.UserRepository
+	mfa    dao.MFARepository
+	issuer string
+}
+
+func (s *enrollTOTPServiceImpl) Exec(ctx context.Context, principal *models.Principal) (*models.TOTPEnrollment, error) {
+	user, err := s.users.GetUser(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := otp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfa.SetPendingTOTP(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{Secret: secret, URI: otp.ProvisioningURI(s.issuer, user.Email, secret)}, nil
+}
+
+type ConfirmTOTPService interface {
+	// Exec enables TOTP once the code proves the app of the user is set up, and returns the recovery codes. They are
+	// only shown this once.
+	Exec(ctx context.Context, principal *models.Principal, code string) ([]string, error)
+}
+
+// NewConfirmTOTPService creates the confirmation service. Codes are accepted up to skew time steps early or late.
+func NewConfirmTOTPService(mfa dao.MFARepository, skew int) ConfirmTOTPService {
+	return &confirmTOTPServiceImpl{
+		mfa:  mfa,
+		skew: skew,
+	}
+}
+
+type confirmTOTPServiceImpl struct {
+	mfa  dao.MFARepository
+	skew int
+}
+
+func (s *confirmTOTPServiceImpl) Exec(ctx context.Context, principal *models.Principal, code string) ([]string, error) {
+	mfa, err := s.mfa.Get(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa.TOTPEnabled() {
+		return nil, dao.ErrMFAAlreadyEnabled
+	}
+	if mfa.TOTPPendingSecret == "" {
+		return nil, dao.ErrNoPendingTOTP
+	}
+
+	counter, ok, err := otp.Validate(mfa.TOTPPendingSecret, normalizeMFACode(code), time.Now(), s.skew)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfa.EnableTOTP(ctx, principal.UserID, counter, recoveryCodeHashes, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+type DisableMFAService interface {
+	// Exec removes the second factors of the principal. The password and a valid code are required, so a stolen
+	// session is not enough. Wrong passwords are throttled like failed logins.
+	Exec(ctx context.Context, principal *models.Principal, password string, code string, client models.ClientInfo) error
+}
+
+func NewDisableMFAService(
+	users dao.UserRepository,
+	passwordHasher hasher.PasswordHasher,
+	mfa dao.MFARepository,
+	protection LoginProtectionService,
+	skew int,
+) DisableMFAService {
+	return &disableMFAServiceImpl{
+		users:      users,
+		hasher:     passwordHasher,
+		mfa:        mfa,
+		protection: protection,
+		skew:       skew,
+	}
+}
+
+type disableMFAServiceImpl struct {
+	users      dao.UserRepository
+	hasher     hasher.PasswordHasher
+	mfa        dao.MFARepository
+	protection LoginProtectionService
+	skew       int
+}
+
+func (s *disableMFAServiceImpl) Exec(ctx context.Context, principal *models.Principal, password string, code string, client models.ClientInfo) error {
+	user, err := s.users.GetUser(ctx, principal.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmPassword(ctx, s.protection, s.hasher, user, password, client); err != nil {
+		return err
+	}
+
+	mfa, err := s.mfa.Get(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := verifySecondFactor(ctx, s.mfa, mfa, code, s.skew, time.Now()); err != nil {
+		return err
+	}
+
+	if err := s.protection.RecordSuccess(ctx, user.Email); err != nil {
+		return err
+	}
+
+	return s.mfa.Disable(ctx, user.ID)
+}
+
+type MFAChallengeService interface {
+	// Challenge returns a challenge if the user must confirm their login with a second factor, and nil otherwise.
+	Challenge(ctx context.Context, user *models.User) (*models.MFAChallenge, error)
+}
+
+// NewMFAChallengeService creates the challenge service. Challenges must be answered within challengeTTL.
+func NewMFAChallengeService(mfa dao.MFARepository, tokens dao.ActionTokenRepository, challengeTTL time.Duration) MFAChallengeService {
+	return &mfaChallengeServiceImpl{
+		mfa:          mfa,
+		tokens:       tokens,
+		challengeTTL: challengeTTL,
+	}
+}
+
+type mfaChallengeServiceImpl struct {
+	mfa          dao.MFARepository
+	tokens       dao.ActionTokenRepository
+	challengeTTL time.Duration
+}
+
+func (s *mfaChallengeServiceImpl) Challenge(ctx context.Context, user *models.User) (*models.MFAChallenge, error) {
+	mfa, err := s.mfa.Get(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !mfa.TOTPEnabled() {
+		return nil, nil
+	}
+
+	token, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	actionToken, err := s.tokens.Create(ctx, hashSecret(token), models.ActionTokenMFAChallenge, user.ID, user.Email, now, now.Add(s.challengeTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []string{models.MFAMethodTOTP}
+	if len(mfa.RecoveryCodeHashes) > 0 {
+		methods = append(methods, models.MFAMethodRecoveryCode)
+	}
+
+	return &models.MFAChallenge{Token: token, ExpiresAt: actionToken.ExpiresAt, Methods: methods}, nil
+}
+
+type LoginMFAService interface {
+	// Exec completes a login with the challenge token and a TOTP or recovery code. Failed codes are throttled like
+	// failed passwords.
+	Exec(ctx context.Context, challengeToken string, code string, client models.ClientInfo) (*models.User, *models.Credentials, error)
+}
+
+func NewLoginMFAService(
+	users dao.UserRepository,
+	mfa dao.MFARepository,
+	tokens dao.ActionTokenRepository,
+	protection LoginProtectionService,
+	issueSession IssueSessionService,
+	skew int,
+) LoginMFAService {
+	return &loginMFAServiceImpl{
+		users:        users,
+		mfa:          mfa,
+		tokens:       tokens,
+		protection:   protection,
+		issueSession: issueSession,
+		skew:         skew,
+	}
+}
+
+type loginMFAServiceImpl struct {
+	users        dao.UserRepository
+	mfa          dao.MFARepository
+	tokens       dao.ActionTokenRepository
+	protection   LoginProtectionService
+	issueSession IssueSessionService
+	skew         int
+}
+
+// invalidMFAChallenge wraps the errors of unusable challenge tokens into ErrInvalidMFAChallenge.
+func invalidMFAChallenge(err error) error {
+	if errors.Is(err, dao.ErrActionTokenNotFound) ||
+		errors.Is(err, dao.ErrActionTokenExpired) ||
+		errors.Is(err, dao.ErrActionTokenUsed) {
+		return errors.Join(ErrInvalidMFAChallenge, err)
+	}
+
+	return err
+}
+
+func (s *loginMFAServiceImpl) Exec(ctx context.Context, challengeToken string, code string, client models.ClientInfo) (*models.User, *models.Credentials, error) {
+	now := time.Now()
+	tokenHash := hashSecret(challengeToken)
+
+	// The challenge is only consumed once answered, so a typo does not require logging in again.
+	challenge, err := s.tokens.Get(ctx, tokenHash, models.ActionTokenMFAChallenge, now)
+	if err != nil {
+		return nil, nil, invalidMFAChallenge(err)
+	}
+
+	if err := s.protection.Check(ctx, challenge.Email, client.IP, now); err != nil {
+		return nil, nil, err
+	}
+
+	mfa, err := s.mfa.Get(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := verifySecondFactor(ctx, s.mfa, mfa, code, s.skew, now); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.protection.RecordFailure(ctx, challenge.Email, client.IP, now); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		return nil, nil, err
+	}
+
+	if _, err := s.tokens.Consume(ctx, tokenHash, models.ActionTokenMFAChallenge, now); err != nil {
+		return nil, nil, invalidMFAChallenge(err)
+	}
+
+	if err := s.protection.RecordSuccess(ctx, challenge.Email); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.users.GetUser(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credentials, err := s.issueSession.IssueSession(ctx, user.ID, client, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, credentials, nil
+}
@@ -0,0 +1,118 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/otp"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func totpCode(t *testing.T, secret string, counter int64) string {
+	code, err := otp.Code(secret, counter)
+	require.NoError(t, err)
+
+	return code
+}
+
+func TestTOTPLogin(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: passwordHashed})
+	mfa := newMFARepositoryMock()
+	tokens := newActionTokenRepositoryMock()
+	principal := &models.Principal{UserID: "user-1"}
+
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	issueSession := services.NewIssueSessionService(
+		newSessionRepositoryMock(),
+		users,
+		services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}),
+		time.Hour,
+	)
+	protection := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{
+		AccountFreeAttempts: 10,
+		IPFreeAttempts:      10,
+		BaseDelay:           time.Minute,
+		MaxDelay:            time.Hour,
+		FailureWindow:       time.Hour,
+	})
+
+	login := services.NewLoginService(
+		users, testEmailParser, passwordHasher, protection, services.NewMFAChallengeService(mfa, tokens, time.Minute), issueSession, false,
+	)
+	loginMFA := services.NewLoginMFAService(users, mfa, tokens, protection, issueSession, 1)
+
+	// Enrolment is only effective once confirmed.
+	enrollment, err := services.NewEnrollTOTPService(users, mfa, "Test").Exec(ctx, principal)
+	require.NoError(t, err)
+	require.Contains(t, enrollment.URI, "otpauth://totp/")
+
+	res, err := login.Exec(ctx, "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Nil(t, res.MFAChallenge)
+
+	confirm := services.NewConfirmTOTPService(mfa, 1)
+
+	_, err = confirm.Exec(ctx, principal, "000000")
+	require.ErrorIs(t, err, services.ErrInvalidMFACode)
+
+	now := time.Now()
+	recoveryCodes, err := confirm.Exec(ctx, principal, totpCode(t, enrollment.Secret, otp.Counter(now)))
+	require.NoError(t, err)
+	require.NotEmpty(t, recoveryCodes)
+
+	// The password alone now only yields a challenge.
+	res, err = login.Exec(ctx, "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Nil(t, res.Credentials)
+	require.NotNil(t, res.MFAChallenge)
+	require.Equal(t, []string{models.MFAMethodTOTP, models.MFAMethodRecoveryCode}, res.MFAChallenge.Methods)
+
+	// The code used for the confirmation cannot be replayed.
+	_, _, err = loginMFA.Exec(ctx, res.MFAChallenge.Token, totpCode(t, enrollment.Secret, otp.Counter(now)), models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidMFACode)
+
+	user, credentials, err := loginMFA.Exec(ctx, res.MFAChallenge.Token, totpCode(t, enrollment.Secret, otp.Counter(now)+1), models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Equal(t, "user-1", user.ID)
+	require.NotEmpty(t, credentials.RefreshToken)
+
+	// A challenge is only answered once.
+	_, _, err = loginMFA.Exec(ctx, res.MFAChallenge.Token, recoveryCodes[0], models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidMFAChallenge)
+
+	// Recovery codes work once each.
+	res, err = login.Exec(ctx, "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	_, _, err = loginMFA.Exec(ctx, res.MFAChallenge.Token, recoveryCodes[0], models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	res, err = login.Exec(ctx, "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+
+	_, _, err = loginMFA.Exec(ctx, res.MFAChallenge.Token, recoveryCodes[0], models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidMFACode)
+
+	// Disabling requires the password and a second factor.
+	disable := services.NewDisableMFAService(users, passwordHasher, mfa, protection, 1)
+
+	err = disable.Exec(ctx, principal, "wrong", recoveryCodes[1], models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidPassword)
+
+	require.NoError(t, disable.Exec(ctx, principal, "password", recoveryCodes[1], models.ClientInfo{IP: "10.0.0.1"}))
+
+	res, err = login.Exec(ctx, "user@example.com", "password", models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Nil(t, res.MFAChallenge)
+}
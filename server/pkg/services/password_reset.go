@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/mail"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"time"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+)
+
+type ForgotPasswordService interface {
+	// Exec sends a password reset link to the email, if it belongs to a user. The result is the same whether the
+	// user exists or not, so the service can't be used to discover accounts.
+	Exec(ctx context.Context, email string) error
+}
+
+// NewForgotPasswordService creates a service that emails reset links. Links point to resetURL, with the token in the
+// token query parameter, and expire after tokenTTL. Links are created and sent with tasks, so known and unknown emails
+// take the same time to answer.
+func NewForgotPasswordService(
+	users dao.UserRepository,
+	emailParser emailaddr.Parser,
+	tokens dao.ActionTokenRepository,
+	mailer mail.Mailer,
+	tasks TaskRunner,
+	resetURL string,
+	tokenTTL time.Duration,
+) ForgotPasswordService {
+	return &forgotPasswordServiceImpl{
+		users:       users,
+		emailParser: emailParser,
+		tokens:      tokens,
+		mailer:      mailer,
+		tasks:       tasks,
+		resetURL:    resetURL,
+		tokenTTL:    tokenTTL,
+	}
+}
+
+type forgotPasswordServiceImpl struct {
+	users       dao.UserRepository
+	emailParser emailaddr.Parser
+	tokens      dao.ActionTokenRepository
+	mailer      mail.Mailer
+	tasks       TaskRunner
+	resetURL    string
+	tokenTTL    time.Duration
+}
+
+func (s *forgotPasswordServiceImpl) Exec(ctx context.Context, email string) error {
+	user, err := s.users.GetUserByEmail(ctx, normalizeEmail(s.emailParser, email))
+	if err != nil {
+		if errors.Is(err, dao.ErrUserNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	s.tasks.Go("send_password_reset_link", func(ctx context.Context) error {
+		return s.sendResetLink(ctx, user, time.Now())
+	})
+
+	return nil
+}
+
+// sendResetLink creates a reset token for the user, and emails them the link.
+func (s *forgotPasswordServiceImpl) sendResetLink(ctx context.Context, user *models.User, now time.Time) error {
+	token, err := newSecret()
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.tokens.Create(ctx, hashSecret(token), models.ActionTokenPasswordReset, user.ID, user.Email, now, now.Add(s.tokenTTL)); err != nil {
+		return err
+	}
+
+	link, err := buildLink(s.resetURL, token)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nUse the link below to choose a new password. It expires in %s, and can only be used once.\n\n%s\n\n"+
+				"If you did not ask to reset your password, you can ignore this email.\n",
+			user.Username, s.tokenTTL, link,
+		),
+	})
+}
+
+type ResetPasswordService interface {
+	// Exec sets a new password for the owner of the reset token, and logs them out of every device.
+	Exec(ctx context.Context, token string, password string) error
+}
+
+func NewResetPasswordService(
+	users dao.UserRepository, passwordPolicy policy.PasswordPolicy, tokens dao.ActionTokenRepository, logoutAll LogoutAllService,
+) ResetPasswordService {
+	return &resetPasswordServiceImpl{
+		users:          users,
+		passwordPolicy: passwordPolicy,
+		tokens:         tokens,
+		logoutAll:      logoutAll,
+	}
+}
+
+type resetPasswordServiceImpl struct {
+	users          dao.UserRepository
+	passwordPolicy policy.PasswordPolicy
+	tokens         dao.ActionTokenRepository
+	logoutAll      LogoutAllService
+}
+
+// invalidResetToken wraps the errors of unusable tokens into ErrInvalidResetToken.
+func invalidResetToken(err error) error {
+	if errors.Is(err, dao.ErrActionTokenNotFound) ||
+		errors.Is(err, dao.ErrActionTokenExpired) ||
+		errors.Is(err, dao.ErrActionTokenUsed) {
+		return errors.Join(ErrInvalidResetToken, err)
+	}
+
+	return err
+}
+
+func (s *resetPasswordServiceImpl) Exec(ctx context.Context, token string, password string) error {
+	if password == "" {
+		return errors.Join(ErrInvalidEntity, ErrMissingPassword)
+	}
+
+	tokenHash := hashSecret(token)
+
+	// The password is checked before the token is consumed, so the user can try another one with the same link.
+	actionToken, err := s.tokens.Get(ctx, tokenHash, models.ActionTokenPasswordReset, time.Now())
+	if err != nil {
+		return invalidResetToken(err)
+	}
+
+	user, err := s.users.GetUser(ctx, actionToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPassword(ctx, s.passwordPolicy, password, user.Email, user.Username); err != nil {
+		return err
+	}
+
+	if _, err := s.tokens.Consume(ctx, tokenHash, models.ActionTokenPasswordReset, time.Now()); err != nil {
+		return invalidResetToken(err)
+	}
+
+	if err := s.users.UpdatePassword(ctx, actionToken.UserID, password); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password must lose access.
+	if err := s.logoutAll.Exec(ctx, &models.Principal{UserID: actionToken.UserID}); err != nil {
+		return err
+	}
+
+	return nil
+}
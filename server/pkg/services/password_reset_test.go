@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForgotPassword(t *testing.T) {
+	data := []struct {
+		name string
+
+		email   string
+		mailErr error
+
+		expectSent     bool
+		expectFailures int
+	}{
+		{
+			name:       "Success",
+			email:      "User@Example.com",
+			expectSent: true,
+		},
+		{
+			name:  "UnknownEmail",
+			email: "unknown@example.com",
+		},
+		{
+			// The client can't tell a failed delivery from an unknown email.
+			name:           "MailFailure",
+			email:          "user@example.com",
+			mailErr:        errors.New("smtp unavailable"),
+			expectFailures: 1,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(nil, &models.User{ID: "user-1", Email: "user@example.com"})
+			mailer := &mailerMock{err: d.mailErr}
+			tasks := new(taskRunnerMock)
+
+			service := services.NewForgotPasswordService(
+				users, testEmailParser, newActionTokenRepositoryMock(), mailer, tasks, "https://example.com/password/reset", time.Hour,
+			)
+
+			require.NoError(t, service.Exec(context.Background(), d.email))
+			require.Len(t, tasks.failures, d.expectFailures)
+
+			if d.expectSent {
+				require.Len(t, mailer.sent, 1)
+				require.Equal(t, "user@example.com", mailer.sent[0].To)
+				require.NotEmpty(t, sentToken(t, mailer.sent[0]))
+			} else {
+				require.Empty(t, mailer.sent)
+			}
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	passwordHashed, err := passwordHasher.Hash("password")
+	require.NoError(t, err)
+
+	passwordPolicy := policy.NewPasswordPolicy(policy.PasswordOptions{MinLength: 8}, nil)
+
+	data := []struct {
+		name string
+
+		purpose   models.ActionTokenPurpose
+		expiresIn time.Duration
+		// passwords are submitted in order, with the same token.
+		passwords []string
+
+		expectErrs []error
+		// expectViolations are the violations of the last password, if it was rejected by the policy.
+		expectViolations []string
+		expectPassword   string
+		expectLoggedOut  bool
+	}{
+		{
+			name:            "Success",
+			purpose:         models.ActionTokenPasswordReset,
+			expiresIn:       time.Hour,
+			passwords:       []string{"new password"},
+			expectErrs:      []error{nil},
+			expectPassword:  "new password",
+			expectLoggedOut: true,
+		},
+		{
+			name:            "SingleUse",
+			purpose:         models.ActionTokenPasswordReset,
+			expiresIn:       time.Hour,
+			passwords:       []string{"new password", "other password"},
+			expectErrs:      []error{nil, services.ErrInvalidResetToken},
+			expectPassword:  "new password",
+			expectLoggedOut: true,
+		},
+		{
+			// The token is only consumed once the password is accepted, so the user can try another one.
+			name:            "PolicyViolation",
+			purpose:         models.ActionTokenPasswordReset,
+			expiresIn:       time.Hour,
+			passwords:       []string{"short", "new password"},
+			expectErrs:      []error{services.ErrInvalidEntity, nil},
+			expectPassword:  "new password",
+			expectLoggedOut: true,
+		},
+		{
+			name:             "PasswordContainsEmail",
+			purpose:          models.ActionTokenPasswordReset,
+			expiresIn:        time.Hour,
+			passwords:        []string{"user password"},
+			expectErrs:       []error{services.ErrInvalidEntity},
+			expectViolations: []string{"password:" + policy.CodeContainsUserInfo},
+			expectPassword:   "password",
+		},
+		{
+			name:           "Expired",
+			purpose:        models.ActionTokenPasswordReset,
+			expiresIn:      -time.Minute,
+			passwords:      []string{"new password"},
+			expectErrs:     []error{services.ErrInvalidResetToken},
+			expectPassword: "password",
+		},
+		{
+			name:           "WrongPurpose",
+			purpose:        models.ActionTokenEmailVerification,
+			expiresIn:      time.Hour,
+			passwords:      []string{"new password"},
+			expectErrs:     []error{services.ErrInvalidResetToken},
+			expectPassword: "password",
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(passwordHasher, &models.User{ID: "user-1", Email: "user@example.com", Password: passwordHashed})
+			sessions := newSessionRepositoryMock(&models.Session{ID: "session-1", UserID: "user-1"})
+			revocations := newRevocationRepositoryMock()
+			tokens := newActionTokenRepositoryMock()
+
+			now := time.Now()
+			_, err := tokens.Create(ctx, hashToken("token"), d.purpose, "user-1", "user@example.com", now, now.Add(d.expiresIn))
+			require.NoError(t, err)
+
+			service := services.NewResetPasswordService(
+				users, passwordPolicy, tokens, services.NewLogoutAllService(revocations, sessions, time.Hour),
+			)
+
+			for i, password := range d.passwords {
+				err = service.Exec(ctx, "token", password)
+				require.ErrorIs(t, err, d.expectErrs[i], i)
+			}
+			if d.expectViolations != nil {
+				require.Equal(t, d.expectViolations, violationCodes(t, err))
+			}
+
+			ok, _, err := passwordHasher.Verify(d.expectPassword, users.users["user-1"].Password)
+			require.NoError(t, err)
+			require.True(t, ok)
+
+			// Whoever knew the old password loses access.
+			_, revoked := revocations.users["user-1"]
+			require.Equal(t, d.expectLoggedOut, revoked)
+			require.Equal(t, d.expectLoggedOut, sessions.sessions["session-1"].RevokedAt != nil)
+		})
+	}
+}
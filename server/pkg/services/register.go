@@ -3,11 +3,13 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"technical-interview/pkg/dao"
+	"technical-interview/pkg/emailaddr"
 	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 var (
@@ -17,23 +19,126 @@ var (
 	ErrMissingUsername = errors.New("missing username")
 )
 
+// ValidationError lists the rules an entity breaks. It matches ErrInvalidEntity.
+type ValidationError struct {
+	Violations []models.Violation
+}
+
+func (e *ValidationError) Error() string {
+	details := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		details[i] = fmt.Sprintf("%s: %s", violation.Field, violation.Code)
+	}
+
+	return fmt.Sprintf("%s (%s)", ErrInvalidEntity, strings.Join(details, ", "))
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidEntity
+}
+
+// codeInvalid is the violation code of fields with a malformed value, like emails that are not valid addresses.
+const codeInvalid = "invalid"
+
+// parseEmail returns the normalized form of an email typed by a user, or a ValidationError if it is not a valid
+// address.
+func parseEmail(parser emailaddr.Parser, email string) (emailaddr.Address, error) {
+	address, err := parser.Parse(email)
+	if err != nil {
+		return emailaddr.Address{}, &ValidationError{Violations: []models.Violation{
+			{Field: "email", Code: codeInvalid, Message: err.Error()},
+		}}
+	}
+
+	return address, nil
+}
+
+// normalizeEmail returns the normalized form of an email typed by a user, to look it up. Invalid emails are returned
+// as is, so they are not found, like unknown ones, without telling they are invalid.
+func normalizeEmail(parser emailaddr.Parser, email string) string {
+	address, err := parser.Parse(email)
+	if err != nil {
+		return email
+	}
+
+	return address.String()
+}
+
+// checkEmailDomain returns a ValidationError if the domain of the address breaks the policy.
+func checkEmailDomain(ctx context.Context, domainPolicy policy.EmailDomainPolicy, address emailaddr.Address) error {
+	violations, err := domainPolicy.Check(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+
+	return nil
+}
+
+// checkUsername returns a ValidationError if the username breaks the policy.
+func checkUsername(usernamePolicy policy.UsernamePolicy, username string) error {
+	if violations := usernamePolicy.Check(username); len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+
+	return nil
+}
+
+// checkPassword returns a ValidationError if the password breaks the policy.
+func checkPassword(ctx context.Context, passwordPolicy policy.PasswordPolicy, password string, email string, username string) error {
+	violations, err := passwordPolicy.Check(ctx, password, email, username)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+
+	return nil
+}
+
 type RegisterService interface {
-	Exec(ctx context.Context, email string, password string, username string) (*models.User, *models.TokenIntrospection, error)
+	Exec(ctx context.Context, email string, password string, username string, client models.ClientInfo) (*models.User, *models.Credentials, error)
 }
 
-func NewRegisterService(repository dao.UserRepository, generateToken GenerateTokenService) RegisterService {
+func NewRegisterService(
+	repository dao.UserRepository,
+	emailParser emailaddr.Parser,
+	domainPolicy policy.EmailDomainPolicy,
+	usernamePolicy policy.UsernamePolicy,
+	passwordPolicy policy.PasswordPolicy,
+	issueSession IssueSessionService,
+	sendVerificationEmail SendVerificationEmailService,
+	tasks TaskRunner,
+) RegisterService {
 	return &registerServiceImpl{
-		repository:    repository,
-		generateToken: generateToken,
+		repository:            repository,
+		emailParser:           emailParser,
+		domainPolicy:          domainPolicy,
+		usernamePolicy:        usernamePolicy,
+		passwordPolicy:        passwordPolicy,
+		issueSession:          issueSession,
+		sendVerificationEmail: sendVerificationEmail,
+		tasks:                 tasks,
 	}
 }
 
 type registerServiceImpl struct {
-	repository    dao.UserRepository
-	generateToken GenerateTokenService
+	repository            dao.UserRepository
+	emailParser           emailaddr.Parser
+	domainPolicy          policy.EmailDomainPolicy
+	usernamePolicy        policy.UsernamePolicy
+	passwordPolicy        policy.PasswordPolicy
+	issueSession          IssueSessionService
+	sendVerificationEmail SendVerificationEmailService
+	tasks                 TaskRunner
 }
 
-func (s *registerServiceImpl) Exec(ctx context.Context, email string, password string, username string) (*models.User, *models.TokenIntrospection, error) {
+func (s *registerServiceImpl) Exec(ctx context.Context, email string, password string, username string, client models.ClientInfo) (*models.User, *models.Credentials, error) {
 	if email == "" {
 		return nil, nil, errors.Join(ErrInvalidEntity, ErrMissingEmail)
 	}
@@ -44,15 +149,39 @@ func (s *registerServiceImpl) Exec(ctx context.Context, email string, password s
 		return nil, nil, errors.Join(ErrInvalidEntity, ErrMissingUsername)
 	}
 
-	user, err := s.repository.Create(ctx, email, password, username)
+	address, err := parseEmail(s.emailParser, email)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkEmailDomain(ctx, s.domainPolicy, address); err != nil {
+		return nil, nil, err
+	}
+
+	username = strings.TrimSpace(username)
+	if err := checkUsername(s.usernamePolicy, username); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkPassword(ctx, s.passwordPolicy, password, address.String(), username); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.repository.Create(ctx, address.String(), password, username)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	token, err := s.generateToken.GenerateToken(models.UserTokenPayload{ID: user.ID}, uuid.New(), time.Now())
+	// The account exists from now on, so a failed delivery must not fail the registration. The user can get a new
+	// link from the update email service.
+	s.tasks.Go("send_verification_email", func(ctx context.Context) error {
+		return s.sendVerificationEmail.Exec(ctx, user, user.Email)
+	})
+
+	credentials, err := s.issueSession.IssueSession(ctx, user.ID, client, time.Now())
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, credentials, nil
 }
@@ -0,0 +1,139 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// violationCodes returns the field and code of every violation of a ValidationError.
+func violationCodes(t *testing.T, err error) []string {
+	var validationErr *services.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+
+	output := make([]string, len(validationErr.Violations))
+	for i, violation := range validationErr.Violations {
+		output[i] = violation.Field + ":" + violation.Code
+	}
+
+	return output
+}
+
+func TestRegister(t *testing.T) {
+	domainPolicy, err := policy.NewEmailDomainPolicy(policy.EmailDomainOptions{}, nil)
+	require.NoError(t, err)
+	usernamePolicy, err := policy.NewUsernamePolicy(policy.UsernameOptions{MinLength: 3, Reserved: []string{"admin"}})
+	require.NoError(t, err)
+	passwordPolicy := policy.NewPasswordPolicy(policy.PasswordOptions{MinLength: 8}, nil)
+
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+
+	data := []struct {
+		name string
+
+		email    string
+		password string
+		username string
+
+		expectErr        error
+		expectViolations []string
+	}{
+		{
+			name:     "Success",
+			email:    "User@Example.com",
+			password: "correct horse",
+			username: "john",
+		},
+		{
+			name:             "PasswordTooShort",
+			email:            "user@example.com",
+			password:         "short",
+			username:         "john",
+			expectViolations: []string{"password:" + policy.CodeTooShort},
+		},
+		{
+			name:             "PasswordContainsUsername",
+			email:            "user@example.com",
+			password:         "john's password",
+			username:         "john",
+			expectViolations: []string{"password:" + policy.CodeContainsUserInfo},
+		},
+		{
+			name:             "InvalidEmail",
+			email:            "not an email",
+			password:         "correct horse",
+			username:         "john",
+			expectViolations: []string{"email:invalid"},
+		},
+		{
+			name:             "DisposableEmail",
+			email:            "user@mailinator.com",
+			password:         "correct horse",
+			username:         "john",
+			expectViolations: []string{"email:" + policy.CodeDomainDenied},
+		},
+		{
+			name:             "ReservedUsername",
+			email:            "user@example.com",
+			password:         "correct horse",
+			username:         "Admin",
+			expectViolations: []string{"username:" + policy.CodeReserved},
+		},
+		{
+			name:      "EmailTaken",
+			email:     "taken@example.com",
+			password:  "correct horse",
+			username:  "john",
+			expectErr: dao.ErrEmailTaken,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(newTestHasher(t), &models.User{ID: "user-0", Email: "taken@example.com", Username: "jane"})
+			mailer := new(mailerMock)
+
+			service := services.NewRegisterService(
+				users,
+				testEmailParser,
+				domainPolicy,
+				usernamePolicy,
+				passwordPolicy,
+				services.NewIssueSessionService(
+					newSessionRepositoryMock(), users, services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}), time.Hour,
+				),
+				services.NewSendVerificationEmailService(newActionTokenRepositoryMock(), mailer, "https://example.com/email/verify", time.Hour),
+				new(taskRunnerMock),
+			)
+
+			user, credentials, err := service.Exec(context.Background(), d.email, d.password, d.username, models.ClientInfo{})
+
+			if d.expectViolations != nil {
+				require.ErrorIs(t, err, services.ErrInvalidEntity)
+				require.Equal(t, d.expectViolations, violationCodes(t, err))
+				require.Len(t, users.users, 1)
+				return
+			}
+			if d.expectErr != nil {
+				require.ErrorIs(t, err, d.expectErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, "user@example.com", user.Email)
+			require.False(t, user.EmailVerified)
+			require.NotNil(t, credentials)
+
+			// The new address must be verified.
+			require.Len(t, mailer.sent, 1)
+			require.Equal(t, "user@example.com", mailer.sent[0].To)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/useragent"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// newRefreshToken generates an opaque refresh token for the session, and the hash to store server-side. The session
+// ID is kept in clear in the token, so the session can be retrieved without a lookup by hash.
+func newRefreshToken(sessionID string) (string, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	return fmt.Sprintf("%s.%s", sessionID, secret), hashSecret(secret), nil
+}
+
+// splitRefreshToken returns the session ID of a refresh token, and the hash of its secret.
+func splitRefreshToken(refreshToken string) (string, string, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	return sessionID, hashSecret(secret), nil
+}
+
+type IssueSessionService interface {
+	// IssueSession opens a new session for the user, and returns its first credentials. The client is recorded on the
+	// session, so the user can recognize it among their devices. Logging in cancels the deletion of the account, if
+	// one is scheduled, and fails with ErrUserDeleted once it is due.
+	IssueSession(ctx context.Context, userID string, client models.ClientInfo, now time.Time) (*models.Credentials, error)
+}
+
+func NewIssueSessionService(
+	repository dao.SessionRepository,
+	users dao.UserRepository,
+	generateToken GenerateTokenService,
+	refreshTokenTTL time.Duration,
+) IssueSessionService {
+	return &issueSessionServiceImpl{
+		repository:      repository,
+		users:           users,
+		generateToken:   generateToken,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+type issueSessionServiceImpl struct {
+	repository      dao.SessionRepository
+	users           dao.UserRepository
+	generateToken   GenerateTokenService
+	refreshTokenTTL time.Duration
+}
+
+func (s *issueSessionServiceImpl) IssueSession(ctx context.Context, userID string, client models.ClientInfo, now time.Time) (*models.Credentials, error) {
+	if err := cancelDeletion(ctx, s.users, userID, now); err != nil {
+		return nil, err
+	}
+
+	sessionID := uuid.New().String()
+
+	refreshToken, refreshTokenHash, err := newRefreshToken(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:               sessionID,
+		UserID:           userID,
+		RefreshTokenHash: refreshTokenHash,
+		CreatedAt:        now,
+		RefreshedAt:      now,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(s.refreshTokenTTL),
+		DeviceLabel:      useragent.Label(client.UserAgent),
+		UserAgent:        client.UserAgent,
+		IP:               client.IP,
+	}
+	if err := s.repository.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	token, err := s.generateToken.GenerateToken(models.UserTokenPayload{ID: userID, SessionID: sessionID}, uuid.New(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Credentials{
+		AccessToken:           token,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+type RefreshTokenService interface {
+	// Exec exchanges a refresh token for new credentials. The refresh token can no longer be used afterward.
+	Exec(ctx context.Context, refreshToken string) (*models.Credentials, error)
+}
+
+func NewRefreshTokenService(repository dao.SessionRepository, generateToken GenerateTokenService, refreshTokenTTL time.Duration) RefreshTokenService {
+	return &refreshTokenServiceImpl{
+		repository:      repository,
+		generateToken:   generateToken,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+type refreshTokenServiceImpl struct {
+	repository      dao.SessionRepository
+	generateToken   GenerateTokenService
+	refreshTokenTTL time.Duration
+}
+
+func (s *refreshTokenServiceImpl) Exec(ctx context.Context, refreshToken string) (*models.Credentials, error) {
+	now := time.Now()
+
+	sessionID, refreshTokenHash, err := splitRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	newRefreshToken, newRefreshTokenHash, err := newRefreshToken(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.repository.Rotate(ctx, sessionID, refreshTokenHash, newRefreshTokenHash, now, now.Add(s.refreshTokenTTL))
+	if err != nil {
+		if errors.Is(err, dao.ErrSessionNotFound) ||
+			errors.Is(err, dao.ErrSessionExpired) ||
+			errors.Is(err, dao.ErrSessionRevoked) ||
+			errors.Is(err, dao.ErrInvalidRefreshToken) ||
+			errors.Is(err, dao.ErrRefreshTokenReused) {
+			return nil, errors.Join(ErrInvalidRefreshToken, err)
+		}
+
+		return nil, err
+	}
+
+	token, err := s.generateToken.GenerateToken(models.UserTokenPayload{ID: session.UserID, SessionID: session.ID}, uuid.New(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Credentials{
+		AccessToken:           token,
+		RefreshToken:          newRefreshToken,
+		RefreshTokenExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+type ListSessionsService interface {
+	// Exec returns the active sessions of the principal, the most recently used first. The session the principal
+	// authenticated with is marked as current.
+	Exec(ctx context.Context, principal *models.Principal) ([]*models.Session, error)
+}
+
+func NewListSessionsService(repository dao.SessionRepository) ListSessionsService {
+	return &listSessionsServiceImpl{
+		repository: repository,
+	}
+}
+
+type listSessionsServiceImpl struct {
+	repository dao.SessionRepository
+}
+
+func (s *listSessionsServiceImpl) Exec(ctx context.Context, principal *models.Principal) ([]*models.Session, error) {
+	now := time.Now()
+
+	sessions, err := s.repository.ListByUser(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]*models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.RevokedAt != nil || !session.ExpiresAt.After(now) {
+			continue
+		}
+
+		session.Current = session.ID == principal.Token.Payload.SessionID
+		output = append(output, session)
+	}
+
+	return output, nil
+}
+
+type RevokeSessionService interface {
+	// Exec signs the principal out of one of their sessions. Tokens issued for the session are rejected right away,
+	// and its refresh token can no longer be used.
+	Exec(ctx context.Context, principal *models.Principal, id string) error
+}
+
+func NewRevokeSessionService(repository dao.SessionRepository) RevokeSessionService {
+	return &revokeSessionServiceImpl{
+		repository: repository,
+	}
+}
+
+type revokeSessionServiceImpl struct {
+	repository dao.SessionRepository
+}
+
+func (s *revokeSessionServiceImpl) Exec(ctx context.Context, principal *models.Principal, id string) error {
+	session, err := s.repository.GetSession(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Sessions of other users are reported as missing, so their IDs can't be probed.
+	if session.UserID != principal.UserID || session.RevokedAt != nil {
+		return dao.ErrSessionNotFound
+	}
+
+	return s.repository.Revoke(ctx, id, time.Now())
+}
@@ -0,0 +1,93 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	sessions := newSessionRepositoryMock()
+	users := newUserRepositoryMock(nil, &models.User{ID: "user-1"}, &models.User{ID: "user-2"})
+	issueSession := services.NewIssueSessionService(
+		sessions,
+		users,
+		services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}),
+		time.Hour,
+	)
+	getTokenStatus := services.NewGetTokenStatusService(keys, services.JWTOptions{}, newRevocationRepositoryMock(), sessions, time.Minute)
+	list := services.NewListSessionsService(sessions)
+	revoke := services.NewRevokeSessionService(sessions)
+
+	openedAt := time.Now()
+
+	laptop, err := issueSession.IssueSession(ctx, "user-1", models.ClientInfo{
+		UserAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		IP:        "10.0.0.1",
+	}, openedAt)
+	require.NoError(t, err)
+	phone, err := issueSession.IssueSession(ctx, "user-1", models.ClientInfo{
+		UserAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+		IP:        "10.0.0.2",
+	}, openedAt)
+	require.NoError(t, err)
+	_, err = issueSession.IssueSession(ctx, "user-2", models.ClientInfo{}, openedAt)
+	require.NoError(t, err)
+
+	laptopSessionID := laptop.AccessToken.Token.Payload.SessionID
+	phoneSessionID := phone.AccessToken.Token.Payload.SessionID
+
+	// Last use is only recorded once per interval.
+	_, err = getTokenStatus.GetTokenStatus(ctx, laptop.AccessToken.TokenRaw, openedAt.Add(30*time.Second))
+	require.NoError(t, err)
+	require.True(t, openedAt.Equal(sessions.sessions[laptopSessionID].LastSeenAt))
+
+	_, err = getTokenStatus.GetTokenStatus(ctx, laptop.AccessToken.TokenRaw, openedAt.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.True(t, openedAt.Add(2*time.Minute).Equal(sessions.sessions[laptopSessionID].LastSeenAt))
+
+	principal := &models.Principal{UserID: "user-1", Token: phone.AccessToken.Token}
+
+	res, err := list.Exec(ctx, principal)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, laptopSessionID, res[0].ID)
+	require.Equal(t, "Firefox on Linux", res[0].DeviceLabel)
+	require.Equal(t, "10.0.0.1", res[0].IP)
+	require.False(t, res[0].Current)
+	require.Equal(t, phoneSessionID, res[1].ID)
+	require.Equal(t, "Chrome on Android", res[1].DeviceLabel)
+	require.True(t, res[1].Current)
+
+	// Sessions can only be revoked by their owner.
+	err = revoke.Exec(ctx, &models.Principal{UserID: "user-2"}, laptopSessionID)
+	require.ErrorIs(t, err, dao.ErrSessionNotFound)
+	err = revoke.Exec(ctx, principal, "unknown")
+	require.ErrorIs(t, err, dao.ErrSessionNotFound)
+
+	require.NoError(t, revoke.Exec(ctx, principal, laptopSessionID))
+	require.ErrorIs(t, revoke.Exec(ctx, principal, laptopSessionID), dao.ErrSessionNotFound)
+
+	// Tokens of the revoked session are rejected right away.
+	status, err := getTokenStatus.GetTokenStatus(ctx, laptop.AccessToken.TokenRaw, time.Now())
+	require.NoError(t, err)
+	require.True(t, status.Revoked)
+
+	status, err = getTokenStatus.GetTokenStatus(ctx, phone.AccessToken.TokenRaw, time.Now())
+	require.NoError(t, err)
+	require.True(t, status.OK)
+
+	res, err = list.Exec(ctx, principal)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, phoneSessionID, res[0].ID)
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type TaskRunner interface {
+	// Go runs the task out of the request path, so its duration and failures can't be observed by the client. The
+	// task gets its own context, as the one of the request is cancelled once the response is sent. Failures are
+	// logged.
+	Go(name string, task func(ctx context.Context) error)
+}
+
+// NewTaskRunner creates a runner that starts every task in its own goroutine. Tasks are cancelled after timeout.
+func NewTaskRunner(logger zerolog.Logger, timeout time.Duration) TaskRunner {
+	return &taskRunnerImpl{
+		logger:  logger,
+		timeout: timeout,
+	}
+}
+
+type taskRunnerImpl struct {
+	logger  zerolog.Logger
+	timeout time.Duration
+}
+
+func (r *taskRunnerImpl) Go(name string, task func(ctx context.Context) error) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+		defer cancel()
+
+		if err := task(ctx); err != nil {
+			r.logger.Error().Err(err).Str("task", name).Msg("background task failed")
+		}
+	}()
+}
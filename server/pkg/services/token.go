@@ -1,12 +1,14 @@
 package services
 
 import (
+	"context"
 	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"technical-interview/pkg/dao"
 	"technical-interview/pkg/models"
 	"time"
 
@@ -17,40 +19,101 @@ var (
 	ErrEncodeTokenHeader  = errors.New("unable to encode token header")
 	ErrEncodeTokenPayload = errors.New("unable to encode token payload")
 	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidClaims      = errors.New("invalid token claims")
 	ErrBadSignature       = errors.New("bad signature")
+	ErrUnknownKey         = errors.New("unknown signing key")
+	ErrNoSigningKey       = errors.New("no signing key available")
 )
 
+// JWTOptions configures the RFC 7519 token format.
+type JWTOptions struct {
+	// Enabled makes GenerateTokenService emit RFC 7519 tokens. Introspection accepts both formats regardless.
+	Enabled bool
+	// Issuer is set as the iss claim. If not empty, introspected JWTs must have the same issuer.
+	Issuer string
+	// Audience is set as the aud claim. If not empty, introspected JWTs must include it in their audience.
+	Audience string
+}
+
 type GenerateTokenService interface {
 	// GenerateToken creates a new, valid token for the given user.
 	GenerateToken(data models.UserTokenPayload, id uuid.UUID, now time.Time) (*models.TokenIntrospection, error)
 }
 
-func NewGenerateTokenService(tokenTTL time.Duration) GenerateTokenService {
+func NewGenerateTokenService(tokenTTL time.Duration, keys *models.KeySet, jwt JWTOptions) GenerateTokenService {
 	return &generateTokenServiceImpl{
 		tokenTTL: tokenTTL,
+		keys:     keys,
+		jwt:      jwt,
 	}
 }
 
 type generateTokenServiceImpl struct {
 	tokenTTL time.Duration
+	keys     *models.KeySet
+	jwt      JWTOptions
+}
+
+// encodeLegacy returns the header and payload of the original token format, where the header holds the token
+// metadata as RFC3339 dates.
+func (s *generateTokenServiceImpl) encodeLegacy(source models.UserToken) (interface{}, interface{}) {
+	return source.Header, source.Payload
+}
+
+// encodeJWT returns the JOSE header and registered claims of an RFC 7519 token.
+func (s *generateTokenServiceImpl) encodeJWT(source models.UserToken) (interface{}, interface{}) {
+	header := models.JWTHeader{Alg: models.JWTAlgorithm, Typ: models.JWTType, Kid: source.Header.KID}
+
+	claims := models.JWTClaims{
+		Sub: source.Payload.ID,
+		Iat: source.Header.IAT.Unix(),
+		Exp: source.Header.EXP.Unix(),
+		Nbf: source.Header.IAT.Unix(),
+		Jti: source.Header.ID.String(),
+		Iss: source.Issuer,
+		Aud: source.Audience,
+		Sid: source.Payload.SessionID,
+	}
+
+	return header, claims
 }
 
 func (s *generateTokenServiceImpl) GenerateToken(data models.UserTokenPayload, id uuid.UUID, now time.Time) (*models.TokenIntrospection, error) {
+	key := s.keys.Active()
+	if key == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	// NumericDate has a precision of one second, so the dates are truncated for the decoded token to match.
+	if s.jwt.Enabled {
+		now = now.Truncate(time.Second)
+	}
+
 	// Create the content of the token.
 	source := models.UserToken{
-		Header:  models.UserTokenHeader{IAT: now, EXP: now.Add(s.tokenTTL), ID: id},
+		Header:  models.UserTokenHeader{IAT: now, EXP: now.Add(s.tokenTTL), ID: id, KID: key.ID},
 		Payload: data,
 	}
 
+	sourceHeader, sourcePayload := s.encodeLegacy(source)
+	if s.jwt.Enabled {
+		source.Issuer = s.jwt.Issuer
+		if s.jwt.Audience != "" {
+			source.Audience = models.Audience{s.jwt.Audience}
+		}
+
+		sourceHeader, sourcePayload = s.encodeJWT(source)
+	}
+
 	// Marshal token header into a base64 string.
-	mrshHeader, err := json.Marshal(source.Header)
+	mrshHeader, err := json.Marshal(sourceHeader)
 	if err != nil {
 		return nil, errors.Join(ErrEncodeTokenHeader, err)
 	}
 	header := base64.RawURLEncoding.EncodeToString(mrshHeader)
 
 	// Marshal token payload into a base64 string.
-	mrshPayload, err := json.Marshal(source.Payload)
+	mrshPayload, err := json.Marshal(sourcePayload)
 	if err != nil {
 		return nil, errors.Join(ErrEncodeTokenPayload, err)
 	}
@@ -59,7 +122,7 @@ func (s *generateTokenServiceImpl) GenerateToken(data models.UserTokenPayload, i
 	// Merge together header and payload strings to create the unsigned version of the token.
 	unsigned := fmt.Sprintf("%s.%s", header, payload)
 	// Generate a signature to prevent data tampering.
-	signature := base64.RawURLEncoding.EncodeToString(ed25519.Sign(models.JWTPrivateKey, []byte(unsigned)))
+	signature := base64.RawURLEncoding.EncodeToString(ed25519.Sign(key.PrivateKey, []byte(unsigned)))
 
 	return &models.TokenIntrospection{
 		OK:       true,
@@ -70,14 +133,68 @@ func (s *generateTokenServiceImpl) GenerateToken(data models.UserTokenPayload, i
 
 type GetTokenStatusService interface {
 	// GetTokenStatus introspects a token and returns the results.
-	GetTokenStatus(token string, now time.Time) (*models.TokenIntrospection, error)
+	GetTokenStatus(ctx context.Context, token string, now time.Time) (*models.TokenIntrospection, error)
+}
+
+// NewGetTokenStatusService creates a service to introspect tokens. Every valid token records the use of its session,
+// at most once per lastSeenInterval.
+func NewGetTokenStatusService(
+	keys *models.KeySet,
+	jwt JWTOptions,
+	revocations dao.RevocationRepository,
+	sessions dao.SessionRepository,
+	lastSeenInterval time.Duration,
+) GetTokenStatusService {
+	return &getTokenStatusServiceImpl{
+		keys:             keys,
+		jwt:              jwt,
+		revocations:      revocations,
+		sessions:         sessions,
+		lastSeenInterval: lastSeenInterval,
+	}
 }
 
-func NewGetTokenStatusService() GetTokenStatusService {
-	return &getTokenStatusServiceImpl{}
+type getTokenStatusServiceImpl struct {
+	keys             *models.KeySet
+	jwt              JWTOptions
+	revocations      dao.RevocationRepository
+	sessions         dao.SessionRepository
+	lastSeenInterval time.Duration
 }
 
-type getTokenStatusServiceImpl struct{}
+// isSessionRevoked returns true if the session the token was issued for has been revoked or deleted, which revokes
+// all of its tokens. Otherwise, the use of the session is recorded.
+func (s *getTokenStatusServiceImpl) isSessionRevoked(ctx context.Context, sessionID string, now time.Time) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+
+	session, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, dao.ErrSessionNotFound) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	if session.RevokedAt != nil {
+		return true, nil
+	}
+
+	if now.Sub(session.LastSeenAt) >= s.lastSeenInterval {
+		if err := s.sessions.Touch(ctx, sessionID, now); err != nil {
+			// The session was deleted since it was read.
+			if errors.Is(err, dao.ErrSessionNotFound) {
+				return true, nil
+			}
+
+			return false, err
+		}
+	}
+
+	return false, nil
+}
 
 func (s *getTokenStatusServiceImpl) splitToken(token string) (string, string, string, error) {
 	parts := strings.Split(token, ".")
@@ -111,13 +228,111 @@ func (s *getTokenStatusServiceImpl) decodeToken(header, payload, signature strin
 	return decodedHeader, decodedPayload, decodedSignature, nil
 }
 
-func (s *getTokenStatusServiceImpl) validateToken(header, payload string, decodedSignature []byte) error {
-	ok := ed25519.Verify(
-		// We know for sure the public type is correct, because we read it from the private key.
-		models.JWTPublicKey,
-		[]byte(fmt.Sprintf("%s.%s", header, payload)),
-		decodedSignature,
-	)
+// parseLegacyToken reads a token in the original format. The returned date is the one before which the token is not
+// valid yet.
+func (s *getTokenStatusServiceImpl) parseLegacyToken(header, payload []byte) (*models.UserToken, time.Time, error) {
+	parsedToken := new(models.UserToken)
+
+	if err := json.Unmarshal(header, &parsedToken.Header); err != nil {
+		return nil, time.Time{}, errors.Join(ErrInvalidToken, err)
+	}
+	if err := json.Unmarshal(payload, &parsedToken.Payload); err != nil {
+		return nil, time.Time{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	return parsedToken, parsedToken.Header.IAT, nil
+}
+
+// parseJWT reads an RFC 7519 token, and converts its registered claims into a UserToken. The returned date is the one
+// before which the token is not valid yet.
+func (s *getTokenStatusServiceImpl) parseJWT(header, payload []byte) (*models.UserToken, time.Time, error) {
+	jwtHeader := new(models.JWTHeader)
+	if err := json.Unmarshal(header, jwtHeader); err != nil {
+		return nil, time.Time{}, errors.Join(ErrInvalidToken, err)
+	}
+	// Only accept the algorithm we sign with, to prevent algorithm confusion attacks.
+	if jwtHeader.Alg != models.JWTAlgorithm {
+		return nil, time.Time{}, ErrInvalidToken
+	}
+	if jwtHeader.Typ != "" && !strings.EqualFold(jwtHeader.Typ, models.JWTType) {
+		return nil, time.Time{}, ErrInvalidToken
+	}
+
+	claims := new(models.JWTClaims)
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, time.Time{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	id, err := uuid.Parse(claims.Jti)
+	if err != nil {
+		return nil, time.Time{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	if s.jwt.Issuer != "" && claims.Iss != s.jwt.Issuer {
+		return nil, time.Time{}, ErrInvalidClaims
+	}
+	if s.jwt.Audience != "" && !claims.Aud.Contains(s.jwt.Audience) {
+		return nil, time.Time{}, ErrInvalidClaims
+	}
+
+	parsedToken := &models.UserToken{
+		Header: models.UserTokenHeader{
+			IAT: time.Unix(claims.Iat, 0),
+			EXP: time.Unix(claims.Exp, 0),
+			ID:  id,
+			KID: jwtHeader.Kid,
+		},
+		Payload:  models.UserTokenPayload{ID: claims.Sub, SessionID: claims.Sid},
+		Issuer:   claims.Iss,
+		Audience: claims.Aud,
+	}
+
+	notBefore := parsedToken.Header.IAT
+	if claims.Nbf > claims.Iat {
+		notBefore = time.Unix(claims.Nbf, 0)
+	}
+
+	return parsedToken, notBefore, nil
+}
+
+// parseToken reads the token in whichever format it was issued. Only RFC 7519 tokens declare an algorithm in their
+// header.
+func (s *getTokenStatusServiceImpl) parseToken(header, payload []byte) (*models.UserToken, time.Time, error) {
+	format := new(struct {
+		Alg string `json:"alg"`
+	})
+	if err := json.Unmarshal(header, format); err != nil {
+		return nil, time.Time{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	if format.Alg != "" {
+		return s.parseJWT(header, payload)
+	}
+
+	return s.parseLegacyToken(header, payload)
+}
+
+// readKeyID returns the ID of the key the token claims to be signed with. Both token formats store it under the kid
+// field of the header.
+func (s *getTokenStatusServiceImpl) readKeyID(header []byte) (string, error) {
+	keyID := new(struct {
+		Kid string `json:"kid"`
+	})
+	if err := json.Unmarshal(header, keyID); err != nil {
+		return "", errors.Join(ErrInvalidToken, err)
+	}
+
+	return keyID.Kid, nil
+}
+
+func (s *getTokenStatusServiceImpl) validateToken(kid, header, payload string, decodedSignature []byte) error {
+	// Any published key is accepted, so tokens signed before a rotation remain valid until they expire.
+	key := s.keys.Find(kid)
+	if key == nil {
+		return ErrUnknownKey
+	}
+
+	ok := ed25519.Verify(key.PublicKey, []byte(fmt.Sprintf("%s.%s", header, payload)), decodedSignature)
 
 	if !ok {
 		return ErrBadSignature
@@ -126,10 +341,11 @@ func (s *getTokenStatusServiceImpl) validateToken(header, payload string, decode
 	return nil
 }
 
-func (s *getTokenStatusServiceImpl) GetTokenStatus(token string, now time.Time) (*models.TokenIntrospection, error) {
+func (s *getTokenStatusServiceImpl) GetTokenStatus(ctx context.Context, token string, now time.Time) (*models.TokenIntrospection, error) {
 	status := &models.TokenIntrospection{TokenRaw: token}
 
 	if token == "" {
+		status.Reason = models.TokenReasonMissing
 		return status, nil
 	}
 
@@ -139,6 +355,7 @@ func (s *getTokenStatusServiceImpl) GetTokenStatus(token string, now time.Time)
 	if err != nil {
 		if errors.Is(err, ErrInvalidToken) {
 			status.Malformed = true
+			status.Reason = models.TokenReasonMalformed
 			return status, nil
 		}
 
@@ -149,49 +366,92 @@ func (s *getTokenStatusServiceImpl) GetTokenStatus(token string, now time.Time)
 	if err != nil {
 		if errors.Is(err, ErrInvalidToken) {
 			status.Malformed = true
+			status.Reason = models.TokenReasonMalformed
 			return status, nil
 		}
 
 		return nil, err
 	}
 
-	if err := s.validateToken(header, payload, decodedSignature); err != nil {
-		if errors.Is(err, ErrBadSignature) {
-			status.Expired = true
-		} else {
-			return nil, err
+	// Only the key ID is read before the signature is verified, so no unverified content is ever returned.
+	kid, err := s.readKeyID(decodedHeader)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			status.Malformed = true
+			status.Reason = models.TokenReasonMalformed
+			return status, nil
 		}
+
+		return nil, err
 	}
 
-	parsedToken := new(models.UserToken)
+	if err := s.validateToken(kid, header, payload, decodedSignature); err != nil {
+		if errors.Is(err, ErrUnknownKey) {
+			status.InvalidSignature = true
+			status.Reason = models.TokenReasonUnknownKey
+			return status, nil
+		}
+		if errors.Is(err, ErrBadSignature) {
+			status.InvalidSignature = true
+			status.Reason = models.TokenReasonInvalidSignature
+			return status, nil
+		}
 
-	if err := json.Unmarshal(decodedHeader, &parsedToken.Header); err != nil {
-		status.Malformed = true
-		return status, nil
-	}
-	if err := json.Unmarshal(decodedPayload, &parsedToken.Payload); err != nil {
-		status.Malformed = true
-		return status, nil
+		return nil, err
 	}
 
-	status.Token = parsedToken
-
-	if !status.Expired {
-		if parsedToken.Header.ID == uuid.Nil {
+	parsedToken, notBefore, err := s.parseToken(decodedHeader, decodedPayload)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
 			status.Malformed = true
+			status.Reason = models.TokenReasonMalformed
 			return status, nil
 		}
-		if parsedToken.Header.IAT.After(now) {
-			status.NotIssued = true
-			return status, nil
-		}
-		if parsedToken.Header.EXP.Before(now) {
-			status.Expired = true
+		if errors.Is(err, ErrInvalidClaims) {
+			status.Reason = models.TokenReasonInvalidClaims
 			return status, nil
 		}
 
-		status.OK = true
+		return nil, err
+	}
+
+	status.Token = parsedToken
+
+	if parsedToken.Header.ID == uuid.Nil {
+		status.Malformed = true
+		status.Reason = models.TokenReasonMalformed
+		return status, nil
 	}
+	if notBefore.After(now) {
+		status.NotIssued = true
+		status.Reason = models.TokenReasonNotIssued
+		return status, nil
+	}
+	if parsedToken.Header.EXP.Before(now) {
+		status.Expired = true
+		status.Reason = models.TokenReasonExpired
+		return status, nil
+	}
+
+	// Only valid tokens are looked up, so forged tokens never reach the database.
+	revoked, err := s.revocations.IsRevoked(ctx, parsedToken.Header.ID.String(), parsedToken.Payload.ID, parsedToken.Header.IAT)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		revoked, err = s.isSessionRevoked(ctx, parsedToken.Payload.SessionID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if revoked {
+		status.Revoked = true
+		status.Reason = models.TokenReasonRevoked
+		return status, nil
+	}
+
+	status.OK = true
+	status.Reason = models.TokenReasonValid
 
 	return status, nil
 }
@@ -0,0 +1,386 @@
+package services_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newSigningKey(t *testing.T, id string) models.SigningKey {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return models.SigningKey{ID: id, PublicKey: publicKey, PrivateKey: privateKey}
+}
+
+func TestTokenKeyRotation(t *testing.T) {
+	oldKey := newSigningKey(t, "old")
+	newKey := newSigningKey(t, "new")
+	now := time.Now()
+
+	oldSet := &models.KeySet{ActiveID: "old", Keys: []models.SigningKey{oldKey}}
+	// After rotation, the old key is only published for verification.
+	rotatedSet := &models.KeySet{
+		ActiveID: "new",
+		Keys:     []models.SigningKey{newKey, {ID: oldKey.ID, PublicKey: oldKey.PublicKey}},
+	}
+	// Once every token it signed has expired, the old key can be removed.
+	retiredSet := &models.KeySet{ActiveID: "new", Keys: []models.SigningKey{newKey}}
+
+	oldToken, err := services.NewGenerateTokenService(time.Hour, oldSet, services.JWTOptions{}).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+	require.Equal(t, "old", oldToken.Token.Header.KID)
+
+	newToken, err := services.NewGenerateTokenService(time.Hour, rotatedSet, services.JWTOptions{}).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+	require.Equal(t, "new", newToken.Token.Header.KID)
+
+	data := []struct {
+		name string
+
+		keys  *models.KeySet
+		token string
+
+		expectOK bool
+	}{
+		{
+			name:     "OldTokenBeforeRotation",
+			keys:     oldSet,
+			token:    oldToken.TokenRaw,
+			expectOK: true,
+		},
+		{
+			name:     "OldTokenAfterRotation",
+			keys:     rotatedSet,
+			token:    oldToken.TokenRaw,
+			expectOK: true,
+		},
+		{
+			name:     "NewTokenAfterRotation",
+			keys:     rotatedSet,
+			token:    newToken.TokenRaw,
+			expectOK: true,
+		},
+		{
+			name:  "OldTokenAfterRetirement",
+			keys:  retiredSet,
+			token: oldToken.TokenRaw,
+		},
+		{
+			name:  "UnknownKey",
+			keys:  oldSet,
+			token: newToken.TokenRaw,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			status, err := services.NewGetTokenStatusService(d.keys, services.JWTOptions{}, newRevocationRepositoryMock(), newSessionRepositoryMock(), time.Minute).
+				GetTokenStatus(context.Background(), d.token, now)
+			require.NoError(t, err)
+			require.Equal(t, d.expectOK, status.OK)
+		})
+	}
+}
+
+func TestGenerateTokenNoActiveKey(t *testing.T) {
+	key := newSigningKey(t, "public-only")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{{ID: key.ID, PublicKey: key.PublicKey}}}
+
+	_, err := services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), time.Now())
+	require.ErrorIs(t, err, services.ErrNoSigningKey)
+}
+
+func TestTokenFormats(t *testing.T) {
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	now := time.Now()
+
+	jwtOptions := services.JWTOptions{Enabled: true, Issuer: "https://api.example.com", Audience: "example"}
+
+	legacyToken, err := services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+
+	jwtToken, err := services.NewGenerateTokenService(time.Hour, keys, jwtOptions).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+
+	otherAudienceToken, err := services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{Enabled: true, Audience: "other"}).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+
+	data := []struct {
+		name string
+
+		token *models.TokenIntrospection
+
+		expectOK     bool
+		expectReason models.TokenStatusReason
+	}{
+		{
+			name:         "Legacy",
+			token:        legacyToken,
+			expectOK:     true,
+			expectReason: models.TokenReasonValid,
+		},
+		{
+			name:         "JWT",
+			token:        jwtToken,
+			expectOK:     true,
+			expectReason: models.TokenReasonValid,
+		},
+		{
+			name:         "JWTWrongAudience",
+			token:        otherAudienceToken,
+			expectReason: models.TokenReasonInvalidClaims,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			status, err := services.NewGetTokenStatusService(keys, jwtOptions, newRevocationRepositoryMock(), newSessionRepositoryMock(), time.Minute).
+				GetTokenStatus(context.Background(), d.token.TokenRaw, now)
+			require.NoError(t, err)
+			require.Equal(t, d.expectOK, status.OK)
+			require.Equal(t, d.expectReason, status.Reason)
+
+			if d.expectOK {
+				require.Equal(t, d.token.Token.Payload, status.Token.Payload)
+				require.Equal(t, d.token.Token.Header.ID, status.Token.Header.ID)
+				require.True(t, d.token.Token.Header.EXP.Equal(status.Token.Header.EXP))
+			}
+		})
+	}
+}
+
+func TestJWTEncoding(t *testing.T) {
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	id := uuid.New()
+	now := time.Unix(1700000000, 0)
+
+	token, err := services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{Enabled: true, Issuer: "issuer", Audience: "audience"}).
+		GenerateToken(models.UserTokenPayload{ID: "user-1"}, id, now)
+	require.NoError(t, err)
+
+	parts := strings.Split(token.TokenRaw, ".")
+	require.Len(t, parts, 3)
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"alg":"EdDSA","typ":"JWT","kid":"key"}`, string(header))
+
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.JSONEq(
+		t,
+		fmt.Sprintf(`{"sub":"user-1","iat":1700000000,"exp":1700003600,"nbf":1700000000,"jti":%q,"iss":"issuer","aud":"audience"}`, id),
+		string(claims),
+	)
+}
+
+func TestTokenRevocation(t *testing.T) {
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	now := time.Now()
+
+	generateToken := services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{})
+
+	revokedToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+	validToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+	oldToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-2"}, uuid.New(), now.Add(-time.Minute))
+	require.NoError(t, err)
+	newToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-2"}, uuid.New(), now)
+	require.NoError(t, err)
+
+	// Logging in again right after logging out of every device issues a token in the same second.
+	jwtToken, err := services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{Enabled: true}).
+		GenerateToken(models.UserTokenPayload{ID: "user-4"}, uuid.New(), now)
+	require.NoError(t, err)
+
+	revokedAt := now.Add(-time.Minute)
+	sessions := newSessionRepositoryMock(
+		&models.Session{ID: "session-active", UserID: "user-3"},
+		&models.Session{ID: "session-revoked", UserID: "user-3", RevokedAt: &revokedAt},
+	)
+
+	activeSessionToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-3", SessionID: "session-active"}, uuid.New(), now)
+	require.NoError(t, err)
+	revokedSessionToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-3", SessionID: "session-revoked"}, uuid.New(), now)
+	require.NoError(t, err)
+	deletedSessionToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-3", SessionID: "session-deleted"}, uuid.New(), now)
+	require.NoError(t, err)
+
+	revocations := newRevocationRepositoryMock()
+	require.NoError(t, revocations.RevokeToken(context.Background(), revokedToken.Token.Header.ID.String(), "user-1", now.Add(time.Hour)))
+	require.NoError(t, revocations.RevokeUserTokens(context.Background(), "user-2", now.Add(-time.Second), now.Add(time.Hour)))
+	require.NoError(t, revocations.RevokeUserTokens(context.Background(), "user-4", now, now.Add(time.Hour)))
+
+	data := []struct {
+		name string
+
+		token string
+
+		expectRevoked bool
+	}{
+		{
+			name:          "RevokedToken",
+			token:         revokedToken.TokenRaw,
+			expectRevoked: true,
+		},
+		{
+			name:  "ValidToken",
+			token: validToken.TokenRaw,
+		},
+		{
+			name:          "TokenIssuedBeforeUserRevocation",
+			token:         oldToken.TokenRaw,
+			expectRevoked: true,
+		},
+		{
+			name:  "TokenIssuedAfterUserRevocation",
+			token: newToken.TokenRaw,
+		},
+		{
+			name:  "TokenIssuedInUserRevocationSecond",
+			token: jwtToken.TokenRaw,
+		},
+		{
+			name:  "ActiveSession",
+			token: activeSessionToken.TokenRaw,
+		},
+		{
+			name:          "RevokedSession",
+			token:         revokedSessionToken.TokenRaw,
+			expectRevoked: true,
+		},
+		{
+			name:          "DeletedSession",
+			token:         deletedSessionToken.TokenRaw,
+			expectRevoked: true,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			status, err := services.NewGetTokenStatusService(keys, services.JWTOptions{}, revocations, sessions, time.Minute).
+				GetTokenStatus(context.Background(), d.token, now)
+			require.NoError(t, err)
+			require.Equal(t, d.expectRevoked, status.Revoked)
+			require.Equal(t, !d.expectRevoked, status.OK)
+		})
+	}
+}
+
+func TestTokenStatusReasons(t *testing.T) {
+	key := newSigningKey(t, "key")
+	otherKey := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	now := time.Now()
+
+	generateToken := services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{})
+
+	validToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now)
+	require.NoError(t, err)
+	expiredToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now.Add(-2*time.Hour))
+	require.NoError(t, err)
+	notIssuedToken, err := generateToken.GenerateToken(models.UserTokenPayload{ID: "user-1"}, uuid.New(), now.Add(time.Hour))
+	require.NoError(t, err)
+	// Same key ID, but signed by a key we don't own.
+	forgedToken, err := services.NewGenerateTokenService(time.Hour, &models.KeySet{ActiveID: otherKey.ID, Keys: []models.SigningKey{otherKey}}, services.JWTOptions{}).
+		GenerateToken(models.UserTokenPayload{ID: "admin"}, uuid.New(), now)
+	require.NoError(t, err)
+	unknownKeyToken, err := services.NewGenerateTokenService(time.Hour, &models.KeySet{ActiveID: "other", Keys: []models.SigningKey{newSigningKey(t, "other")}}, services.JWTOptions{}).
+		GenerateToken(models.UserTokenPayload{ID: "admin"}, uuid.New(), now)
+	require.NoError(t, err)
+
+	// Replace the payload of a valid token.
+	validParts := strings.Split(validToken.TokenRaw, ".")
+	tamperedToken := strings.Join([]string{
+		validParts[0],
+		base64.RawURLEncoding.EncodeToString([]byte(`{"id":"admin"}`)),
+		validParts[2],
+	}, ".")
+
+	data := []struct {
+		name string
+
+		token string
+
+		expectReason models.TokenStatusReason
+	}{
+		{
+			name:         "Valid",
+			token:        validToken.TokenRaw,
+			expectReason: models.TokenReasonValid,
+		},
+		{
+			name:         "Missing",
+			token:        "",
+			expectReason: models.TokenReasonMissing,
+		},
+		{
+			name:         "Malformed",
+			token:        "not-a-token",
+			expectReason: models.TokenReasonMalformed,
+		},
+		{
+			name:         "Expired",
+			token:        expiredToken.TokenRaw,
+			expectReason: models.TokenReasonExpired,
+		},
+		{
+			name:         "NotIssued",
+			token:        notIssuedToken.TokenRaw,
+			expectReason: models.TokenReasonNotIssued,
+		},
+		{
+			name:         "Forged",
+			token:        forgedToken.TokenRaw,
+			expectReason: models.TokenReasonInvalidSignature,
+		},
+		{
+			name:         "Tampered",
+			token:        tamperedToken,
+			expectReason: models.TokenReasonInvalidSignature,
+		},
+		{
+			name:         "UnknownKey",
+			token:        unknownKeyToken.TokenRaw,
+			expectReason: models.TokenReasonUnknownKey,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			status, err := services.NewGetTokenStatusService(keys, services.JWTOptions{}, newRevocationRepositoryMock(), newSessionRepositoryMock(), time.Minute).
+				GetTokenStatus(context.Background(), d.token, now)
+			require.NoError(t, err)
+			require.Equal(t, d.expectReason, status.Reason)
+			require.Equal(t, d.expectReason == models.TokenReasonValid, status.OK)
+
+			// Unverified content must never be returned.
+			if d.expectReason == models.TokenReasonInvalidSignature || d.expectReason == models.TokenReasonUnknownKey {
+				require.True(t, status.InvalidSignature)
+				require.False(t, status.Expired)
+				require.Nil(t, status.Token)
+			}
+		})
+	}
+}
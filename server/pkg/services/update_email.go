@@ -3,8 +3,12 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"technical-interview/pkg/dao"
-	"time"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/mail"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
 )
 
 var (
@@ -12,33 +16,94 @@ var (
 )
 
 type UpdateEmailService interface {
-	UpdateEmail(ctx context.Context, tokenRaw string, email string) error
+	// UpdateEmail stores the new address as pending, and sends it a verification link. The address only replaces
+	// the current one once verified. The current address is notified of the change. Giving the current address while
+	// it is not verified sends it a new verification link. Links are sent with tasks, so a failed delivery doesn't fail
+	// the request.
+	UpdateEmail(ctx context.Context, principal *models.Principal, email string) error
 }
 
-func NewUpdateEmailService(repository dao.UserRepository, getTokenStatus GetTokenStatusService) UpdateEmailService {
+func NewUpdateEmailService(
+	repository dao.UserRepository,
+	emailParser emailaddr.Parser,
+	domainPolicy policy.EmailDomainPolicy,
+	sendVerificationEmail SendVerificationEmailService,
+	mailer mail.Mailer,
+	tasks TaskRunner,
+) UpdateEmailService {
 	return &updateEmailServiceImpl{
-		getTokenStatus: getTokenStatus,
-		repository:     repository,
+		repository:            repository,
+		emailParser:           emailParser,
+		domainPolicy:          domainPolicy,
+		sendVerificationEmail: sendVerificationEmail,
+		mailer:                mailer,
+		tasks:                 tasks,
 	}
 }
 
 type updateEmailServiceImpl struct {
-	getTokenStatus GetTokenStatusService
-	repository     dao.UserRepository
+	repository            dao.UserRepository
+	emailParser           emailaddr.Parser
+	domainPolicy          policy.EmailDomainPolicy
+	sendVerificationEmail SendVerificationEmailService
+	mailer                mail.Mailer
+	tasks                 TaskRunner
 }
 
-func (s *updateEmailServiceImpl) UpdateEmail(ctx context.Context, tokenRaw string, email string) error {
-	tokenStatus, err := s.getTokenStatus.GetTokenStatus(tokenRaw, time.Now())
+func (s *updateEmailServiceImpl) UpdateEmail(ctx context.Context, principal *models.Principal, email string) error {
+	address, err := parseEmail(s.emailParser, email)
 	if err != nil {
 		return err
 	}
-	if !tokenStatus.OK {
-		return ErrInvalidCredentials
+	email = address.String()
+
+	user, err := s.repository.GetUser(ctx, principal.UserID)
+	if err != nil {
+		return err
 	}
 
-	if err := s.repository.UpdateEmail(ctx, tokenStatus.Token.Payload.ID, email); err != nil {
+	if email == user.Email {
+		if !user.EmailVerified {
+			s.tasks.Go("send_verification_email", func(ctx context.Context) error {
+				return s.sendVerificationEmail.Exec(ctx, user, email)
+			})
+		}
+
+		return nil
+	}
+
+	if err := checkEmailDomain(ctx, s.domainPolicy, address); err != nil {
 		return err
 	}
 
+	if err := s.repository.SetPendingEmail(ctx, user.ID, email); err != nil {
+		return err
+	}
+
+	// The pending email is stored from now on, so a failed delivery must not fail the request. The user can get a new
+	// link by requesting the change again.
+	s.tasks.Go("send_verification_email", func(ctx context.Context) error {
+		return s.sendVerificationEmail.Exec(ctx, user, email)
+	})
+
+	// Warn the owner of the current address, in case the account was compromised.
+	s.tasks.Go("send_email_change_notice", func(ctx context.Context) error {
+		return s.warnEmailChange(ctx, user, email)
+	})
+
 	return nil
 }
+
+// warnEmailChange tells the user at their current address that a change to email was requested.
+func (s *updateEmailServiceImpl) warnEmailChange(ctx context.Context, user *models.User, email string) error {
+	return s.mailer.Send(ctx, &models.Mail{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nA request was made to change the email address of your account to %s. The change will "+
+				"be applied once the new address is verified.\n\nIf you did not make this request, reset your password "+
+				"immediately.\n",
+			user.Username, email,
+		),
+	})
+}
@@ -0,0 +1,85 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateEmail(t *testing.T) {
+	domainPolicy, err := policy.NewEmailDomainPolicy(policy.EmailDomainOptions{}, nil)
+	require.NoError(t, err)
+
+	data := []struct {
+		name string
+
+		user    *models.User
+		email   string
+		mailErr error
+
+		expectPendingEmail string
+		expectSentTo       []string
+		expectFailures     int
+	}{
+		{
+			name:               "Success",
+			user:               &models.User{ID: "user-1", Email: "user@example.com", EmailVerified: true},
+			email:              "new@example.com",
+			expectPendingEmail: "new@example.com",
+			expectSentTo:       []string{"new@example.com", "user@example.com"},
+		},
+		{
+			name:         "ResendVerification",
+			user:         &models.User{ID: "user-1", Email: "user@example.com"},
+			email:        "user@example.com",
+			expectSentTo: []string{"user@example.com"},
+		},
+		{
+			name:  "AlreadyVerified",
+			user:  &models.User{ID: "user-1", Email: "user@example.com", EmailVerified: true},
+			email: "user@example.com",
+		},
+		{
+			// The pending email is stored, so the request succeeds even if the links can't be delivered.
+			name:               "MailFailure",
+			user:               &models.User{ID: "user-1", Email: "user@example.com", EmailVerified: true},
+			email:              "new@example.com",
+			mailErr:            errors.New("smtp unavailable"),
+			expectPendingEmail: "new@example.com",
+			expectFailures:     2,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(nil, d.user)
+			mailer := &mailerMock{err: d.mailErr}
+			tasks := new(taskRunnerMock)
+
+			service := services.NewUpdateEmailService(
+				users,
+				testEmailParser,
+				domainPolicy,
+				services.NewSendVerificationEmailService(newActionTokenRepositoryMock(), mailer, "https://example.com/email/verify", time.Hour),
+				mailer,
+				tasks,
+			)
+
+			require.NoError(t, service.UpdateEmail(context.Background(), &models.Principal{UserID: "user-1"}, d.email))
+			require.Equal(t, d.expectPendingEmail, users.users["user-1"].PendingEmail)
+			require.Len(t, tasks.failures, d.expectFailures)
+
+			var sentTo []string
+			for _, mail := range mailer.sent {
+				sentTo = append(sentTo, mail.To)
+			}
+			require.Equal(t, d.expectSentTo, sentTo)
+		})
+	}
+}
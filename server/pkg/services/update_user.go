@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"time"
+	// Time zones are validated against the embedded database, so they don't depend on the host.
+	_ "time/tzdata"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+	"golang.org/x/text/language"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxAvatarURLLength   = 2048
+)
+
+// profileField is a field of the user that can be changed with a patch.
+type profileField struct {
+	// normalize validates a new value, and returns it in canonical form, or the reason it is rejected. Null values
+	// are given as an empty string.
+	normalize func(usernamePolicy policy.UsernamePolicy, value string) (string, *models.Violation)
+	set       func(user *models.User, value string)
+}
+
+// violation is a shorthand for a violation with a single code.
+func violation(code string, message string) *models.Violation {
+	return &models.Violation{Code: code, Message: message}
+}
+
+// profileFields maps the JSON names of the editable fields to their handling.
+var profileFields = map[string]profileField{
+	"username": {
+		normalize: func(usernamePolicy policy.UsernamePolicy, value string) (string, *models.Violation) {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return "", violation("required", "username can't be removed")
+			}
+			if violations := usernamePolicy.Check(value); len(violations) > 0 {
+				return "", &violations[0]
+			}
+
+			return value, nil
+		},
+		set: func(user *models.User, value string) { user.Username = value },
+	},
+	"displayName": {
+		normalize: func(_ policy.UsernamePolicy, value string) (string, *models.Violation) {
+			value = strings.TrimSpace(value)
+			if utf8.RuneCountInString(value) > maxDisplayNameLength {
+				return "", violation(policy.CodeTooLong, fmt.Sprintf("display name must be at most %d characters long", maxDisplayNameLength))
+			}
+			if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+				return "", violation(policy.CodeInvalidCharacters, "display name must not contain control characters")
+			}
+
+			return value, nil
+		},
+		set: func(user *models.User, value string) { user.DisplayName = value },
+	},
+	"locale": {
+		normalize: func(_ policy.UsernamePolicy, value string) (string, *models.Violation) {
+			if value == "" {
+				return "", nil
+			}
+
+			tag, err := language.Parse(value)
+			if err != nil {
+				return "", violation(codeInvalid, "locale must be a BCP 47 language tag, like en-US")
+			}
+
+			return tag.String(), nil
+		},
+		set: func(user *models.User, value string) { user.Locale = value },
+	},
+	"timezone": {
+		normalize: func(_ policy.UsernamePolicy, value string) (string, *models.Violation) {
+			if value == "" {
+				return "", nil
+			}
+
+			// Local is the zone of the server, not a zone name.
+			if _, err := time.LoadLocation(value); err != nil || value == "Local" {
+				return "", violation(codeInvalid, "timezone must be an IANA time zone name, like Europe/Paris")
+			}
+
+			return value, nil
+		},
+		set: func(user *models.User, value string) { user.Timezone = value },
+	},
+	"avatarURL": {
+		normalize: func(_ policy.UsernamePolicy, value string) (string, *models.Violation) {
+			if value == "" {
+				return "", nil
+			}
+			if len(value) > maxAvatarURLLength {
+				return "", violation(policy.CodeTooLong, fmt.Sprintf("avatar URL must be at most %d bytes long", maxAvatarURLLength))
+			}
+
+			// Avatars are loaded by other users, so they must not be served over plain HTTP.
+			avatarURL, err := url.Parse(value)
+			if err != nil || avatarURL.Scheme != "https" || avatarURL.Host == "" {
+				return "", violation(codeInvalid, "avatar URL must be an absolute https URL")
+			}
+
+			return avatarURL.String(), nil
+		},
+		set: func(user *models.User, value string) { user.AvatarURL = value },
+	},
+}
+
+type UpdateUserService interface {
+	// Exec applies a JSON Merge Patch (RFC 7396) to the profile of the user, and returns the updated user. Fields set
+	// to null are cleared. If ifUpdatedAt is not zero, it returns dao.ErrUserModified when the user was updated since.
+	Exec(ctx context.Context, principal *models.Principal, patch map[string]json.RawMessage, ifUpdatedAt time.Time) (*models.User, error)
+}
+
+func NewUpdateUserService(repository dao.UserRepository, usernamePolicy policy.UsernamePolicy) UpdateUserService {
+	return &updateUserServiceImpl{
+		repository:     repository,
+		usernamePolicy: usernamePolicy,
+	}
+}
+
+type updateUserServiceImpl struct {
+	repository     dao.UserRepository
+	usernamePolicy policy.UsernamePolicy
+}
+
+// parsePatch validates the patch, and returns the values to set, by field.
+func (s *updateUserServiceImpl) parsePatch(patch map[string]json.RawMessage) (map[string]string, error) {
+	output := make(map[string]string, len(patch))
+	var violations []models.Violation
+
+	// Sorted, so violations are always listed in the same order.
+	names := lo.Keys(patch)
+	slices.Sort(names)
+	for _, name := range names {
+		field, ok := profileFields[name]
+		if !ok {
+			violations = append(violations, models.Violation{Field: name, Code: "not_editable", Message: "field can't be changed"})
+			continue
+		}
+
+		var value *string
+		if err := json.Unmarshal(patch[name], &value); err != nil {
+			violations = append(violations, models.Violation{Field: name, Code: codeInvalid, Message: "value must be a string or null"})
+			continue
+		}
+
+		normalized, fieldViolation := field.normalize(s.usernamePolicy, lo.FromPtr(value))
+		if fieldViolation != nil {
+			fieldViolation.Field = name
+			violations = append(violations, *fieldViolation)
+			continue
+		}
+
+		output[name] = normalized
+	}
+
+	if len(violations) > 0 {
+		return nil, &ValidationError{Violations: violations}
+	}
+
+	return output, nil
+}
+
+func (s *updateUserServiceImpl) Exec(ctx context.Context, principal *models.Principal, patch map[string]json.RawMessage, ifUpdatedAt time.Time) (*models.User, error) {
+	values, err := s.parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repository.Update(ctx, principal.UserID, ifUpdatedAt, func(user *models.User) error {
+		for name, value := range values {
+			profileFields[name].set(user, value)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,120 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/policy"
+	"technical-interview/pkg/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUser(t *testing.T) {
+	usernamePolicy, err := policy.NewUsernamePolicy(policy.UsernameOptions{MinLength: 3, MaxLength: 20, Charset: "a-z0-9_.-"})
+	require.NoError(t, err)
+
+	version := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		name string
+
+		patch       string
+		ifUpdatedAt time.Time
+
+		expect      *models.User
+		expectCodes []string
+		expectErr   error
+	}{
+		{
+			name:  "Success",
+			patch: `{"username": "Johnny", "displayName": " John Doe ", "locale": "en-us", "timezone": "Europe/Paris", "avatarURL": "https://cdn.example.com/a.png"}`,
+			expect: &models.User{
+				Username:    "Johnny",
+				DisplayName: "John Doe",
+				Locale:      "en-US",
+				Timezone:    "Europe/Paris",
+				AvatarURL:   "https://cdn.example.com/a.png",
+			},
+			ifUpdatedAt: version,
+		},
+		{
+			name:   "ClearFields",
+			patch:  `{"locale": null, "displayName": ""}`,
+			expect: &models.User{Username: "john", Timezone: "UTC"},
+		},
+		{
+			name:   "EmptyPatch",
+			patch:  `{}`,
+			expect: &models.User{Username: "john", DisplayName: "John", Locale: "fr-FR", Timezone: "UTC"},
+		},
+		{
+			name:        "Modified",
+			patch:       `{"displayName": "Jack"}`,
+			ifUpdatedAt: version.Add(-time.Second),
+			expectErr:   dao.ErrUserModified,
+		},
+		{
+			name:      "UsernameTaken",
+			patch:     `{"username": "JANE"}`,
+			expectErr: dao.ErrUsernameTaken,
+		},
+		{
+			name:  "InvalidValues",
+			patch: `{"username": null, "locale": "not a locale", "timezone": "Mars/Olympus", "avatarURL": "http://example.com/a.png", "displayName": 42}`,
+			expectCodes: []string{
+				"invalid",
+				"invalid",
+				"invalid",
+				"invalid",
+				"required",
+			},
+			expectErr: services.ErrInvalidEntity,
+		},
+		{
+			name:        "ReadOnlyField",
+			patch:       `{"email": "other@example.com"}`,
+			expectCodes: []string{"not_editable"},
+			expectErr:   services.ErrInvalidEntity,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			users := newUserRepositoryMock(
+				newTestHasher(t),
+				&models.User{ID: "user-1", Username: "john", DisplayName: "John", Locale: "fr-FR", Timezone: "UTC", UpdatedAt: version},
+				&models.User{ID: "user-2", Username: "jane", UpdatedAt: version},
+			)
+			service := services.NewUpdateUserService(users, usernamePolicy)
+
+			patch := make(map[string]json.RawMessage)
+			require.NoError(t, json.Unmarshal([]byte(d.patch), &patch))
+
+			user, err := service.Exec(context.Background(), &models.Principal{UserID: "user-1"}, patch, d.ifUpdatedAt)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if d.expectCodes != nil {
+				var validationErr *services.ValidationError
+				require.ErrorAs(t, err, &validationErr)
+
+				codes := make([]string, len(validationErr.Violations))
+				for i, violation := range validationErr.Violations {
+					codes[i] = violation.Code
+				}
+				require.Equal(t, d.expectCodes, codes)
+			}
+
+			if err == nil {
+				require.Equal(t, d.expect.Username, user.Username)
+				require.Equal(t, d.expect.DisplayName, user.DisplayName)
+				require.Equal(t, d.expect.Locale, user.Locale)
+				require.Equal(t, d.expect.Timezone, user.Timezone)
+				require.Equal(t, d.expect.AvatarURL, user.AvatarURL)
+			}
+		})
+	}
+}
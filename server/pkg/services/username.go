@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/policy"
+)
+
+type UsernameAvailableService interface {
+	// Exec returns whether the username can be registered, for sign-up forms. Usernames breaking the policy return a
+	// ValidationError.
+	Exec(ctx context.Context, username string) (bool, error)
+}
+
+func NewUsernameAvailableService(repository dao.UserRepository, usernamePolicy policy.UsernamePolicy) UsernameAvailableService {
+	return &usernameAvailableServiceImpl{
+		repository:     repository,
+		usernamePolicy: usernamePolicy,
+	}
+}
+
+type usernameAvailableServiceImpl struct {
+	repository     dao.UserRepository
+	usernamePolicy policy.UsernamePolicy
+}
+
+func (s *usernameAvailableServiceImpl) Exec(ctx context.Context, username string) (bool, error) {
+	if err := checkUsername(s.usernamePolicy, username); err != nil {
+		return false, err
+	}
+
+	_, err := s.repository.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, dao.ErrUserNotFound) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	return false, nil
+}
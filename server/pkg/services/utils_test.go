@@ -0,0 +1,579 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/emailaddr"
+	"technical-interview/pkg/hasher"
+	"technical-interview/pkg/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testEmailParser = emailaddr.NewParser(emailaddr.Options{
+	Rules: []emailaddr.Rule{
+		{Domains: []string{"gmail.com"}, IgnoreDots: true, TagSeparators: "+"},
+	},
+})
+
+// hashToken returns the hash action tokens are stored under, to create them without sending a link first.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+type revocationRepositoryMock struct {
+	tokens map[string]bool
+	users  map[string]time.Time
+}
+
+func newRevocationRepositoryMock() *revocationRepositoryMock {
+	return &revocationRepositoryMock{tokens: map[string]bool{}, users: map[string]time.Time{}}
+}
+
+func (mock *revocationRepositoryMock) RevokeToken(_ context.Context, id string, _ string, _ time.Time) error {
+	mock.tokens[id] = true
+	return nil
+}
+
+func (mock *revocationRepositoryMock) RevokeUserTokens(_ context.Context, userID string, before time.Time, _ time.Time) error {
+	mock.users[userID] = before.Truncate(time.Second)
+	return nil
+}
+
+func (mock *revocationRepositoryMock) DeleteByUser(_ context.Context, userID string) error {
+	delete(mock.users, userID)
+	return nil
+}
+
+func (mock *revocationRepositoryMock) IsRevoked(_ context.Context, id string, userID string, issuedAt time.Time) (bool, error) {
+	before, ok := mock.users[userID]
+	return mock.tokens[id] || (ok && issuedAt.Before(before)), nil
+}
+
+type sessionRepositoryMock struct {
+	sessions map[string]*models.Session
+}
+
+func newSessionRepositoryMock(sessions ...*models.Session) *sessionRepositoryMock {
+	mock := &sessionRepositoryMock{sessions: map[string]*models.Session{}}
+	for _, session := range sessions {
+		mock.sessions[session.ID] = session
+	}
+
+	return mock
+}
+
+func (mock *sessionRepositoryMock) Create(_ context.Context, session *models.Session) error {
+	mock.sessions[session.ID] = session
+	return nil
+}
+
+func (mock *sessionRepositoryMock) GetSession(_ context.Context, id string) (*models.Session, error) {
+	session, ok := mock.sessions[id]
+	if !ok {
+		return nil, dao.ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+func (mock *sessionRepositoryMock) ListByUser(_ context.Context, userID string) ([]*models.Session, error) {
+	var output []*models.Session
+	for _, session := range mock.sessions {
+		if session.UserID == userID {
+			output = append(output, session)
+		}
+	}
+
+	slices.SortFunc(output, func(a, b *models.Session) int {
+		return b.LastSeenAt.Compare(a.LastSeenAt)
+	})
+
+	return output, nil
+}
+
+func (mock *sessionRepositoryMock) Touch(_ context.Context, id string, now time.Time) error {
+	session, ok := mock.sessions[id]
+	if !ok {
+		return dao.ErrSessionNotFound
+	}
+
+	session.LastSeenAt = now
+	return nil
+}
+
+func (mock *sessionRepositoryMock) Rotate(_ context.Context, id string, refreshTokenHash string, newRefreshTokenHash string, now time.Time, expiresAt time.Time) (*models.Session, error) {
+	session, ok := mock.sessions[id]
+	if !ok {
+		return nil, dao.ErrSessionNotFound
+	}
+	if session.RefreshTokenHash != refreshTokenHash {
+		return nil, dao.ErrInvalidRefreshToken
+	}
+
+	session.RetiredTokenHashes = append(session.RetiredTokenHashes, refreshTokenHash)
+	session.RefreshTokenHash = newRefreshTokenHash
+	session.RefreshedAt = now
+	session.LastSeenAt = now
+	session.ExpiresAt = expiresAt
+
+	return session, nil
+}
+
+func (mock *sessionRepositoryMock) Revoke(_ context.Context, id string, now time.Time) error {
+	session, ok := mock.sessions[id]
+	if !ok {
+		return dao.ErrSessionNotFound
+	}
+
+	session.RevokedAt = &now
+	return nil
+}
+
+func (mock *sessionRepositoryMock) RevokeUserSessions(_ context.Context, userID string, exceptID string, now time.Time) error {
+	for _, session := range mock.sessions {
+		if session.UserID == userID && session.ID != exceptID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (mock *sessionRepositoryMock) DeleteByUser(_ context.Context, userID string) error {
+	for id, session := range mock.sessions {
+		if session.UserID == userID {
+			delete(mock.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+type userRepositoryMock struct {
+	users  map[string]*models.User
+	hasher hasher.PasswordHasher
+}
+
+func newUserRepositoryMock(passwordHasher hasher.PasswordHasher, users ...*models.User) *userRepositoryMock {
+	mock := &userRepositoryMock{users: map[string]*models.User{}, hasher: passwordHasher}
+	for _, user := range users {
+		mock.users[user.ID] = user
+	}
+
+	return mock
+}
+
+func (mock *userRepositoryMock) Create(_ context.Context, email string, password string, username string) (*models.User, error) {
+	if _, err := mock.GetUserByEmail(context.Background(), email); err == nil {
+		return nil, dao.ErrEmailTaken
+	}
+	if _, err := mock.GetUserByUsername(context.Background(), username); err == nil {
+		return nil, dao.ErrUsernameTaken
+	}
+
+	passwordHashed, err := mock.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{ID: uuid.NewString(), Email: email, Username: username, Password: passwordHashed}
+	mock.users[user.ID] = user
+
+	return user, nil
+}
+
+func (mock *userRepositoryMock) GetUser(_ context.Context, id string) (*models.User, error) {
+	user, ok := mock.users[id]
+	if !ok {
+		return nil, dao.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (mock *userRepositoryMock) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
+	for _, user := range mock.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return nil, dao.ErrUserNotFound
+}
+
+func (mock *userRepositoryMock) GetUserByUsername(_ context.Context, username string) (*models.User, error) {
+	for _, user := range mock.users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+
+	return nil, dao.ErrUserNotFound
+}
+
+func (mock *userRepositoryMock) Update(ctx context.Context, id string, ifUpdatedAt time.Time, fn func(user *models.User) error) (*models.User, error) {
+	user, err := mock.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ifUpdatedAt.IsZero() && !user.UpdatedAt.Equal(ifUpdatedAt) {
+		return nil, dao.ErrUserModified
+	}
+
+	updated := *user
+	if err := fn(&updated); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(updated.Username, user.Username) {
+		if _, err := mock.GetUserByUsername(ctx, updated.Username); err == nil {
+			return nil, dao.ErrUsernameTaken
+		}
+	}
+
+	updated.UpdatedAt = user.UpdatedAt.Add(time.Second)
+	mock.users[id] = &updated
+
+	return &updated, nil
+}
+
+func (mock *userRepositoryMock) UpdateEmail(ctx context.Context, id string, email string) error {
+	user, err := mock.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	user.Email = email
+	user.EmailVerified = false
+	user.EmailVerifiedAt = nil
+	return nil
+}
+
+func (mock *userRepositoryMock) SetPendingEmail(ctx context.Context, id string, email string) error {
+	user, err := mock.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	user.PendingEmail = email
+	return nil
+}
+
+func (mock *userRepositoryMock) VerifyEmail(ctx context.Context, id string, email string, now time.Time) error {
+	user, err := mock.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	switch email {
+	case user.Email:
+	case user.PendingEmail:
+		user.Email = email
+		user.PendingEmail = ""
+	default:
+		return dao.ErrEmailMismatch
+	}
+
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+func (mock *userRepositoryMock) UpdatePassword(ctx context.Context, id string, password string) error {
+	user, err := mock.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	passwordHashed, err := mock.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	user.Password = passwordHashed
+	return nil
+}
+
+func (mock *userRepositoryMock) ListDueDeletions(_ context.Context, now time.Time) ([]*models.User, error) {
+	output := make([]*models.User, 0)
+	for _, user := range mock.users {
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(now) {
+			output = append(output, user)
+		}
+	}
+
+	return output, nil
+}
+
+func (mock *userRepositoryMock) Delete(ctx context.Context, id string, now time.Time) error {
+	user, err := mock.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(now) {
+		return dao.ErrDeletionNotDue
+	}
+
+	delete(mock.users, id)
+	return nil
+}
+
+type actionTokenRepositoryMock struct {
+	tokens map[string]*models.ActionToken
+}
+
+func newActionTokenRepositoryMock() *actionTokenRepositoryMock {
+	return &actionTokenRepositoryMock{tokens: map[string]*models.ActionToken{}}
+}
+
+func (mock *actionTokenRepositoryMock) Create(_ context.Context, tokenHash string, purpose models.ActionTokenPurpose, userID string, email string, now time.Time, expiresAt time.Time) (*models.ActionToken, error) {
+	token := &models.ActionToken{
+		ID:        tokenHash,
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	mock.tokens[tokenHash] = token
+
+	return token, nil
+}
+
+func (mock *actionTokenRepositoryMock) Get(_ context.Context, tokenHash string, purpose models.ActionTokenPurpose, now time.Time) (*models.ActionToken, error) {
+	token, ok := mock.tokens[tokenHash]
+	if !ok || token.Purpose != purpose {
+		return nil, dao.ErrActionTokenNotFound
+	}
+	if token.UsedAt != nil {
+		return nil, dao.ErrActionTokenUsed
+	}
+	if !token.ExpiresAt.After(now) {
+		return nil, dao.ErrActionTokenExpired
+	}
+
+	return token, nil
+}
+
+func (mock *actionTokenRepositoryMock) Consume(ctx context.Context, tokenHash string, purpose models.ActionTokenPurpose, now time.Time) (*models.ActionToken, error) {
+	token, err := mock.Get(ctx, tokenHash, purpose, now)
+	if err != nil {
+		return nil, err
+	}
+
+	token.UsedAt = &now
+	return token, nil
+}
+
+func (mock *actionTokenRepositoryMock) DeleteByUser(_ context.Context, userID string) error {
+	for id, token := range mock.tokens {
+		if token.UserID == userID {
+			delete(mock.tokens, id)
+		}
+	}
+
+	return nil
+}
+
+type mfaRepositoryMock struct {
+	mfa map[string]*models.MFA
+}
+
+func newMFARepositoryMock() *mfaRepositoryMock {
+	return &mfaRepositoryMock{mfa: map[string]*models.MFA{}}
+}
+
+func (mock *mfaRepositoryMock) Get(_ context.Context, userID string) (*models.MFA, error) {
+	mfa, ok := mock.mfa[userID]
+	if !ok {
+		return &models.MFA{UserID: userID}, nil
+	}
+
+	// Copied, like a document read from the database.
+	output := *mfa
+	output.RecoveryCodeHashes = slices.Clone(mfa.RecoveryCodeHashes)
+	return &output, nil
+}
+
+func (mock *mfaRepositoryMock) SetPendingTOTP(_ context.Context, userID string, secret string) error {
+	mfa, ok := mock.mfa[userID]
+	if !ok {
+		mfa = &models.MFA{UserID: userID}
+		mock.mfa[userID] = mfa
+	}
+	if mfa.TOTPEnabled() {
+		return dao.ErrMFAAlreadyEnabled
+	}
+
+	mfa.TOTPPendingSecret = secret
+	return nil
+}
+
+func (mock *mfaRepositoryMock) EnableTOTP(_ context.Context, userID string, counter int64, recoveryCodeHashes []string, now time.Time) error {
+	mfa, ok := mock.mfa[userID]
+	if !ok || mfa.TOTPPendingSecret == "" {
+		return dao.ErrNoPendingTOTP
+	}
+	if mfa.TOTPEnabled() {
+		return dao.ErrMFAAlreadyEnabled
+	}
+
+	mfa.TOTPSecret, mfa.TOTPPendingSecret = mfa.TOTPPendingSecret, ""
+	mfa.TOTPEnabledAt = &now
+	mfa.TOTPLastCounter = counter
+	mfa.RecoveryCodeHashes = recoveryCodeHashes
+	return nil
+}
+
+func (mock *mfaRepositoryMock) Disable(_ context.Context, userID string) error {
+	delete(mock.mfa, userID)
+	return nil
+}
+
+func (mock *mfaRepositoryMock) UseTOTPCounter(_ context.Context, userID string, counter int64) error {
+	mfa, ok := mock.mfa[userID]
+	if !ok || !mfa.TOTPEnabled() {
+		return dao.ErrMFANotEnabled
+	}
+	if counter <= mfa.TOTPLastCounter {
+		return dao.ErrTOTPCodeReused
+	}
+
+	mfa.TOTPLastCounter = counter
+	return nil
+}
+
+func (mock *mfaRepositoryMock) UseRecoveryCode(_ context.Context, userID string, codeHash string) error {
+	mfa, ok := mock.mfa[userID]
+	if !ok {
+		return dao.ErrRecoveryCodeNotFound
+	}
+
+	index := slices.Index(mfa.RecoveryCodeHashes, codeHash)
+	if index < 0 {
+		return dao.ErrRecoveryCodeNotFound
+	}
+
+	mfa.RecoveryCodeHashes = slices.Delete(mfa.RecoveryCodeHashes, index, index+1)
+	return nil
+}
+
+type webAuthnCredentialRepositoryMock struct {
+	credentials map[string]*models.WebAuthnCredential
+}
+
+func newWebAuthnCredentialRepositoryMock() *webAuthnCredentialRepositoryMock {
+	return &webAuthnCredentialRepositoryMock{credentials: map[string]*models.WebAuthnCredential{}}
+}
+
+func (mock *webAuthnCredentialRepositoryMock) Create(_ context.Context, credential *models.WebAuthnCredential) error {
+	if _, ok := mock.credentials[credential.ID]; ok {
+		return dao.ErrWebAuthnCredentialExists
+	}
+
+	stored := *credential
+	mock.credentials[credential.ID] = &stored
+	return nil
+}
+
+func (mock *webAuthnCredentialRepositoryMock) Get(_ context.Context, id string) (*models.WebAuthnCredential, error) {
+	credential, ok := mock.credentials[id]
+	if !ok {
+		return nil, dao.ErrWebAuthnCredentialNotFound
+	}
+
+	output := *credential
+	return &output, nil
+}
+
+func (mock *webAuthnCredentialRepositoryMock) ListByUser(_ context.Context, userID string) ([]*models.WebAuthnCredential, error) {
+	output := make([]*models.WebAuthnCredential, 0)
+	for _, credential := range mock.credentials {
+		if credential.UserID == userID {
+			copied := *credential
+			output = append(output, &copied)
+		}
+	}
+
+	slices.SortFunc(output, func(a, b *models.WebAuthnCredential) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return output, nil
+}
+
+func (mock *webAuthnCredentialRepositoryMock) RecordUse(_ context.Context, id string, signCount uint32, now time.Time) error {
+	credential, ok := mock.credentials[id]
+	if !ok {
+		return dao.ErrWebAuthnCredentialNotFound
+	}
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return dao.ErrSignCountRegression
+	}
+
+	credential.SignCount = signCount
+	credential.LastUsedAt = &now
+	return nil
+}
+
+func (mock *webAuthnCredentialRepositoryMock) Rename(_ context.Context, userID string, id string, name string) error {
+	credential, ok := mock.credentials[id]
+	if !ok || credential.UserID != userID {
+		return dao.ErrWebAuthnCredentialNotFound
+	}
+
+	credential.Name = name
+	return nil
+}
+
+func (mock *webAuthnCredentialRepositoryMock) Delete(_ context.Context, userID string, id string) error {
+	credential, ok := mock.credentials[id]
+	if !ok || credential.UserID != userID {
+		return dao.ErrWebAuthnCredentialNotFound
+	}
+
+	delete(mock.credentials, id)
+	return nil
+}
+
+func (mock *webAuthnCredentialRepositoryMock) DeleteByUser(_ context.Context, userID string) error {
+	for id, credential := range mock.credentials {
+		if credential.UserID == userID {
+			delete(mock.credentials, id)
+		}
+	}
+
+	return nil
+}
+
+type mailerMock struct {
+	sent []*models.Mail
+	// err is returned by Send when set, and the mail is not recorded.
+	err error
+}
+
+func (mock *mailerMock) Send(_ context.Context, mail *models.Mail) error {
+	if mock.err != nil {
+		return mock.err
+	}
+
+	mock.sent = append(mock.sent, mail)
+	return nil
+}
+
+// taskRunnerMock runs tasks right away, so their effects can be checked as soon as the service returns.
+type taskRunnerMock struct {
+	failures []error
+}
+
+func (mock *taskRunnerMock) Go(_ string, task func(ctx context.Context) error) {
+	if err := task(context.Background()); err != nil {
+		mock.failures = append(mock.failures, err)
+	}
+}
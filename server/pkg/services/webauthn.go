@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/webauthn"
+	"time"
+)
+
+const defaultWebAuthnCredentialName = "Passkey"
+
+var (
+	ErrInvalidWebAuthnChallenge  = errors.New("invalid or expired WebAuthn challenge")
+	ErrInvalidWebAuthnCredential = errors.New("invalid WebAuthn credential")
+)
+
+// webAuthnChallengeHash returns the hash the challenge is stored under, as an action token.
+func webAuthnChallengeHash(challenge []byte) string {
+	return hashSecret(webauthn.URLEncodedBase64(challenge).String())
+}
+
+// getWebAuthnChallenge returns the ceremony the client data answers. The challenge is read from the client data
+// before it is verified, only to find the ceremony it claims to answer.
+func getWebAuthnChallenge(
+	ctx context.Context,
+	tokens dao.ActionTokenRepository,
+	clientDataJSON []byte,
+	purpose models.ActionTokenPurpose,
+	now time.Time,
+) ([]byte, *models.ActionToken, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, nil, errors.Join(ErrInvalidWebAuthnCredential, err)
+	}
+
+	token, err := tokens.Get(ctx, webAuthnChallengeHash(clientData.Challenge), purpose, now)
+	if err != nil {
+		return nil, nil, invalidWebAuthnChallenge(err)
+	}
+
+	return clientData.Challenge, token, nil
+}
+
+// invalidWebAuthnChallenge wraps the errors of unusable challenges into ErrInvalidWebAuthnChallenge.
+func invalidWebAuthnChallenge(err error) error {
+	if errors.Is(err, dao.ErrActionTokenNotFound) ||
+		errors.Is(err, dao.ErrActionTokenExpired) ||
+		errors.Is(err, dao.ErrActionTokenUsed) {
+		return errors.Join(ErrInvalidWebAuthnChallenge, err)
+	}
+
+	return err
+}
+
+func credentialDescriptors(credentials []*models.WebAuthnCredential) ([]webauthn.CredentialDescriptor, error) {
+	output := make([]webauthn.CredentialDescriptor, len(credentials))
+
+	for i, credential := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(credential.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		output[i] = webauthn.CredentialDescriptor{
+			Type:       webauthn.CredentialTypePublicKey,
+			ID:         id,
+			Transports: credential.Transports,
+		}
+	}
+
+	return output, nil
+}
+
+type BeginWebAuthnRegistrationService interface {
+	// Exec starts the registration of a new authenticator for the principal.
+	Exec(ctx context.Context, principal *models.Principal) (*webauthn.CreationOptions, error)
+}
+
+// NewBeginWebAuthnRegistrationService creates the service. Registrations must complete within challengeTTL.
+func NewBeginWebAuthnRegistrationService(
+	users dao.UserRepository,
+	credentials dao.WebAuthnCredentialRepository,
+	tokens dao.ActionTokenRepository,
+	relyingParty webauthn.RelyingParty,
+	challengeTTL time.Duration,
+) BeginWebAuthnRegistrationService {
+	return &beginWebAuthnRegistrationServiceImpl{
+		users:        users,
+		credentials:  credentials,
+		tokens:       tokens,
+		relyingParty: relyingParty,
+		challengeTTL: challengeTTL,
+	}
+}
+
+type beginWebAuthnRegistrationServiceImpl struct {
+	users        dao.UserRepository
+	credentials  dao.WebAuthnCredentialRepository
+	tokens       dao.ActionTokenRepository
+	relyingParty webauthn.RelyingParty
+	challengeTTL time.Duration
+}
+
+func (s *beginWebAuthnRegistrationServiceImpl) Exec(ctx context.Context, principal *models.Principal) (*webauthn.CreationOptions, error) {
+	user, err := s.users.GetUser(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentials.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prevents registering the same authenticator twice.
+	exclude, err := credentialDescriptors(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.relyingParty.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = s.tokens.Create(
+		ctx, webAuthnChallengeHash(challenge), models.ActionTokenWebAuthnRegistration, user.ID, user.Email, now, now.Add(s.challengeTTL),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user handle is returned by authenticators on login, so it is the ID rather than the email, which may change.
+	entity := webauthn.UserEntity{ID: []byte(user.ID), Name: user.Email, DisplayName: user.Email}
+	return s.relyingParty.CreationOptions(challenge, entity, exclude), nil
+}
+
+type FinishWebAuthnRegistrationService interface {
+	// Exec verifies the response of the authenticator, and registers the credential under name.
+	Exec(ctx context.Context, principal *models.Principal, name string, credential *webauthn.RegistrationCredential) (*models.WebAuthnCredential, error)
+}
+
+func NewFinishWebAuthnRegistrationService(
+	credentials dao.WebAuthnCredentialRepository,
+	tokens dao.ActionTokenRepository,
+	relyingParty webauthn.RelyingParty,
+) FinishWebAuthnRegistrationService {
+	return &finishWebAuthnRegistrationServiceImpl{
+		credentials:  credentials,
+		tokens:       tokens,
+		relyingParty: relyingParty,
+	}
+}
+
+type finishWebAuthnRegistrationServiceImpl struct {
+	credentials  dao.WebAuthnCredentialRepository
+	tokens       dao.ActionTokenRepository
+	relyingParty webauthn.RelyingParty
+}
+
+func (s *finishWebAuthnRegistrationServiceImpl) Exec(
+	ctx context.Context,
+	principal *models.Principal,
+	name string,
+	credential *webauthn.RegistrationCredential,
+) (*models.WebAuthnCredential, error) {
+	now := time.Now()
+
+	challenge, token, err := getWebAuthnChallenge(
+		ctx, s.tokens, credential.Response.ClientDataJSON, models.ActionTokenWebAuthnRegistration, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// A challenge can't be used to add a credential to another account.
+	if token.UserID != principal.UserID {
+		return nil, ErrInvalidWebAuthnChallenge
+	}
+
+	verified, err := s.relyingParty.VerifyRegistration(challenge, credential)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthnCredential, err)
+	}
+
+	if _, err := s.tokens.Consume(ctx, token.ID, models.ActionTokenWebAuthnRegistration, now); err != nil {
+		return nil, invalidWebAuthnChallenge(err)
+	}
+
+	if name == "" {
+		name = defaultWebAuthnCredentialName
+	}
+
+	output := &models.WebAuthnCredential{
+		ID:         webauthn.URLEncodedBase64(verified.ID).String(),
+		UserID:     principal.UserID,
+		Name:       name,
+		PublicKey:  verified.PublicKey,
+		Algorithm:  verified.Algorithm,
+		SignCount:  verified.SignCount,
+		AAGUID:     verified.AAGUID,
+		Transports: verified.Transports,
+		BackedUp:   verified.BackedUp,
+		CreatedAt:  now,
+	}
+
+	if err := s.credentials.Create(ctx, output); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+type BeginWebAuthnLoginService interface {
+	// Exec starts a passwordless login. Any passkey of the relying party is accepted, so the user does not have to
+	// enter their email, and the response does not reveal whether an account exists.
+	Exec(ctx context.Context) (*webauthn.RequestOptions, error)
+}
+
+func NewBeginWebAuthnLoginService(
+	tokens dao.ActionTokenRepository,
+	relyingParty webauthn.RelyingParty,
+	challengeTTL time.Duration,
+) BeginWebAuthnLoginService {
+	return &beginWebAuthnLoginServiceImpl{
+		tokens:       tokens,
+		relyingParty: relyingParty,
+		challengeTTL: challengeTTL,
+	}
+}
+
+type beginWebAuthnLoginServiceImpl struct {
+	tokens       dao.ActionTokenRepository
+	relyingParty webauthn.RelyingParty
+	challengeTTL time.Duration
+}
+
+func (s *beginWebAuthnLoginServiceImpl) Exec(ctx context.Context) (*webauthn.RequestOptions, error) {
+	challenge, err := s.relyingParty.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = s.tokens.Create(ctx, webAuthnChallengeHash(challenge), models.ActionTokenWebAuthnLogin, "", "", now, now.Add(s.challengeTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.relyingParty.RequestOptions(challenge, nil), nil
+}
+
+type FinishWebAuthnLoginService interface {
+	// Exec verifies the assertion of the authenticator, and logs its owner in. The IP of the client is used for
+	// throttling.
+	Exec(ctx context.Context, credential *webauthn.AssertionCredential, client models.ClientInfo) (*models.User, *models.Credentials, error)
+}
+
+// NewFinishWebAuthnLoginService creates the service. If requireVerifiedEmail is true, users can't log in until they
+// verify their email, like with a password.
+func NewFinishWebAuthnLoginService(
+	users dao.UserRepository,
+	credentials dao.WebAuthnCredentialRepository,
+	tokens dao.ActionTokenRepository,
+	relyingParty webauthn.RelyingParty,
+	protection LoginProtectionService,
+	issueSession IssueSessionService,
+	requireVerifiedEmail bool,
+) FinishWebAuthnLoginService {
+	return &finishWebAuthnLoginServiceImpl{
+		users:                users,
+		credentials:          credentials,
+		tokens:               tokens,
+		relyingParty:         relyingParty,
+		protection:           protection,
+		issueSession:         issueSession,
+		requireVerifiedEmail: requireVerifiedEmail,
+	}
+}
+
+type finishWebAuthnLoginServiceImpl struct {
+	users                dao.UserRepository
+	credentials          dao.WebAuthnCredentialRepository
+	tokens               dao.ActionTokenRepository
+	relyingParty         webauthn.RelyingParty
+	protection           LoginProtectionService
+	issueSession         IssueSessionService
+	requireVerifiedEmail bool
+}
+
+func (s *finishWebAuthnLoginServiceImpl) Exec(
+	ctx context.Context,
+	credential *webauthn.AssertionCredential,
+	client models.ClientInfo,
+) (*models.User, *models.Credentials, error) {
+	now := time.Now()
+
+	challenge, token, err := getWebAuthnChallenge(ctx, s.tokens, credential.Response.ClientDataJSON, models.ActionTokenWebAuthnLogin, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := s.credentials.Get(ctx, credential.ID)
+	if err != nil {
+		if errors.Is(err, dao.ErrWebAuthnCredentialNotFound) {
+			return nil, nil, errors.Join(ErrInvalidWebAuthnCredential, err)
+		}
+
+		return nil, nil, err
+	}
+
+	// Discoverable credentials return the user handle they were created with.
+	if len(credential.Response.UserHandle) > 0 && string(credential.Response.UserHandle) != stored.UserID {
+		return nil, nil, ErrInvalidWebAuthnCredential
+	}
+
+	user, err := s.users.GetUser(ctx, stored.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.protection.Check(ctx, user.Email, client.IP, now); err != nil {
+		return nil, nil, err
+	}
+
+	assertion, err := s.relyingParty.VerifyAssertion(challenge, credential, stored.PublicKey)
+	if err != nil {
+		if err := s.protection.RecordFailure(ctx, user.Email, client.IP, now); err != nil {
+			return nil, nil, err
+		}
+
+		return nil, nil, errors.Join(ErrInvalidWebAuthnCredential, err)
+	}
+
+	if err := s.credentials.RecordUse(ctx, stored.ID, assertion.SignCount, now); err != nil {
+		// The authenticator may have been cloned.
+		if errors.Is(err, dao.ErrSignCountRegression) {
+			return nil, nil, errors.Join(ErrInvalidWebAuthnCredential, err)
+		}
+
+		return nil, nil, err
+	}
+
+	// Consumed last, so a signature can only be exchanged for a session once.
+	if _, err := s.tokens.Consume(ctx, token.ID, models.ActionTokenWebAuthnLogin, now); err != nil {
+		return nil, nil, invalidWebAuthnChallenge(err)
+	}
+
+	if s.requireVerifiedEmail && !user.EmailVerified {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	if err := s.protection.RecordSuccess(ctx, user.Email); err != nil {
+		return nil, nil, err
+	}
+
+	credentials, err := s.issueSession.IssueSession(ctx, user.ID, client, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, credentials, nil
+}
+
+type ListWebAuthnCredentialsService interface {
+	Exec(ctx context.Context, principal *models.Principal) ([]*models.WebAuthnCredential, error)
+}
+
+func NewListWebAuthnCredentialsService(credentials dao.WebAuthnCredentialRepository) ListWebAuthnCredentialsService {
+	return &listWebAuthnCredentialsServiceImpl{
+		credentials: credentials,
+	}
+}
+
+type listWebAuthnCredentialsServiceImpl struct {
+	credentials dao.WebAuthnCredentialRepository
+}
+
+func (s *listWebAuthnCredentialsServiceImpl) Exec(ctx context.Context, principal *models.Principal) ([]*models.WebAuthnCredential, error) {
+	return s.credentials.ListByUser(ctx, principal.UserID)
+}
+
+type RenameWebAuthnCredentialService interface {
+	Exec(ctx context.Context, principal *models.Principal, id string, name string) error
+}
+
+func NewRenameWebAuthnCredentialService(credentials dao.WebAuthnCredentialRepository) RenameWebAuthnCredentialService {
+	return &renameWebAuthnCredentialServiceImpl{
+		credentials: credentials,
+	}
+}
+
+type renameWebAuthnCredentialServiceImpl struct {
+	credentials dao.WebAuthnCredentialRepository
+}
+
+func (s *renameWebAuthnCredentialServiceImpl) Exec(ctx context.Context, principal *models.Principal, id string, name string) error {
+	return s.credentials.Rename(ctx, principal.UserID, id, name)
+}
+
+type DeleteWebAuthnCredentialService interface {
+	Exec(ctx context.Context, principal *models.Principal, id string) error
+}
+
+func NewDeleteWebAuthnCredentialService(credentials dao.WebAuthnCredentialRepository) DeleteWebAuthnCredentialService {
+	return &deleteWebAuthnCredentialServiceImpl{
+		credentials: credentials,
+	}
+}
+
+type deleteWebAuthnCredentialServiceImpl struct {
+	credentials dao.WebAuthnCredentialRepository
+}
+
+func (s *deleteWebAuthnCredentialServiceImpl) Exec(ctx context.Context, principal *models.Principal, id string) error {
+	return s.credentials.Delete(ctx, principal.UserID, id)
+}
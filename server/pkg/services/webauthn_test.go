@@ -0,0 +1,141 @@
+package services_test
+
+import (
+	"context"
+	"technical-interview/pkg/dao"
+	"technical-interview/pkg/models"
+	"technical-interview/pkg/services"
+	"technical-interview/pkg/webauthn"
+	"technical-interview/pkg/webauthn/webauthntest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebAuthn(t *testing.T) {
+	ctx := context.Background()
+	passwordHasher := newTestHasher(t)
+
+	users := newUserRepositoryMock(
+		passwordHasher,
+		&models.User{ID: "user-1", Email: "user@example.com"},
+		&models.User{ID: "user-2", Email: "other@example.com"},
+	)
+	credentials := newWebAuthnCredentialRepositoryMock()
+	tokens := newActionTokenRepositoryMock()
+	principal := &models.Principal{UserID: "user-1"}
+
+	relyingParty, err := webauthn.NewRelyingParty(webauthn.Options{
+		RPID:             "example.com",
+		Origins:          []string{"https://example.com"},
+		UserVerification: webauthn.UserVerificationRequired,
+	})
+	require.NoError(t, err)
+
+	key := newSigningKey(t, "key")
+	keys := &models.KeySet{ActiveID: key.ID, Keys: []models.SigningKey{key}}
+	issueSession := services.NewIssueSessionService(
+		newSessionRepositoryMock(),
+		users,
+		services.NewGenerateTokenService(time.Hour, keys, services.JWTOptions{}),
+		time.Hour,
+	)
+	protection := services.NewLoginProtectionService(dao.NewMemoryLoginAttemptRepository(), testEmailParser, services.LoginProtectionOptions{
+		AccountFreeAttempts: 10,
+		IPFreeAttempts:      10,
+		BaseDelay:           time.Minute,
+		MaxDelay:            time.Hour,
+		FailureWindow:       time.Hour,
+	})
+
+	beginRegistration := services.NewBeginWebAuthnRegistrationService(users, credentials, tokens, relyingParty, time.Minute)
+	finishRegistration := services.NewFinishWebAuthnRegistrationService(credentials, tokens, relyingParty)
+	beginLogin := services.NewBeginWebAuthnLoginService(tokens, relyingParty, time.Minute)
+	finishLogin := services.NewFinishWebAuthnLoginService(users, credentials, tokens, relyingParty, protection, issueSession, false)
+
+	authenticator := webauthntest.NewAuthenticator("https://example.com")
+
+	creationOptions, err := beginRegistration.Exec(ctx, principal)
+	require.NoError(t, err)
+	require.Equal(t, []byte("user-1"), []byte(creationOptions.User.ID))
+
+	registration, err := authenticator.Create(creationOptions)
+	require.NoError(t, err)
+
+	// The challenge was issued to another user.
+	_, err = finishRegistration.Exec(ctx, &models.Principal{UserID: "user-2"}, "Laptop", registration)
+	require.ErrorIs(t, err, services.ErrInvalidWebAuthnChallenge)
+
+	credential, err := finishRegistration.Exec(ctx, principal, "Laptop", registration)
+	require.NoError(t, err)
+	require.Equal(t, registration.ID, credential.ID)
+	require.Equal(t, "Laptop", credential.Name)
+
+	// Challenges are single use.
+	_, err = finishRegistration.Exec(ctx, principal, "Laptop", registration)
+	require.ErrorIs(t, err, services.ErrInvalidWebAuthnChallenge)
+
+	// Registered authenticators are excluded from new registrations.
+	creationOptions, err = beginRegistration.Exec(ctx, principal)
+	require.NoError(t, err)
+	require.Len(t, creationOptions.ExcludeCredentials, 1)
+
+	_, err = authenticator.Create(creationOptions)
+	require.ErrorIs(t, err, webauthntest.ErrCredentialExcluded)
+
+	requestOptions, err := beginLogin.Exec(ctx)
+	require.NoError(t, err)
+
+	assertion, err := authenticator.Get(requestOptions)
+	require.NoError(t, err)
+
+	user, userCredentials, err := finishLogin.Exec(ctx, assertion, models.ClientInfo{IP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.Equal(t, "user-1", user.ID)
+	require.NotEmpty(t, userCredentials.AccessToken)
+	require.NotEmpty(t, userCredentials.RefreshToken)
+
+	// An assertion can't be replayed.
+	_, _, err = finishLogin.Exec(ctx, assertion, models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidWebAuthnChallenge)
+
+	// A sign count that goes back reveals a cloned authenticator.
+	credentials.credentials[credential.ID].SignCount = 100
+
+	requestOptions, err = beginLogin.Exec(ctx)
+	require.NoError(t, err)
+
+	assertion, err = authenticator.Get(requestOptions)
+	require.NoError(t, err)
+
+	_, _, err = finishLogin.Exec(ctx, assertion, models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidWebAuthnCredential)
+	require.ErrorIs(t, err, dao.ErrSignCountRegression)
+
+	// Credentials can only be managed by their owner.
+	rename := services.NewRenameWebAuthnCredentialService(credentials)
+	remove := services.NewDeleteWebAuthnCredentialService(credentials)
+
+	err = rename.Exec(ctx, &models.Principal{UserID: "user-2"}, credential.ID, "Stolen")
+	require.ErrorIs(t, err, dao.ErrWebAuthnCredentialNotFound)
+	require.NoError(t, rename.Exec(ctx, principal, credential.ID, "Phone"))
+
+	err = remove.Exec(ctx, &models.Principal{UserID: "user-2"}, credential.ID)
+	require.ErrorIs(t, err, dao.ErrWebAuthnCredentialNotFound)
+	require.NoError(t, remove.Exec(ctx, principal, credential.ID))
+
+	list, err := services.NewListWebAuthnCredentialsService(credentials).Exec(ctx, principal)
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	// Deleted credentials can't log in anymore.
+	requestOptions, err = beginLogin.Exec(ctx)
+	require.NoError(t, err)
+
+	assertion, err = authenticator.Get(requestOptions)
+	require.NoError(t, err)
+
+	_, _, err = finishLogin.Exec(ctx, assertion, models.ClientInfo{IP: "10.0.0.1"})
+	require.ErrorIs(t, err, services.ErrInvalidWebAuthnCredential)
+}
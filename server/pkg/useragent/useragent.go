@@ -0,0 +1,70 @@
+package useragent
+
+import (
+	"strings"
+)
+
+// UnknownDevice labels clients without a recognizable user agent.
+const UnknownDevice = "Unknown device"
+
+// match associates a name to the first user agent token found in a user agent.
+type match struct {
+	token string
+	name  string
+}
+
+// browsers are checked in order, as most browsers also announce the engines they are compatible with: Edge and Opera
+// claim to be Chrome, and Chrome claims to be Safari.
+var browsers = []match{
+	{token: "Edg/", name: "Edge"},
+	{token: "EdgiOS/", name: "Edge"},
+	{token: "EdgA/", name: "Edge"},
+	{token: "OPR/", name: "Opera"},
+	{token: "SamsungBrowser/", name: "Samsung Internet"},
+	{token: "Firefox/", name: "Firefox"},
+	{token: "FxiOS/", name: "Firefox"},
+	{token: "CriOS/", name: "Chrome"},
+	{token: "Chrome/", name: "Chrome"},
+	{token: "Safari/", name: "Safari"},
+	{token: "curl/", name: "curl"},
+}
+
+// systems are checked in order, as Android user agents mention Linux, and iOS ones mention Mac OS X.
+var systems = []match{
+	{token: "iPhone", name: "iOS"},
+	{token: "iPad", name: "iPadOS"},
+	{token: "Android", name: "Android"},
+	{token: "Windows", name: "Windows"},
+	{token: "CrOS", name: "ChromeOS"},
+	{token: "Mac OS X", name: "macOS"},
+	{token: "Macintosh", name: "macOS"},
+	{token: "Linux", name: "Linux"},
+}
+
+func find(userAgent string, matches []match) string {
+	for _, m := range matches {
+		if strings.Contains(userAgent, m.token) {
+			return m.name
+		}
+	}
+
+	return ""
+}
+
+// Label returns a short, readable description of the client behind a user agent, like "Chrome on macOS", so users can
+// recognize their devices. It is a best effort: user agents are set by clients, and can't be trusted.
+func Label(userAgent string) string {
+	browser := find(userAgent, browsers)
+	system := find(userAgent, systems)
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return UnknownDevice
+	}
+}
@@ -0,0 +1,60 @@
+package useragent_test
+
+import (
+	"technical-interview/pkg/useragent"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabel(t *testing.T) {
+	data := []struct {
+		name string
+
+		userAgent string
+
+		expect string
+	}{
+		{
+			name:      "ChromeMacOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expect:    "Chrome on macOS",
+		},
+		{
+			name:      "EdgeWindows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			expect:    "Edge on Windows",
+		},
+		{
+			name:      "FirefoxLinux",
+			userAgent: "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			expect:    "Firefox on Linux",
+		},
+		{
+			name:      "SafariIPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			expect:    "Safari on iOS",
+		},
+		{
+			name:      "ChromeAndroid",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			expect:    "Chrome on Android",
+		},
+		{
+			name:      "BrowserOnly",
+			userAgent: "curl/8.4.0",
+			expect:    "curl",
+		},
+		{
+			name:      "Empty",
+			userAgent: "",
+			expect:    useragent.UnknownDevice,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			require.Equal(t, d.expect, useragent.Label(d.userAgent))
+		})
+	}
+}
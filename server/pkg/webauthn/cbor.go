@@ -0,0 +1,155 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var (
+	ErrInvalidCBOR = errors.New("invalid CBOR")
+)
+
+// maxCBORDepth bounds the nesting of decoded values. Authenticators never nest more than a few levels.
+const maxCBORDepth = 16
+
+const (
+	cborUnsigned = iota
+	cborNegative
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// decodeCBOR decodes the first CBOR value of data, and returns the bytes that follow it. It only supports the subset
+// of CBOR used by authenticators (CTAP2 canonical encoding): integers are returned as int64, byte and text strings as
+// []byte and string, arrays as []any and maps as map[any]any, with int64 or string keys.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeCBORValue(data, 0)
+}
+
+// readCBORHead reads the major type and argument of the next item.
+func readCBORHead(data []byte) (major byte, argument uint64, rest []byte, err error) {
+	if len(data) == 0 {
+		return 0, 0, nil, ErrInvalidCBOR
+	}
+
+	major, info, data := data[0]>>5, data[0]&0x1f, data[1:]
+
+	// Floats share the encoding of the arguments of other types.
+	if major == cborSimple && info >= 24 {
+		return 0, 0, nil, ErrInvalidCBOR
+	}
+
+	switch {
+	case info < 24:
+		return major, uint64(info), data, nil
+	case info == 24 && len(data) >= 1:
+		return major, uint64(data[0]), data[1:], nil
+	case info == 25 && len(data) >= 2:
+		return major, uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26 && len(data) >= 4:
+		return major, uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27 && len(data) >= 8:
+		return major, binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		// Indefinite lengths are not allowed by CTAP2.
+		return 0, 0, nil, ErrInvalidCBOR
+	}
+}
+
+func decodeCBORValue(data []byte, depth int) (any, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, ErrInvalidCBOR
+	}
+
+	major, argument, data, err := readCBORHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		if argument > math.MaxInt64 {
+			return nil, nil, ErrInvalidCBOR
+		}
+
+		return int64(argument), data, nil
+	case cborNegative:
+		if argument > math.MaxInt64 {
+			return nil, nil, ErrInvalidCBOR
+		}
+
+		return -1 - int64(argument), data, nil
+	case cborBytes, cborText:
+		if argument > uint64(len(data)) {
+			return nil, nil, ErrInvalidCBOR
+		}
+
+		value := data[:argument:argument]
+		if major == cborText {
+			return string(value), data[argument:], nil
+		}
+
+		return value, data[argument:], nil
+	case cborArray:
+		// Every item takes at least a byte, which bounds the allocation.
+		if argument > uint64(len(data)) {
+			return nil, nil, ErrInvalidCBOR
+		}
+
+		output := make([]any, argument)
+		for i := range output {
+			if output[i], data, err = decodeCBORValue(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		return output, data, nil
+	case cborMap:
+		if argument > uint64(len(data))/2 {
+			return nil, nil, ErrInvalidCBOR
+		}
+
+		output := make(map[any]any, argument)
+		for i := uint64(0); i < argument; i++ {
+			var key, value any
+			if key, data, err = decodeCBORValue(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, ErrInvalidCBOR
+			}
+
+			if _, ok := output[key]; ok {
+				return nil, nil, ErrInvalidCBOR
+			}
+
+			if value, data, err = decodeCBORValue(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+
+			output[key] = value
+		}
+
+		return output, data, nil
+	case cborSimple:
+		switch argument {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		}
+	}
+
+	// Tags and floats are not used by authenticators.
+	return nil, nil, ErrInvalidCBOR
+}
@@ -0,0 +1,135 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers, from the IANA registry.
+const (
+	AlgorithmES256 int64 = -7
+	AlgorithmEdDSA int64 = -8
+	AlgorithmRS256 int64 = -257
+)
+
+// Algorithms are the signature algorithms accepted for new credentials, by order of preference.
+var Algorithms = []int64{AlgorithmES256, AlgorithmEdDSA, AlgorithmRS256}
+
+// COSE key parameters (RFC 9053).
+const (
+	coseKeyType      int64 = 1
+	coseKeyAlgorithm int64 = 3
+	// The meaning of negative labels depends on the key type: curve, x and y for elliptic curves, modulus and
+	// exponent for RSA.
+	coseKeyParam1 int64 = -1
+	coseKeyParam2 int64 = -2
+	coseKeyParam3 int64 = -3
+
+	coseKeyTypeOKP int64 = 1
+	coseKeyTypeEC2 int64 = 2
+	coseKeyTypeRSA int64 = 3
+
+	coseCurveP256    int64 = 1
+	coseCurveEd25519 int64 = 6
+
+	minRSABits = 2048
+)
+
+var (
+	ErrInvalidPublicKey     = errors.New("invalid public key")
+	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+	ErrInvalidSignature     = errors.New("invalid signature")
+)
+
+// PublicKey is a credential public key, parsed from its COSE encoding.
+type PublicKey struct {
+	Algorithm int64
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey parses a COSE encoded key, as found in authenticator data. Only the keys of Algorithms are
+// supported.
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	value, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidPublicKey, err)
+	}
+	if len(rest) > 0 {
+		return nil, ErrInvalidPublicKey
+	}
+
+	params, ok := value.(map[any]any)
+	if !ok {
+		return nil, ErrInvalidPublicKey
+	}
+
+	keyType, _ := params[coseKeyType].(int64)
+	algorithm, _ := params[coseKeyAlgorithm].(int64)
+	curve, _ := params[coseKeyParam1].(int64)
+
+	switch {
+	case algorithm == AlgorithmES256 && keyType == coseKeyTypeEC2 && curve == coseCurveP256:
+		x, _ := params[coseKeyParam2].([]byte)
+		y, _ := params[coseKeyParam3].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, ErrInvalidPublicKey
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrInvalidPublicKey
+		}
+
+		return &PublicKey{Algorithm: algorithm, key: key}, nil
+	case algorithm == AlgorithmEdDSA && keyType == coseKeyTypeOKP && curve == coseCurveEd25519:
+		x, _ := params[coseKeyParam2].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidPublicKey
+		}
+
+		return &PublicKey{Algorithm: algorithm, key: ed25519.PublicKey(x)}, nil
+	case algorithm == AlgorithmRS256 && keyType == coseKeyTypeRSA:
+		n, _ := params[coseKeyParam1].([]byte)
+		e, _ := params[coseKeyParam2].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return nil, ErrInvalidPublicKey
+		}
+
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSABits || key.E < 3 {
+			return nil, ErrInvalidPublicKey
+		}
+
+		return &PublicKey{Algorithm: algorithm, key: key}, nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// Verify checks the signature of data, in the format produced by authenticators: DER for ECDSA, raw for EdDSA and
+// PKCS #1 v1.5 for RSA.
+func (publicKey *PublicKey) Verify(data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+	ok := false
+
+	switch key := publicKey.key.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
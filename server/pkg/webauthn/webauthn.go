@@ -0,0 +1,535 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+
+	CredentialTypePublicKey = "public-key"
+
+	clientDataTypeCreate = "webauthn.create"
+	clientDataTypeGet    = "webauthn.get"
+
+	challengeLength = 32
+	// maxCredentialIDLength is the limit set by the specification.
+	maxCredentialIDLength = 1023
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent    byte = 1 << 0
+	flagUserVerified   byte = 1 << 2
+	flagBackupEligible byte = 1 << 3
+	flagBackedUp       byte = 1 << 4
+	flagAttestedData   byte = 1 << 6
+	flagExtensionData  byte = 1 << 7
+)
+
+var (
+	ErrInvalidOptions         = errors.New("invalid WebAuthn options")
+	ErrInvalidResponse        = errors.New("invalid WebAuthn response")
+	ErrChallengeMismatch      = errors.New("challenge mismatch")
+	ErrOriginMismatch         = errors.New("origin not allowed")
+	ErrRPIDMismatch           = errors.New("relying party ID mismatch")
+	ErrUserNotPresent         = errors.New("user presence not asserted")
+	ErrUserNotVerified        = errors.New("user verification required")
+	ErrCredentialMismatch     = errors.New("credential ID mismatch")
+	ErrInvalidAuthenticator   = errors.New("invalid authenticator data")
+	ErrUnsupportedAttestation = errors.New("unsupported attestation")
+)
+
+// URLEncodedBase64 is a byte slice encoded in JSON as unpadded base64url, the encoding of binary fields in the JSON
+// serialization of WebAuthn.
+type URLEncodedBase64 []byte
+
+func (data URLEncodedBase64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(data))
+}
+
+func (data *URLEncodedBase64) UnmarshalJSON(input []byte) error {
+	var encoded string
+	if err := json.Unmarshal(input, &encoded); err != nil {
+		return err
+	}
+
+	// Some clients still pad their values.
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return err
+	}
+
+	*data = decoded
+	return nil
+}
+
+// String returns the base64url encoding of the data, without padding.
+func (data URLEncodedBase64) String() string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	// ID is the user handle. It must not contain personal information, and is returned by discoverable credentials.
+	ID          URLEncodedBase64 `json:"id"`
+	Name        string           `json:"name"`
+	DisplayName string           `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string           `json:"type"`
+	ID         URLEncodedBase64 `json:"id"`
+	Transports []string         `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions are the options of a registration ceremony, in the JSON format read by
+// PublicKeyCredential.parseCreationOptionsFromJSON.
+type CreationOptions struct {
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              URLEncodedBase64       `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options of an authentication ceremony, in the JSON format read by
+// PublicKeyCredential.parseRequestOptionsFromJSON.
+type RequestOptions struct {
+	Challenge        URLEncodedBase64       `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    URLEncodedBase64 `json:"clientDataJSON" binding:"required"`
+	AttestationObject URLEncodedBase64 `json:"attestationObject" binding:"required"`
+	Transports        []string         `json:"transports"`
+}
+
+// RegistrationCredential is the result of navigator.credentials.create, serialized with toJSON.
+type RegistrationCredential struct {
+	ID       string                           `json:"id" binding:"required"`
+	RawID    URLEncodedBase64                 `json:"rawId" binding:"required"`
+	Type     string                           `json:"type" binding:"required"`
+	Response AuthenticatorAttestationResponse `json:"response" binding:"required"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    URLEncodedBase64 `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData URLEncodedBase64 `json:"authenticatorData" binding:"required"`
+	Signature         URLEncodedBase64 `json:"signature" binding:"required"`
+	UserHandle        URLEncodedBase64 `json:"userHandle"`
+}
+
+// AssertionCredential is the result of navigator.credentials.get, serialized with toJSON.
+type AssertionCredential struct {
+	ID       string                         `json:"id" binding:"required"`
+	RawID    URLEncodedBase64               `json:"rawId" binding:"required"`
+	Type     string                         `json:"type" binding:"required"`
+	Response AuthenticatorAssertionResponse `json:"response" binding:"required"`
+}
+
+// ClientData is the data the browser passes to the authenticator, and returns as clientDataJSON.
+type ClientData struct {
+	Type        string           `json:"type"`
+	Challenge   URLEncodedBase64 `json:"challenge"`
+	Origin      string           `json:"origin"`
+	CrossOrigin bool             `json:"crossOrigin"`
+}
+
+// ParseClientData decodes clientDataJSON. It does not verify anything, but gives access to the challenge needed to
+// look up the ceremony.
+func ParseClientData(clientDataJSON []byte) (*ClientData, error) {
+	output := new(ClientData)
+	if err := json.Unmarshal(clientDataJSON, output); err != nil {
+		return nil, errors.Join(ErrInvalidResponse, err)
+	}
+
+	return output, nil
+}
+
+// AuthenticatorData is the data signed by the authenticator.
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// AAGUID, CredentialID and PublicKey are only set on registration.
+	AAGUID       []byte
+	CredentialID []byte
+	// PublicKey is the COSE encoded credential public key.
+	PublicKey []byte
+}
+
+func (data *AuthenticatorData) UserPresent() bool {
+	return data.Flags&flagUserPresent != 0
+}
+
+func (data *AuthenticatorData) UserVerified() bool {
+	return data.Flags&flagUserVerified != 0
+}
+
+// BackupEligible returns true if the credential can be synced, like most passkeys.
+func (data *AuthenticatorData) BackupEligible() bool {
+	return data.Flags&flagBackupEligible != 0
+}
+
+func (data *AuthenticatorData) BackedUp() bool {
+	return data.Flags&flagBackedUp != 0
+}
+
+// ParseAuthenticatorData decodes the binary authenticator data.
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrInvalidAuthenticator
+	}
+
+	output := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if output.Flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, ErrInvalidAuthenticator
+		}
+
+		output.AAGUID = rest[:16]
+		credentialIDLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+
+		if credentialIDLength > maxCredentialIDLength || credentialIDLength > len(rest) {
+			return nil, ErrInvalidAuthenticator
+		}
+
+		output.CredentialID = rest[:credentialIDLength]
+		rest = rest[credentialIDLength:]
+
+		// The key is not prefixed with its length, so it must be decoded to find where it ends.
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidAuthenticator, err)
+		}
+
+		output.PublicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if output.Flags&flagExtensionData != 0 {
+		var err error
+		if _, rest, err = decodeCBOR(rest); err != nil {
+			return nil, errors.Join(ErrInvalidAuthenticator, err)
+		}
+	}
+
+	if len(rest) > 0 {
+		return nil, ErrInvalidAuthenticator
+	}
+
+	return output, nil
+}
+
+// Credential is a credential created by a registration ceremony.
+type Credential struct {
+	ID []byte
+	// PublicKey is the COSE encoded public key, to give to VerifyAssertion.
+	PublicKey      []byte
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         string
+	Transports     []string
+	BackupEligible bool
+	BackedUp       bool
+}
+
+// Assertion is the result of a verified authentication ceremony.
+type Assertion struct {
+	// SignCount is the counter of the authenticator, to check it increased since the last use of the credential.
+	// Authenticators that don't count always return 0.
+	SignCount    uint32
+	UserVerified bool
+	BackedUp     bool
+}
+
+type Options struct {
+	// RPID is the domain the credentials are bound to. It must be the domain of the origins or one of its parents.
+	RPID string
+	// RPName is shown to users by their authenticator.
+	RPName string
+	// Origins are the web origins allowed to run ceremonies, like https://example.com.
+	Origins []string
+	// Timeout is the hint given to browsers for the duration of a ceremony.
+	Timeout time.Duration
+	// UserVerification is required, preferred or discouraged. Only a required verification is enforced.
+	UserVerification string
+}
+
+type RelyingParty interface {
+	// NewChallenge returns a random challenge, to keep until the ceremony completes.
+	NewChallenge() ([]byte, error)
+	// CreationOptions returns the options of a registration ceremony. Authenticators refuse to create a new
+	// credential if they already hold one of exclude.
+	CreationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) *CreationOptions
+	// RequestOptions returns the options of an authentication ceremony. An empty allow list lets the user pick any
+	// discoverable credential (passkey) of the relying party.
+	RequestOptions(challenge []byte, allow []CredentialDescriptor) *RequestOptions
+	// VerifyRegistration verifies the response of a registration ceremony, and returns the new credential.
+	VerifyRegistration(challenge []byte, credential *RegistrationCredential) (*Credential, error)
+	// VerifyAssertion verifies the response of an authentication ceremony with the COSE encoded public key of the
+	// credential.
+	VerifyAssertion(challenge []byte, credential *AssertionCredential, publicKey []byte) (*Assertion, error)
+}
+
+func NewRelyingParty(options Options) (RelyingParty, error) {
+	if options.RPID == "" || len(options.Origins) == 0 {
+		return nil, ErrInvalidOptions
+	}
+
+	switch options.UserVerification {
+	case UserVerificationRequired, UserVerificationPreferred, UserVerificationDiscouraged:
+	default:
+		return nil, ErrInvalidOptions
+	}
+
+	if options.RPName == "" {
+		options.RPName = options.RPID
+	}
+
+	return &relyingPartyImpl{
+		options:  options,
+		rpIDHash: sha256.Sum256([]byte(options.RPID)),
+	}, nil
+}
+
+type relyingPartyImpl struct {
+	options  Options
+	rpIDHash [32]byte
+}
+
+func (rp *relyingPartyImpl) NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (rp *relyingPartyImpl) CreationOptions(challenge []byte, user UserEntity, exclude []CredentialDescriptor) *CreationOptions {
+	params := make([]CredentialParameter, len(Algorithms))
+	for i, algorithm := range Algorithms {
+		params[i] = CredentialParameter{Type: CredentialTypePublicKey, Algorithm: algorithm}
+	}
+
+	return &CreationOptions{
+		RP:                 RelyingPartyEntity{ID: rp.options.RPID, Name: rp.options.RPName},
+		User:               user,
+		Challenge:          challenge,
+		PubKeyCredParams:   params,
+		Timeout:            rp.options.Timeout.Milliseconds(),
+		ExcludeCredentials: append([]CredentialDescriptor{}, exclude...),
+		// Passkeys must be discoverable, as logins don't ask for an email first.
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   rp.options.UserVerification,
+		},
+		// Attestation is not verified, so there is no point in asking for it.
+		Attestation: "none",
+	}
+}
+
+func (rp *relyingPartyImpl) RequestOptions(challenge []byte, allow []CredentialDescriptor) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.options.Timeout.Milliseconds(),
+		RPID:             rp.options.RPID,
+		AllowCredentials: append([]CredentialDescriptor{}, allow...),
+		UserVerification: rp.options.UserVerification,
+	}
+}
+
+// verifyClientData checks the client data was produced by an allowed origin, for the expected ceremony.
+func (rp *relyingPartyImpl) verifyClientData(clientDataJSON []byte, expectedType string, challenge []byte) error {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+
+	if clientData.Type != expectedType {
+		return ErrInvalidResponse
+	}
+	if subtle.ConstantTimeCompare(clientData.Challenge, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+	// Ceremonies are not expected to run in third party frames.
+	if clientData.CrossOrigin || !slices.Contains(rp.options.Origins, clientData.Origin) {
+		return ErrOriginMismatch
+	}
+
+	return nil
+}
+
+// verifyAuthenticatorData checks the data was produced for this relying party, with the user present and, if
+// required, verified.
+func (rp *relyingPartyImpl) verifyAuthenticatorData(authenticatorData *AuthenticatorData) error {
+	if subtle.ConstantTimeCompare(authenticatorData.RPIDHash, rp.rpIDHash[:]) != 1 {
+		return ErrRPIDMismatch
+	}
+	if !authenticatorData.UserPresent() {
+		return ErrUserNotPresent
+	}
+	if rp.options.UserVerification == UserVerificationRequired && !authenticatorData.UserVerified() {
+		return ErrUserNotVerified
+	}
+
+	return nil
+}
+
+func (rp *relyingPartyImpl) VerifyRegistration(challenge []byte, credential *RegistrationCredential) (*Credential, error) {
+	if credential.Type != CredentialTypePublicKey {
+		return nil, ErrInvalidResponse
+	}
+
+	if err := rp.verifyClientData(credential.Response.ClientDataJSON, clientDataTypeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	value, rest, err := decodeCBOR(credential.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidResponse, err)
+	}
+
+	attestation, ok := value.(map[any]any)
+	if !ok || len(rest) > 0 {
+		return nil, ErrInvalidResponse
+	}
+
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[any]any)
+	rawAuthenticatorData, _ := attestation["authData"].([]byte)
+
+	// Attestation is never requested, and browsers replace it with "none" unless the authenticator is trusted by an
+	// enterprise policy. Other formats are accepted, but their statement is not trusted nor checked.
+	if format == "" || statement == nil || (format == "none" && len(statement) > 0) {
+		return nil, ErrUnsupportedAttestation
+	}
+
+	authenticatorData, err := ParseAuthenticatorData(rawAuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rp.verifyAuthenticatorData(authenticatorData); err != nil {
+		return nil, err
+	}
+
+	if authenticatorData.CredentialID == nil {
+		return nil, ErrInvalidAuthenticator
+	}
+	if !bytes.Equal(authenticatorData.CredentialID, credential.RawID) ||
+		credential.ID != credential.RawID.String() {
+		return nil, ErrCredentialMismatch
+	}
+
+	publicKey, err := ParsePublicKey(authenticatorData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             authenticatorData.CredentialID,
+		PublicKey:      authenticatorData.PublicKey,
+		Algorithm:      publicKey.Algorithm,
+		SignCount:      authenticatorData.SignCount,
+		AAGUID:         formatAAGUID(authenticatorData.AAGUID),
+		Transports:     credential.Response.Transports,
+		BackupEligible: authenticatorData.BackupEligible(),
+		BackedUp:       authenticatorData.BackedUp(),
+	}, nil
+}
+
+func (rp *relyingPartyImpl) VerifyAssertion(challenge []byte, credential *AssertionCredential, publicKey []byte) (*Assertion, error) {
+	if credential.Type != CredentialTypePublicKey {
+		return nil, ErrInvalidResponse
+	}
+	if credential.ID != credential.RawID.String() {
+		return nil, ErrCredentialMismatch
+	}
+
+	if err := rp.verifyClientData(credential.Response.ClientDataJSON, clientDataTypeGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authenticatorData, err := ParseAuthenticatorData(credential.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rp.verifyAuthenticatorData(authenticatorData); err != nil {
+		return nil, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(credential.Response.ClientDataJSON)
+	signed := append(slices.Clip(credential.Response.AuthenticatorData), clientDataHash[:]...)
+
+	if err := key.Verify(signed, credential.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		SignCount:    authenticatorData.SignCount,
+		UserVerified: authenticatorData.UserVerified(),
+		BackedUp:     authenticatorData.BackedUp(),
+	}, nil
+}
+
+// formatAAGUID formats the AAGUID of an authenticator model like a UUID.
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+
+	encoded := hex.EncodeToString(aaguid)
+	return encoded[:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:]
+}
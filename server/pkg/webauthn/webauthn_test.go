@@ -0,0 +1,281 @@
+package webauthn_test
+
+import (
+	"technical-interview/pkg/webauthn"
+	"technical-interview/pkg/webauthn/webauthntest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testOrigin = "https://example.com"
+
+func newTestRelyingParty(t *testing.T, rpID string, userVerification string) webauthn.RelyingParty {
+	rp, err := webauthn.NewRelyingParty(webauthn.Options{
+		RPID:             rpID,
+		RPName:           "Example",
+		Origins:          []string{testOrigin},
+		Timeout:          time.Minute,
+		UserVerification: userVerification,
+	})
+	require.NoError(t, err)
+
+	return rp
+}
+
+// register creates a credential on the authenticator, and returns it as verified by the relying party.
+func register(t *testing.T, rp webauthn.RelyingParty, authenticator *webauthntest.Authenticator) *webauthn.Credential {
+	challenge, err := rp.NewChallenge()
+	require.NoError(t, err)
+
+	options := rp.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("user-1"), Name: "user@example.com"}, nil)
+	response, err := authenticator.Create(options)
+	require.NoError(t, err)
+
+	credential, err := rp.VerifyRegistration(challenge, response)
+	require.NoError(t, err)
+
+	return credential
+}
+
+func TestRegistration(t *testing.T) {
+	rp := newTestRelyingParty(t, "example.com", webauthn.UserVerificationRequired)
+
+	data := []struct {
+		name string
+
+		authenticator *webauthntest.Authenticator
+		rp            webauthn.RelyingParty
+		// challenge replaces the challenge sent to the authenticator when set.
+		challenge []byte
+
+		expectErr error
+	}{
+		{
+			name:          "Success",
+			authenticator: webauthntest.NewAuthenticator(testOrigin),
+			rp:            rp,
+		},
+		{
+			name:          "WrongChallenge",
+			authenticator: webauthntest.NewAuthenticator(testOrigin),
+			rp:            rp,
+			challenge:     []byte("other challenge"),
+			expectErr:     webauthn.ErrChallengeMismatch,
+		},
+		{
+			name:          "WrongOrigin",
+			authenticator: webauthntest.NewAuthenticator("https://evil.example"),
+			rp:            rp,
+			expectErr:     webauthn.ErrOriginMismatch,
+		},
+		{
+			name:          "WrongRPID",
+			authenticator: webauthntest.NewAuthenticator(testOrigin),
+			rp:            newTestRelyingParty(t, "other.example.com", webauthn.UserVerificationRequired),
+			expectErr:     webauthn.ErrRPIDMismatch,
+		},
+		{
+			name:          "UserNotVerified",
+			authenticator: &webauthntest.Authenticator{Origin: testOrigin, SkipUserVerification: true},
+			rp:            rp,
+			expectErr:     webauthn.ErrUserNotVerified,
+		},
+		{
+			name:          "UserVerificationPreferred",
+			authenticator: &webauthntest.Authenticator{Origin: testOrigin, SkipUserVerification: true},
+			rp:            newTestRelyingParty(t, "example.com", webauthn.UserVerificationPreferred),
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			challenge, err := rp.NewChallenge()
+			require.NoError(t, err)
+
+			// Options always come from rp, so the authenticator scopes the credential to example.com.
+			options := rp.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("user-1"), Name: "user@example.com"}, nil)
+			response, err := d.authenticator.Create(options)
+			require.NoError(t, err)
+
+			if d.challenge != nil {
+				challenge = d.challenge
+			}
+
+			credential, err := d.rp.VerifyRegistration(challenge, response)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				require.Equal(t, []byte(response.RawID), credential.ID)
+				require.Equal(t, webauthn.AlgorithmES256, credential.Algorithm)
+				require.Equal(t, "77656261-7574-686e-7465-73742d737721", credential.AAGUID)
+				require.Equal(t, []string{"internal"}, credential.Transports)
+
+				_, err = webauthn.ParsePublicKey(credential.PublicKey)
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRegistrationExcludedCredentials(t *testing.T) {
+	rp := newTestRelyingParty(t, "example.com", webauthn.UserVerificationRequired)
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+	credential := register(t, rp, authenticator)
+
+	challenge, err := rp.NewChallenge()
+	require.NoError(t, err)
+
+	options := rp.CreationOptions(
+		challenge,
+		webauthn.UserEntity{ID: []byte("user-1"), Name: "user@example.com"},
+		[]webauthn.CredentialDescriptor{{Type: webauthn.CredentialTypePublicKey, ID: credential.ID}},
+	)
+
+	_, err = authenticator.Create(options)
+	require.ErrorIs(t, err, webauthntest.ErrCredentialExcluded)
+}
+
+func TestAssertion(t *testing.T) {
+	rp := newTestRelyingParty(t, "example.com", webauthn.UserVerificationRequired)
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+	credential := register(t, rp, authenticator)
+
+	data := []struct {
+		name string
+
+		// tamper modifies the response before it is verified.
+		tamper    func(response *webauthn.AssertionCredential, challenge []byte) []byte
+		expectErr error
+	}{
+		{
+			name: "Success",
+		},
+		{
+			name: "WrongChallenge",
+			tamper: func(_ *webauthn.AssertionCredential, _ []byte) []byte {
+				return []byte("other challenge")
+			},
+			expectErr: webauthn.ErrChallengeMismatch,
+		},
+		{
+			name: "TamperedSignature",
+			tamper: func(response *webauthn.AssertionCredential, challenge []byte) []byte {
+				response.Response.Signature[len(response.Response.Signature)-1] ^= 1
+				return challenge
+			},
+			expectErr: webauthn.ErrInvalidSignature,
+		},
+		{
+			name: "TamperedAuthenticatorData",
+			tamper: func(response *webauthn.AssertionCredential, challenge []byte) []byte {
+				// Sign count.
+				response.Response.AuthenticatorData[36] ^= 1
+				return challenge
+			},
+			expectErr: webauthn.ErrInvalidSignature,
+		},
+		{
+			name: "MismatchedID",
+			tamper: func(response *webauthn.AssertionCredential, challenge []byte) []byte {
+				response.ID = "other"
+				return challenge
+			},
+			expectErr: webauthn.ErrCredentialMismatch,
+		},
+		{
+			name: "TruncatedAuthenticatorData",
+			tamper: func(response *webauthn.AssertionCredential, challenge []byte) []byte {
+				response.Response.AuthenticatorData = response.Response.AuthenticatorData[:36]
+				return challenge
+			},
+			expectErr: webauthn.ErrInvalidAuthenticator,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			challenge, err := rp.NewChallenge()
+			require.NoError(t, err)
+
+			response, err := authenticator.Get(rp.RequestOptions(challenge, nil))
+			require.NoError(t, err)
+			require.Equal(t, []byte("user-1"), []byte(response.Response.UserHandle))
+
+			if d.tamper != nil {
+				challenge = d.tamper(response, challenge)
+			}
+
+			assertion, err := rp.VerifyAssertion(challenge, response, credential.PublicKey)
+			require.ErrorIs(t, err, d.expectErr)
+
+			if err == nil {
+				require.True(t, assertion.UserVerified)
+				require.Greater(t, assertion.SignCount, credential.SignCount)
+			}
+		})
+	}
+}
+
+func TestAssertionAllowCredentials(t *testing.T) {
+	rp := newTestRelyingParty(t, "example.com", webauthn.UserVerificationRequired)
+	authenticator := webauthntest.NewAuthenticator(testOrigin)
+	register(t, rp, authenticator)
+
+	challenge, err := rp.NewChallenge()
+	require.NoError(t, err)
+
+	options := rp.RequestOptions(
+		challenge,
+		[]webauthn.CredentialDescriptor{{Type: webauthn.CredentialTypePublicKey, ID: []byte("unknown")}},
+	)
+
+	_, err = authenticator.Get(options)
+	require.ErrorIs(t, err, webauthntest.ErrNoCredential)
+}
+
+func TestParseAuthenticatorData(t *testing.T) {
+	data := []struct {
+		name string
+
+		data      []byte
+		expectErr error
+	}{
+		{
+			name:      "TooShort",
+			data:      make([]byte, 36),
+			expectErr: webauthn.ErrInvalidAuthenticator,
+		},
+		{
+			name: "Minimal",
+			data: make([]byte, 37),
+		},
+		{
+			name:      "TrailingBytes",
+			data:      make([]byte, 38),
+			expectErr: webauthn.ErrInvalidAuthenticator,
+		},
+		{
+			name: "TruncatedAttestedData",
+			// Attested credential data flag, with a credential ID length larger than the data.
+			data:      append(append(make([]byte, 32), 1<<6, 0, 0, 0, 0), append(make([]byte, 16), 0xff, 0xff)...),
+			expectErr: webauthn.ErrInvalidAuthenticator,
+		},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			_, err := webauthn.ParseAuthenticatorData(d.data)
+			require.ErrorIs(t, err, d.expectErr)
+		})
+	}
+}
+
+func TestNewRelyingParty(t *testing.T) {
+	_, err := webauthn.NewRelyingParty(webauthn.Options{RPID: "example.com", UserVerification: webauthn.UserVerificationRequired})
+	require.ErrorIs(t, err, webauthn.ErrInvalidOptions)
+
+	_, err = webauthn.NewRelyingParty(webauthn.Options{RPID: "example.com", Origins: []string{testOrigin}, UserVerification: "always"})
+	require.ErrorIs(t, err, webauthn.ErrInvalidOptions)
+}
@@ -0,0 +1,253 @@
+// Package webauthntest provides a software authenticator, to run WebAuthn ceremonies in tests without a browser.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"technical-interview/pkg/webauthn"
+)
+
+var (
+	ErrCredentialExcluded   = errors.New("the authenticator already holds an excluded credential")
+	ErrNoCredential         = errors.New("the authenticator holds no matching credential")
+	ErrUnsupportedAlgorithm = errors.New("the authenticator only supports ES256")
+)
+
+// AAGUID identifies the model of the software authenticator.
+var AAGUID = []byte("webauthntest-sw!")
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// Authenticator holds discoverable ES256 credentials in memory. It also plays the part of the browser, and answers
+// ceremonies from Origin.
+type Authenticator struct {
+	Origin string
+	// SkipUserVerification leaves the user verified flag unset, like a security key without PIN.
+	SkipUserVerification bool
+	// ZeroSignCount makes the authenticator always report a sign count of 0, like most passkey providers.
+	ZeroSignCount bool
+
+	credentials []*credential
+}
+
+func NewAuthenticator(origin string) *Authenticator {
+	return &Authenticator{Origin: origin}
+}
+
+func (authenticator *Authenticator) flags() byte {
+	// User present.
+	flags := byte(1 << 0)
+	if !authenticator.SkipUserVerification {
+		flags |= 1 << 2
+	}
+
+	return flags
+}
+
+func (authenticator *Authenticator) clientDataJSON(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(webauthn.ClientData{
+		Type:      ceremony,
+		Challenge: challenge,
+		Origin:    authenticator.Origin,
+	})
+}
+
+// Create runs a registration ceremony, like navigator.credentials.create.
+func (authenticator *Authenticator) Create(options *webauthn.CreationOptions) (*webauthn.RegistrationCredential, error) {
+	supported := slices.ContainsFunc(options.PubKeyCredParams, func(param webauthn.CredentialParameter) bool {
+		return param.Algorithm == webauthn.AlgorithmES256
+	})
+	if !supported {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	for _, excluded := range options.ExcludeCredentials {
+		if authenticator.find(options.RP.ID, excluded.ID) != nil {
+			return nil, ErrCredentialExcluded
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	created := &credential{id: id, rpID: options.RP.ID, userHandle: options.User.ID, key: key}
+	authenticator.credentials = append(authenticator.credentials, created)
+
+	clientDataJSON, err := authenticator.clientDataJSON("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attested credential data.
+	authenticatorData := authenticator.authenticatorData(created, 1<<6)
+	authenticatorData = append(authenticatorData, AAGUID...)
+	authenticatorData = binary.BigEndian.AppendUint16(authenticatorData, uint16(len(id)))
+	authenticatorData = append(authenticatorData, id...)
+	authenticatorData = appendCOSEKey(authenticatorData, &key.PublicKey)
+
+	attestationObject := appendMapHead(nil, 3)
+	attestationObject = appendText(attestationObject, "fmt")
+	attestationObject = appendText(attestationObject, "none")
+	attestationObject = appendText(attestationObject, "attStmt")
+	attestationObject = appendMapHead(attestationObject, 0)
+	attestationObject = appendText(attestationObject, "authData")
+	attestationObject = appendBytes(attestationObject, authenticatorData)
+
+	return &webauthn.RegistrationCredential{
+		ID:    webauthn.URLEncodedBase64(id).String(),
+		RawID: id,
+		Type:  webauthn.CredentialTypePublicKey,
+		Response: webauthn.AuthenticatorAttestationResponse{
+			ClientDataJSON:    clientDataJSON,
+			AttestationObject: attestationObject,
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Get runs an authentication ceremony, like navigator.credentials.get. Without allowed credentials, the first
+// credential created for the relying party is used.
+func (authenticator *Authenticator) Get(options *webauthn.RequestOptions) (*webauthn.AssertionCredential, error) {
+	var selected *credential
+
+	if len(options.AllowCredentials) == 0 {
+		selected = authenticator.find(options.RPID, nil)
+	}
+	for _, allowed := range options.AllowCredentials {
+		if selected = authenticator.find(options.RPID, allowed.ID); selected != nil {
+			break
+		}
+	}
+
+	if selected == nil {
+		return nil, ErrNoCredential
+	}
+
+	if !authenticator.ZeroSignCount {
+		selected.signCount++
+	}
+
+	clientDataJSON, err := authenticator.clientDataJSON("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticatorData := authenticator.authenticatorData(selected, 0)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(slices.Clip(authenticatorData), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, selected.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &webauthn.AssertionCredential{
+		ID:    webauthn.URLEncodedBase64(selected.id).String(),
+		RawID: selected.id,
+		Type:  webauthn.CredentialTypePublicKey,
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    clientDataJSON,
+			AuthenticatorData: authenticatorData,
+			Signature:         signature,
+			UserHandle:        selected.userHandle,
+		},
+	}, nil
+}
+
+// find returns the credential of the relying party with the ID, or its first credential if id is nil.
+func (authenticator *Authenticator) find(rpID string, id []byte) *credential {
+	for _, held := range authenticator.credentials {
+		if held.rpID == rpID && (id == nil || slices.Equal(held.id, id)) {
+			return held
+		}
+	}
+
+	return nil
+}
+
+func (authenticator *Authenticator) authenticatorData(held *credential, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(held.rpID))
+
+	output := append([]byte{}, rpIDHash[:]...)
+	output = append(output, authenticator.flags()|flags)
+	return binary.BigEndian.AppendUint32(output, held.signCount)
+}
+
+// appendCOSEKey encodes an ES256 public key, with the labels in canonical order.
+func appendCOSEKey(data []byte, key *ecdsa.PublicKey) []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	data = appendMapHead(data, 5)
+	// Key type: EC2.
+	data = appendInt(data, 1)
+	data = appendInt(data, 2)
+	// Algorithm: ES256.
+	data = appendInt(data, 3)
+	data = appendInt(data, webauthn.AlgorithmES256)
+	// Curve: P-256.
+	data = appendInt(data, -1)
+	data = appendInt(data, 1)
+	data = appendInt(data, -2)
+	data = appendBytes(data, x)
+	data = appendInt(data, -3)
+	return appendBytes(data, y)
+}
+
+func appendHead(data []byte, major byte, argument uint64) []byte {
+	major <<= 5
+
+	switch {
+	case argument < 24:
+		return append(data, major|byte(argument))
+	case argument <= 0xff:
+		return append(data, major|24, byte(argument))
+	case argument <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(data, major|25), uint16(argument))
+	case argument <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(data, major|26), uint32(argument))
+	default:
+		return binary.BigEndian.AppendUint64(append(data, major|27), argument)
+	}
+}
+
+func appendInt(data []byte, value int64) []byte {
+	if value < 0 {
+		return appendHead(data, 1, uint64(-1-value))
+	}
+
+	return appendHead(data, 0, uint64(value))
+}
+
+func appendBytes(data []byte, value []byte) []byte {
+	return append(appendHead(data, 2, uint64(len(value))), value...)
+}
+
+func appendText(data []byte, value string) []byte {
+	return append(appendHead(data, 3, uint64(len(value))), value...)
+}
+
+func appendMapHead(data []byte, size int) []byte {
+	return appendHead(data, 5, uint64(size))
+}